@@ -7,6 +7,7 @@ print("--- LOGOUT ---")
 
 url = f"{BASE_URL}/auth/logout"
 refresh_token = load_config("refreshToken")
+access_token = load_config("accessToken")
 
 if not refresh_token:
     print("Error: No refresh token found. Run A2.auth_login.py first.")
@@ -16,8 +17,14 @@ payload = {
     "refreshToken": refresh_token
 }
 
+# Sending the access token too makes the server revoke it immediately
+headers = {}
+if access_token:
+    headers["Authorization"] = f"Bearer {access_token}"
+
 response = send_and_print(
     url=url,
+    headers=headers,
     method="POST",
     body=payload,
     output_file=f"{os.path.splitext(os.path.basename(__file__))[0]}.json"
@@ -0,0 +1,108 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+print(f"\n{Colors.BOLD}=== TEST: SESSION MANAGEMENT ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"sessions_{timestamp}@test.com"
+password = "password123"
+
+def bearer(tokens):
+    return {"Authorization": f"Bearer {tokens['access']['token']}"}
+
+def login(device, output_file):
+    resp = send_and_print(f"{BASE_URL}/auth/login", headers={"User-Agent": device}, method="POST", body={"email": email, "password": password}, output_file=output_file)
+    return resp.json()['tokens']
+
+def list_sessions(headers, output_file):
+    resp = send_and_print(f"{BASE_URL}/auth/sessions", headers=headers, output_file=output_file)
+    return resp.json() if resp.status_code == 200 else []
+
+# 1. SETUP: three devices
+print(f"\n>> Step 1: Signing in from three devices ({email})...")
+reg = send_and_print(f"{BASE_URL}/auth/register", headers={"User-Agent": "T10-Laptop"}, method="POST", body={"name": "Session User", "email": email, "password": password}, output_file="test_sessions_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+laptop = reg.json()['tokens']
+phone = login("T10-Phone", "test_sessions_login_phone.json")
+tablet = login("T10-Tablet", "test_sessions_login_tablet.json")
+
+# 2. List sessions
+print(f"\n>> Step 2: Listing sessions...")
+sessions = list_sessions(bearer(laptop), "test_sessions_list.json")
+agents = sorted(s['userAgent'] for s in sessions)
+check(agents == ["T10-Laptop", "T10-Phone", "T10-Tablet"] and all(s['ipAddress'] and s['lastUsedAt'] for s in sessions),
+      "Three sessions listed with device, IP and last use.", f"Unexpected sessions: {sessions}")
+check(all('token' not in s for s in sessions), "Refresh tokens not exposed.", "Security Breach! Refresh token in session list.")
+phone_id = next((s['id'] for s in sessions if s['userAgent'] == "T10-Phone"), None)
+
+# 3. Refresh keeps the session
+print(f"\n>> Step 3: Refreshing the phone session...")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", headers={"User-Agent": "T10-Phone"}, method="POST", body={"refreshToken": phone['refresh']['token']}, output_file="test_sessions_refresh.json")
+check(resp.status_code == 200, "Phone session refreshed.", f"Refresh failed (Status: {resp.status_code}).")
+phone = resp.json()
+sessions = list_sessions(bearer(laptop), "test_sessions_list_after_refresh.json")
+check(len(sessions) == 3 and any(s['id'] == phone_id for s in sessions), "Same session id after rotation.", f"Unexpected sessions: {sessions}")
+
+# 4. Revoke one session
+print(f"\n>> Step 4: Revoking the phone session from the laptop...")
+other = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Other", "email": f"sessions_other_{timestamp}@test.com", "password": password}, output_file="test_sessions_other.json")
+resp = send_and_print(f"{BASE_URL}/auth/sessions/{phone_id}", headers=bearer(other.json()['tokens']), method="DELETE", output_file="test_sessions_other_revoke.json")
+check(resp.status_code == 404, "Another user cannot revoke the session (404).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/sessions/{phone_id}", headers=bearer(laptop), method="DELETE", output_file="test_sessions_revoke.json")
+check(resp.status_code == 204, "Session revoked (204).", f"Revocation failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": phone['refresh']['token']}, output_file="test_sessions_revoked_refresh.json")
+check(resp.status_code == 401, "Revoked session cannot refresh (401).", f"Revoked session refreshed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/sessions", headers=bearer(phone), output_file="test_sessions_revoked_access.json")
+check(resp.status_code == 401, "Revoked session's access token refused (401).", f"Access token still valid (Status: {resp.status_code}).")
+
+# 5. Admin view
+print(f"\n>> Step 5: Admin lists the user's sessions...")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/sessions", headers=admin_headers, output_file="test_sessions_admin_list.json")
+check(resp.status_code == 200 and len(resp.json()) == 2, "Admin sees the two remaining sessions.", f"Unexpected (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/sessions", headers=bearer(laptop), output_file="test_sessions_admin_forbidden.json")
+check(resp.status_code == 403, "Non-admin cannot use the admin endpoint (403).", f"Security Breach! (Status: {resp.status_code}).")
+
+# 6. Logout everywhere
+print(f"\n>> Step 6: Logging out everywhere...")
+time.sleep(1)  # Revocation cutoffs have one-second precision
+resp = send_and_print(f"{BASE_URL}/auth/logout-all", headers=bearer(laptop), method="POST", output_file="test_sessions_logout_all.json")
+check(resp.status_code == 204, "Logged out everywhere (204).", f"Logout-all failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/sessions", headers=bearer(tablet), output_file="test_sessions_after_logout_all.json")
+check(resp.status_code == 401, "Access tokens of other devices revoked (401).", f"Access token still valid (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": tablet['refresh']['token']}, output_file="test_sessions_after_logout_all_refresh.json")
+check(resp.status_code == 401, "Refresh tokens revoked (401).", f"Refresh token still valid (Status: {resp.status_code}).")
+
+# 7. Admin force logout
+print(f"\n>> Step 7: Admin force-logs out the account...")
+time.sleep(1)
+laptop = login("T10-Laptop", "test_sessions_relogin.json")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/sessions", headers=admin_headers, method="DELETE", output_file="test_sessions_admin_logout.json")
+check(resp.status_code == 204, "Admin force logout (204).", f"Force logout failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/sessions", headers=bearer(laptop), output_file="test_sessions_after_admin_logout.json")
+check(resp.status_code == 401, "User's access token revoked (401).", f"Access token still valid (Status: {resp.status_code}).")
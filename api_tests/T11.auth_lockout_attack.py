@@ -0,0 +1,88 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Expects the default lockout settings (LOCKOUT_MAX_ATTEMPTS=5, LOCKOUT_DELAY_SECONDS=1).
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+print(f"\n{Colors.BOLD}=== TEST: ACCOUNT LOCKOUT (BRUTE FORCE) ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"lockout_{timestamp}@test.com"
+password = "password123"
+
+def retry_after(resp):
+    return int(resp.result_dict.get("response", {}).get("headers", {}).get("Retry-After", "0"))
+
+def login(pw, output_file):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": pw}, output_file=output_file)
+
+# 1. SETUP
+print(f"\n>> Step 1: Registering victim ({email})...")
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Lockout Victim", "email": email, "password": password}, output_file="test_lockout_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+user_headers = {"Authorization": f"Bearer {reg.json()['tokens']['access']['token']}"}
+
+# 2. Progressive delay
+print(f"\n>> Step 2: A wrong password imposes a delay...")
+resp = login("wrong-password", "test_lockout_wrong_1.json")
+check(resp.status_code == 401, "Wrong password rejected (401).", f"Unexpected status {resp.status_code}.")
+resp = login(password, "test_lockout_too_fast.json")
+wait = retry_after(resp)
+check(resp.status_code == 429 and wait >= 1, f"Immediate retry throttled (429, Retry-After {wait}s), even with the right password.", f"Not throttled (Status: {resp.status_code}).")
+time.sleep(wait)
+resp = login(password, "test_lockout_after_delay.json")
+check(resp.status_code == 200, "Login allowed after waiting (200).", f"Login refused (Status: {resp.status_code}).")
+
+# 3. ATTACK: brute force until lockout
+print(f"\n>> Step 3: Guessing passwords until the account locks...")
+statuses = []
+for attempt in range(5):
+    resp = login(f"guess-{attempt}", f"test_lockout_guess_{attempt}.json")
+    while resp.status_code == 429:
+        time.sleep(max(retry_after(resp), 1))
+        resp = login(f"guess-{attempt}", f"test_lockout_guess_{attempt}.json")
+    statuses.append(resp.status_code)
+check(statuses == [401] * 5, "Five guesses rejected.", f"Unexpected statuses {statuses}.")
+
+resp = login(password, "test_lockout_locked.json")
+wait = retry_after(resp)
+check(resp.status_code == 429 and wait > 60, f"Account locked, even for the right password (429, Retry-After {wait}s).", f"Security Breach! Not locked (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=admin_headers, output_file="test_lockout_admin_view.json")
+check(resp.status_code == 200 and resp.json().get('lockedUntil'), "Admin sees lockedUntil.", f"lockedUntil missing (Status: {resp.status_code}).")
+
+# 4. Unlocking
+print(f"\n>> Step 4: Unlocking...")
+resp = send_and_print(f"{BASE_URL}/auth/unlock-account?token=invalid", method="POST", output_file="test_lockout_bad_unlock.json")
+check(resp.status_code == 401, "Invalid unlock link rejected (401).", f"Unexpected status {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/unlock", headers=user_headers, method="POST", output_file="test_lockout_self_unlock.json")
+check(resp.status_code == 403, "Users cannot use the admin unlock (403).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/unlock", headers=admin_headers, method="POST", output_file="test_lockout_admin_unlock.json")
+check(resp.status_code == 204, "Admin unlocked the account (204).", f"Unlock failed (Status: {resp.status_code}).")
+resp = login(password, "test_lockout_after_unlock.json")
+check(resp.status_code == 200, "Login works again (200).", f"Still locked (Status: {resp.status_code}).")
@@ -0,0 +1,79 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Run the API with:
+#   PASSWORD_REQUIRE_DIGIT=true
+#   PASSWORD_BREACHED_RANGES_DIR=<directory with a 7E8B0.txt file containing the line below>
+#   A3433F1210A9699D85420E363A1B162ECAC:42   (SHA-1 of "Summer2024!" is 7E8B0A3433...)
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def violations(resp):
+    body = resp.json() or {}
+    message = (body.get('errors') or {}).get('Password', "")
+    return message.replace("Failed validation: ", "").split(",") if message else []
+
+print(f"\n{Colors.BOLD}=== TEST: PASSWORD POLICY ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+
+def register(password, tag):
+    return send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+        "name": "Margaret Hamilton", "email": f"mhamilton_{tag}_{timestamp}@test.com", "password": password,
+    }, output_file=f"test_policy_register_{tag}.json")
+
+# 1. Rules on registration
+print(f"\n>> Step 1: Weak passwords are rejected at registration...")
+resp = register("a1", "short")
+check(resp.status_code == 400 and "min_length" in violations(resp), "Too short (min_length).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = register("x1" * 40, "long")
+check(resp.status_code == 400 and "max_length" in violations(resp), "Too long (max_length).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = register("nodigitshere", "digit")
+check(resp.status_code == 400 and violations(resp) == ["digit"], "Missing digit (digit).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = register("hamilton1969", "name")
+check(resp.status_code == 400 and "personal_info" in violations(resp), "Contains the name (personal_info).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = register("Summer2024!", "breached")
+check(resp.status_code == 400 and violations(resp) == ["breached"], "Known breached password (breached).", f"Unexpected: {resp.status_code} {violations(resp)}")
+
+resp = register("Apollo11-guidance", "ok")
+check(resp.status_code == 201, "Compliant password accepted (201).", f"Registration failed (Status: {resp.status_code}).")
+if resp.status_code != 201:
+    sys.exit(1)
+user_id = resp.json()['user']['id']
+
+# 2. History
+print(f"\n>> Step 2: Previous passwords cannot be reused...")
+def set_password(password, output_file):
+    return send_and_print(f"{BASE_URL}/users/{user_id}", headers=admin_headers, method="PATCH", body={"password": password}, output_file=output_file)
+
+resp = set_password("Apollo11-guidance", "test_policy_same.json")
+check(resp.status_code == 400 and violations(resp) == ["reused"], "Current password rejected (reused).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = set_password("Lunar-module-5", "test_policy_change.json")
+check(resp.status_code == 200, "New password accepted (200).", f"Change failed (Status: {resp.status_code}).")
+resp = set_password("Apollo11-guidance", "test_policy_back.json")
+check(resp.status_code == 400 and violations(resp) == ["reused"], "Previous password rejected (reused).", f"Unexpected: {resp.status_code} {violations(resp)}")
+resp = set_password("short", "test_policy_update_weak.json")
+check(resp.status_code == 400 and "min_length" in violations(resp), "Policy also applies to updates.", f"Unexpected: {resp.status_code} {violations(resp)}")
+
+resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": f"mhamilton_ok_{timestamp}@test.com", "password": "Lunar-module-5"}, output_file="test_policy_login.json")
+check(resp.status_code == 200, "Login with the new password (200).", f"Login failed (Status: {resp.status_code}).")
@@ -0,0 +1,95 @@
+import sys
+import os
+import time
+import sqlite3
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, save_config, load_config
+
+# Two phases, the API is restarted in between:
+#   1. PASSWORD_HASH_ALGORITHM=bcrypt   python T13.password_rehash.py register
+#   2. PASSWORD_HASH_ALGORITHM=argon2id python T13.password_rehash.py login
+# Set DB_FILE to the SQLite database of the API to also inspect the stored hashes.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def stored_hash(email):
+    db_file = os.environ.get("DB_FILE")
+    if not db_file:
+        return None
+    with sqlite3.connect(db_file) as conn:
+        row = conn.execute("SELECT password FROM users WHERE email = ?", (email,)).fetchone()
+    return row[0] if row else None
+
+def login(email, password, output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={
+        "email": email, "password": password,
+    }, output_file=output)
+
+phase = sys.argv[1] if len(sys.argv) > 1 else "register"
+password = "Blue-horizon-cascade-42"
+
+print(f"\n{Colors.BOLD}=== TEST: PASSWORD REHASH ON LOGIN ({phase}) ==={Colors.ENDC}")
+
+if phase == "register":
+    email = f"rehash_{int(time.time())}@test.com"
+    print(f"\n>> Step 1: Registering while the API hashes with bcrypt...")
+    resp = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+        "name": "Rehash User", "email": email, "password": password,
+    }, output_file="test_rehash_register.json")
+    check(resp.status_code == 201, "User registered (201).", f"Registration failed (Status: {resp.status_code}).")
+    save_config("rehashEmail", email)
+
+    hash_value = stored_hash(email)
+    if hash_value is not None:
+        check(hash_value.startswith("$2"), "Password stored as a bcrypt hash.", f"Unexpected hash format: {hash_value[:12]}")
+
+    print(f"\n{Colors.WARNING}Restart the API with PASSWORD_HASH_ALGORITHM=argon2id and run this script with 'login'.{Colors.ENDC}")
+    sys.exit(0)
+
+email = load_config("rehashEmail")
+if not email:
+    print(f"{Colors.FAIL}No user from the register phase. Run this script with 'register' first.{Colors.ENDC}")
+    sys.exit(1)
+
+# 2. The old bcrypt hash still verifies and is upgraded
+print(f"\n>> Step 2: Logging in with the bcrypt hashed password...")
+resp = login(email, password, "test_rehash_login_1.json")
+check(resp.status_code == 200, "Login with the old hash succeeded (200).", f"Login failed (Status: {resp.status_code}).")
+
+hash_value = stored_hash(email)
+if hash_value is not None:
+    check(hash_value.startswith("$argon2id$v=19$"), "Hash upgraded to an argon2id PHC string.", f"Hash was not upgraded: {hash_value[:12]}")
+
+# 3. The new hash verifies, and only the right password
+print(f"\n>> Step 3: Logging in again with the rehashed password...")
+resp = login(email, password, "test_rehash_login_2.json")
+check(resp.status_code == 200, "Login with the new hash succeeded (200).", f"Login failed (Status: {resp.status_code}).")
+
+resp = login(email, password + "x", "test_rehash_login_wrong.json")
+check(resp.status_code == 401, "Wrong password still rejected (401).", f"Unexpected status: {resp.status_code}.")
+
+# 4. New registrations use argon2id right away
+print(f"\n>> Step 4: Registering a new user with argon2id active...")
+new_email = f"argon_{int(time.time())}@test.com"
+resp = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Argon User", "email": new_email, "password": password,
+}, output_file="test_rehash_register_argon.json")
+check(resp.status_code == 201, "User registered (201).", f"Registration failed (Status: {resp.status_code}).")
+hash_value = stored_hash(new_email)
+if hash_value is not None:
+    check(hash_value.startswith("$argon2id$"), "Password stored as an argon2id hash.", f"Unexpected hash format: {hash_value[:12]}")
+
+resp = login(new_email, password, "test_rehash_login_argon.json")
+check(resp.status_code == 200, "Login with the argon2id hash succeeded (200).", f"Login failed (Status: {resp.status_code}).")
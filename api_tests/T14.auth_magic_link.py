@@ -0,0 +1,94 @@
+import sys
+import os
+import time
+import sqlite3
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# Uses the default MAGIC_LINK_MAX_REQUESTS=3. Set DB_FILE to the SQLite database of the API
+# to pick up the emailed link token and test the login itself.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def latest_link_token(user_id):
+    db_file = os.environ.get("DB_FILE")
+    if not db_file:
+        return None
+    # Links are sent in the background, give the server a moment
+    for _ in range(20):
+        with sqlite3.connect(db_file) as conn:
+            row = conn.execute("SELECT token FROM tokens WHERE user_id = ? AND type = 'magicLink' ORDER BY id DESC LIMIT 1", (user_id,)).fetchone()
+        if row:
+            return row[0]
+        time.sleep(0.1)
+    return None
+
+def request_link(email, output):
+    return send_and_print(f"{BASE_URL}/auth/magic-link", method="POST", body={"email": email}, output_file=output)
+
+def verify_link(token, output):
+    return send_and_print(f"{BASE_URL}/auth/magic-link/verify?token={token}", method="POST", output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: MAGIC LINK LOGIN ==={Colors.ENDC}")
+
+timestamp = int(time.time())
+email = f"magic_{timestamp}@test.com"
+unknown_email = f"nobody_{timestamp}@test.com"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Magic User", "email": email, "password": "Correct-horse-battery-9",
+}, output_file="test_magic_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+
+# 1. Requests do not reveal whether the account exists
+print(f"\n>> Step 1: Requesting links for a known and an unknown email...")
+known = request_link(email, "test_magic_request_known.json")
+check(known.status_code == 200, "Known email answered 200.", f"Unexpected status: {known.status_code}.")
+unknown = request_link(unknown_email, "test_magic_request_unknown.json")
+check(unknown.status_code == 200 and unknown.json() == known.json(), "Unknown email gets the same answer.", f"Unexpected: {unknown.status_code} {unknown.json()}")
+resp = request_link("not-an-email", "test_magic_request_invalid.json")
+check(resp.status_code == 400, "Malformed email rejected (400).", f"Unexpected status: {resp.status_code}.")
+
+# 2. Bad links are refused
+print(f"\n>> Step 2: Verifying a forged link...")
+resp = verify_link("forged-token", "test_magic_verify_forged.json")
+check(resp.status_code == 401, "Forged token rejected (401).", f"Unexpected status: {resp.status_code}.")
+
+# 3. The emailed link logs in once
+token = latest_link_token(user_id)
+if token is None:
+    print(f"{Colors.WARNING}DB_FILE not set, skipping the login with the emailed link.{Colors.ENDC}")
+else:
+    print(f"\n>> Step 3: Logging in with the emailed link...")
+    resp = verify_link(token, "test_magic_verify.json")
+    tokens = (resp.json() or {}).get('tokens')
+    check(resp.status_code == 200 and tokens, "Link exchanged for a token pair (200).", f"Login failed (Status: {resp.status_code}).")
+    resp = verify_link(token, "test_magic_verify_reuse.json")
+    check(resp.status_code == 401, "Link cannot be used twice (401).", f"Reused link accepted (Status: {resp.status_code}).")
+
+# 4. Requests are rate limited per email, known or not
+print(f"\n>> Step 4: Flooding link requests...")
+for target, label in ((email, "known"), (unknown_email, "unknown")):
+    statuses = [request_link(target, f"test_magic_flood_{label}_{i}.json").status_code for i in range(3)]
+    limited = next((i for i, status in enumerate(statuses) if status == 429), None)
+    check(limited is not None, f"{label.capitalize()} email limited after {limited or 0} more requests (429).", f"No limit for the {label} email: {statuses}")
+resp = request_link(email, "test_magic_flood_retry.json")
+retry_after = resp.result_dict["response"]["headers"].get("Retry-After")
+check(resp.status_code == 429 and retry_after, f"Retry-After given ({retry_after}s).", f"Unexpected: {resp.status_code} Retry-After={retry_after}")
+resp = request_link(f"other_{timestamp}@test.com", "test_magic_other.json")
+check(resp.status_code == 200, "Other emails are not affected (200).", f"Unexpected status: {resp.status_code}.")
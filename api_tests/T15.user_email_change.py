@@ -0,0 +1,107 @@
+import sys
+import os
+import time
+import sqlite3
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# Set DB_FILE to the SQLite database of the API to pick up the mailed tokens
+# and test confirming and cancelling.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def mailed_token(user_id, token_type):
+    db_file = os.environ.get("DB_FILE")
+    if not db_file:
+        return None
+    with sqlite3.connect(db_file) as conn:
+        row = conn.execute("SELECT token FROM tokens WHERE user_id = ? AND type = ? ORDER BY id DESC LIMIT 1", (user_id, token_type)).fetchone()
+    return row[0] if row else None
+
+def request_change(headers, email, password, output):
+    return send_and_print(f"{BASE_URL}/users/me/email-change", method="POST", headers=headers, body={"email": email, "password": password}, output_file=output)
+
+def login(email, output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: EMAIL CHANGE ==={Colors.ENDC}")
+
+timestamp = int(time.time())
+email = f"mover_{timestamp}@test.com"
+new_email = f"moved_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Mover", "email": email, "password": password,
+}, output_file="test_email_change_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+tokens = reg.json()['tokens']
+headers = {"Authorization": f"Bearer {tokens['access']['token']}"}
+
+send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Squatter", "email": f"taken_{timestamp}@test.com", "password": password,
+}, output_file="test_email_change_register_taken.json")
+
+# 1. The request is checked
+print(f"\n>> Step 1: Invalid change requests...")
+resp = request_change({}, new_email, password, "test_email_change_anonymous.json")
+check(resp.status_code == 401, "Anonymous request rejected (401).", f"Unexpected status: {resp.status_code}.")
+resp = request_change(headers, new_email, "wrong-password", "test_email_change_wrong_password.json")
+check(resp.status_code == 403, "Wrong password rejected (403).", f"Unexpected status: {resp.status_code}.")
+resp = request_change(headers, f"taken_{timestamp}@test.com", password, "test_email_change_taken.json")
+check(resp.status_code == 400, "Address of another account rejected (400).", f"Unexpected status: {resp.status_code}.")
+
+# 2. A valid request only sets the pending email
+print(f"\n>> Step 2: Requesting the change...")
+resp = request_change(headers, new_email, password, "test_email_change_request.json")
+check(resp.status_code == 202, "Change requested (202).", f"Request failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=headers, output_file="test_email_change_pending.json")
+body = resp.json() or {}
+check(body.get('email') == email and body.get('pendingEmail') == new_email, "Email unchanged, new one pending.", f"Unexpected user: {body}")
+
+resp = send_and_print(f"{BASE_URL}/users/me/email-change/confirm?token=forged", method="POST", output_file="test_email_change_forged.json")
+check(resp.status_code == 401, "Forged confirmation rejected (401).", f"Unexpected status: {resp.status_code}.")
+
+if not os.environ.get("DB_FILE"):
+    print(f"{Colors.WARNING}DB_FILE not set, skipping confirmation and cancellation.{Colors.ENDC}")
+    sys.exit(0)
+
+# 3. The old address can cancel
+print(f"\n>> Step 3: Cancelling from the old address...")
+cancel_token = mailed_token(user_id, "cancelEmail")
+resp = send_and_print(f"{BASE_URL}/users/me/email-change/cancel?token={cancel_token}", method="POST", output_file="test_email_change_cancel.json")
+check(resp.status_code == 204, "Change cancelled (204).", f"Cancel failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=headers, output_file="test_email_change_cancelled.json")
+check(not (resp.json() or {}).get('pendingEmail'), "Pending email cleared.", f"Still pending: {resp.json()}")
+
+# 4. Confirming from the new address swaps the email and ends the sessions
+print(f"\n>> Step 4: Requesting again and confirming from the new address...")
+request_change(headers, new_email, password, "test_email_change_request_2.json")
+confirm_token = mailed_token(user_id, "emailChange")
+resp = send_and_print(f"{BASE_URL}/users/me/email-change/confirm?token={confirm_token}", method="POST", output_file="test_email_change_confirm.json")
+check(resp.status_code == 204, "Change confirmed (204).", f"Confirmation failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/me/email-change/confirm?token={confirm_token}", method="POST", output_file="test_email_change_confirm_again.json")
+check(resp.status_code == 401, "Confirmation link is single-use (401).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": tokens['refresh']['token']}, output_file="test_email_change_old_refresh.json")
+check(resp.status_code == 401, "Refresh token revoked (401).", f"Old refresh token still works (Status: {resp.status_code}).")
+resp = login(email, "test_email_change_login_old.json")
+check(resp.status_code == 401, "Old email no longer logs in (401).", f"Unexpected status: {resp.status_code}.")
+resp = login(new_email, "test_email_change_login_new.json")
+user = (resp.json() or {}).get('user') or {}
+check(resp.status_code == 200 and user.get('email') == new_email and user.get('isEmailVerified') is False, "New email logs in, its verification state reset.", f"Unexpected: {resp.status_code} {user}")
@@ -0,0 +1,92 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def login(pw, output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": pw}, output_file=output)
+
+def refresh(tokens, output):
+    return send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": tokens['refresh']['token']}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: SELF-SERVICE (/users/me) ==={Colors.ENDC}")
+
+timestamp = int(time.time())
+email = f"self_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+new_password = "Tr0ub4dor-and-more-3"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Self Service", "email": email, "password": password,
+}, output_file="test_me_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+laptop = reg.json()['tokens']
+phone = login(password, "test_me_login_phone.json").json()['tokens']
+headers = {"Authorization": f"Bearer {laptop['access']['token']}"}
+
+# 1. Profile
+print(f"\n>> Step 1: Reading and updating the profile...")
+resp = send_and_print(f"{BASE_URL}/users/me", headers=headers, output_file="test_me_get.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('email') == email, "GET /users/me returns the signed-in user.", f"Unexpected: {resp.status_code}")
+resp = send_and_print(f"{BASE_URL}/users/me", output_file="test_me_get_anonymous.json")
+check(resp.status_code == 401, "Anonymous GET rejected (401).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/users/me", method="PATCH", headers=headers, body={
+    "name": "Renamed Self", "role": "admin", "email": f"sneaky_{timestamp}@test.com",
+}, output_file="test_me_patch.json")
+body = resp.json() or {}
+check(resp.status_code == 200 and body.get('name') == "Renamed Self", "Name updated (200).", f"Update failed (Status: {resp.status_code}).")
+check(body.get('role') == "user" and body.get('email') == email, "Role and email cannot be changed here.", f"Escalation or email change: {body}")
+
+# 2. Password change
+print(f"\n>> Step 2: Changing the password...")
+resp = send_and_print(f"{BASE_URL}/users/me/password", method="POST", headers=headers, body={
+    "currentPassword": "not-my-password", "newPassword": new_password,
+}, output_file="test_me_password_wrong.json")
+check(resp.status_code == 403, "Wrong current password rejected (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/me/password", method="POST", headers=headers, body={
+    "currentPassword": password, "newPassword": "short",
+}, output_file="test_me_password_weak.json")
+check(resp.status_code == 400, "Weak new password rejected by the policy (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/me/password", method="POST", headers=headers, body={
+    "currentPassword": password, "newPassword": new_password,
+}, output_file="test_me_password.json")
+check(resp.status_code == 204, "Password changed (204).", f"Change failed (Status: {resp.status_code}).")
+
+resp = refresh(laptop, "test_me_refresh_laptop.json")
+check(resp.status_code == 200, "This session stays signed in.", f"Own session ended (Status: {resp.status_code}).")
+laptop = resp.json() if resp.status_code == 200 else laptop
+headers = {"Authorization": f"Bearer {laptop['access']['token']}"}
+resp = refresh(phone, "test_me_refresh_phone.json")
+check(resp.status_code == 401, "Other sessions ended (401).", f"Other session still works (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/me", headers={"Authorization": f"Bearer {phone['access']['token']}"}, output_file="test_me_phone_access.json")
+check(resp.status_code == 401, "Their access tokens revoked too (401).", f"Other session's access token still works (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/me", headers=headers, output_file="test_me_laptop_access.json")
+check(resp.status_code == 200, "This session's access token still works (200).", f"Own access token revoked (Status: {resp.status_code}).")
+check(login(new_password, "test_me_login_new.json").status_code == 200, "New password works.", "New password rejected.")
+check(login(password, "test_me_login_old.json").status_code == 401, "Old password rejected.", "Old password still works.")
+
+# 3. Account deletion
+print(f"\n>> Step 3: Deleting the account...")
+resp = send_and_print(f"{BASE_URL}/users/me", method="DELETE", headers=headers, body={"password": password}, output_file="test_me_delete_wrong.json")
+check(resp.status_code == 400, "Deletion needs the right password (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/me", method="DELETE", headers=headers, body={"password": new_password}, output_file="test_me_delete.json")
+check(resp.status_code == 204, "Account deleted (204).", f"Deletion failed (Status: {resp.status_code}).")
+check(login(new_password, "test_me_login_deleted.json").status_code == 401, "Deleted account cannot log in.", "Deleted account still logs in.")
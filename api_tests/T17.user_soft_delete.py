@@ -0,0 +1,85 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def search(extra, output):
+    resp = send_and_print(f"{BASE_URL}/users?search={email}&scope=email{extra}", headers=admin_headers, output_file=output)
+    return (resp.json() or {}).get('results') or []
+
+def login(output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: SOFT DELETE AND RESTORE ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"deleted_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Soon Gone", "email": email, "password": password,
+}, output_file="test_soft_delete_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+refresh_token = reg.json()['tokens']['refresh']['token']
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/api-keys", method="POST", headers={"Authorization": f"Bearer {reg.json()['tokens']['access']['token']}"}, body={"name": "Backup script"}, output_file="test_soft_delete_api_key.json")
+api_key = (resp.json() or {}).get('key', "")
+
+# 1. Deleting hides the user everywhere
+print(f"\n>> Step 1: Admin deletes the user...")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="DELETE", headers=admin_headers, output_file="test_soft_delete_delete.json")
+check(resp.status_code == 204, "User deleted (204).", f"Deletion failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=admin_headers, output_file="test_soft_delete_get.json")
+check(resp.status_code == 404, "Deleted user not found by ID (404).", f"Unexpected status: {resp.status_code}.")
+check(search("", "test_soft_delete_list.json") == [], "Deleted user left out of the list.", "Deleted user still listed.")
+check(login("test_soft_delete_login.json").status_code == 401, "Deleted user cannot log in (401).", "Deleted user still logs in.")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": refresh_token}, output_file="test_soft_delete_refresh.json")
+check(resp.status_code == 401, "Sessions of the deleted user ended (401).", f"Refresh still works (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": api_key}, output_file="test_soft_delete_api_key_use.json")
+check(api_key and resp.status_code == 401, "API keys of the deleted user refused (401).", f"Deleted user's key still works (Status: {resp.status_code}).")
+
+# 2. Admins can still see it, and the email stays reserved
+print(f"\n>> Step 2: Listing with includeDeleted=true...")
+results = search("&includeDeleted=true", "test_soft_delete_list_deleted.json")
+check(len(results) == 1 and results[0].get('deletedAt'), "Deleted user listed with deletedAt.", f"Unexpected results: {results}")
+resp = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Email Thief", "email": email, "password": password,
+}, output_file="test_soft_delete_reuse_email.json")
+check(resp.status_code == 400, "Email of the deleted user cannot be registered (400).", f"Unexpected status: {resp.status_code}.")
+
+# 3. Restore
+print(f"\n>> Step 3: Restoring the user...")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/restore", method="POST", output_file="test_soft_delete_restore_anonymous.json")
+check(resp.status_code == 401, "Anonymous restore rejected (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/restore", method="POST", headers=admin_headers, output_file="test_soft_delete_restore.json")
+body = resp.json() or {}
+check(resp.status_code == 200 and body.get('id') == user_id and not body.get('deletedAt'), "User restored (200).", f"Restore failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/restore", method="POST", headers=admin_headers, output_file="test_soft_delete_restore_again.json")
+check(resp.status_code == 404, "Restoring a live user fails (404).", f"Unexpected status: {resp.status_code}.")
+check(len(search("", "test_soft_delete_list_restored.json")) == 1, "Restored user listed again.", "Restored user missing from the list.")
+check(login("test_soft_delete_login_restored.json").status_code == 200, "Restored user logs in (200).", "Restored user cannot log in.")
+
+print(f"\n{Colors.WARNING}The purge after USER_DELETED_RETENTION_DAYS runs hourly and is not exercised here.{Colors.ENDC}")
@@ -0,0 +1,109 @@
+import sys
+import os
+import time
+from datetime import datetime, timedelta, timezone
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def login(pw, output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": pw}, output_file=output)
+
+def admin_action(action, body, output):
+    return send_and_print(f"{BASE_URL}/users/{user_id}/{action}", method="POST", headers=admin_headers, body=body, output_file=output)
+
+def in_seconds(seconds):
+    return (datetime.now(timezone.utc) + timedelta(seconds=seconds)).isoformat()
+
+print(f"\n{Colors.BOLD}=== TEST: ACCOUNT STATUS ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"status_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Status User", "email": email, "password": password,
+}, output_file="test_status_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+tokens = reg.json()['tokens']
+user_headers = {"Authorization": f"Bearer {tokens['access']['token']}"}
+check(reg.json()['user'].get('status') == "active", "New users are active.", f"Unexpected status: {reg.json()['user'].get('status')}")
+
+key = send_and_print(f"{BASE_URL}/users/{user_id}/api-keys", method="POST", headers=user_headers, body={"name": "status-test"}, output_file="test_status_api_key.json")
+api_key = (key.json() or {}).get('key')
+
+# 1. Suspension
+print(f"\n>> Step 1: Suspending the user...")
+resp = admin_action("suspend", {"reason": "Chargeback", "until": in_seconds(-60)}, "test_status_suspend_past.json")
+check(resp.status_code == 400, "Suspension ending in the past rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = admin_action("suspend", {}, "test_status_suspend_no_reason.json")
+check(resp.status_code == 400, "Suspension without a reason rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/suspend", method="POST", headers=user_headers, body={"reason": "self"}, output_file="test_status_suspend_by_user.json")
+check(resp.status_code == 403, "Users cannot change statuses (403).", f"Unexpected status: {resp.status_code}.")
+
+resp = admin_action("suspend", {"reason": "Chargeback"}, "test_status_suspend.json")
+body = resp.json() or {}
+check(resp.status_code == 200 and body.get('status') == "suspended" and body.get('statusReason') == "Chargeback", "User suspended with a reason (200).", f"Suspension failed (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=user_headers, output_file="test_status_access_token.json")
+check(resp.status_code in (401, 403), f"Access token refused ({resp.status_code}).", f"Access token still works (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": tokens['refresh']['token']}, output_file="test_status_refresh.json")
+check(resp.status_code == 401, "Refresh tokens revoked (401).", f"Refresh still works (Status: {resp.status_code}).")
+if api_key:
+    resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": api_key}, output_file="test_status_api_key_use.json")
+    check(resp.status_code == 403, "API key refused (403).", f"API key still works (Status: {resp.status_code}).")
+resp = login(password, "test_status_login_suspended.json")
+check(resp.status_code == 403, "Login refused for the suspended account (403).", f"Unexpected status: {resp.status_code}.")
+time.sleep(1.1)
+resp = login("wrong-password", "test_status_login_wrong.json")
+check(resp.status_code == 401, "Wrong password does not reveal the status (401).", f"Unexpected status: {resp.status_code}.")
+
+# 2. Filtering and reactivation
+print(f"\n>> Step 2: Listing by status and reactivating...")
+resp = send_and_print(f"{BASE_URL}/users?status=suspended&search={email}&scope=email", headers=admin_headers, output_file="test_status_filter.json")
+results = (resp.json() or {}).get('results') or []
+check(len(results) == 1, "status filter finds the suspended user.", f"Unexpected results: {results}")
+resp = send_and_print(f"{BASE_URL}/users?status=active&search={email}&scope=email", headers=admin_headers, output_file="test_status_filter_active.json")
+check(((resp.json() or {}).get('results') or []) == [], "status=active leaves it out.", "Suspended user listed as active.")
+
+resp = admin_action("reactivate", None, "test_status_reactivate.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('status') == "active", "User reactivated (200).", f"Reactivation failed (Status: {resp.status_code}).")
+time.sleep(2.1)
+check(login(password, "test_status_login_reactivated.json").status_code == 200, "Reactivated user logs in.", "Reactivated user cannot log in.")
+
+# 3. Timed suspension ends by itself
+print(f"\n>> Step 3: Suspending for 3 seconds...")
+resp = admin_action("suspend", {"reason": "Cool down", "until": in_seconds(3)}, "test_status_suspend_timed.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('suspendedUntil'), "Timed suspension set (200).", f"Suspension failed (Status: {resp.status_code}).")
+check(login(password, "test_status_login_timed.json").status_code == 403, "Login refused during the suspension.", "Login allowed during the suspension.")
+time.sleep(4)
+check(login(password, "test_status_login_after.json").status_code == 200, "Login allowed once it ended.", "Login still refused after the end date.")
+
+# 4. Ban
+print(f"\n>> Step 4: Banning the user...")
+resp = admin_action("ban", {"reason": "Fraud"}, "test_status_ban.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('status') == "banned", "User banned (200).", f"Ban failed (Status: {resp.status_code}).")
+resp = login(password, "test_status_login_banned.json")
+check(resp.status_code == 403 and "banned" in ((resp.json() or {}).get('message') or ""), "Login refused for the banned account (403).", f"Unexpected: {resp.status_code}")
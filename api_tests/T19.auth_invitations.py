@@ -0,0 +1,161 @@
+import sys
+import os
+import time
+import sqlite3
+import hashlib
+import secrets
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Run the API with REGISTRATION_MODE=invite-only.
+# Set DB_FILE to the SQLite database of the API to pick up the mailed tokens
+# and test accepting invitations.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+# Only a hash of the mailed token is stored, so the test swaps in the hash of a token of its own
+def mailed_token(invitation_id):
+    db_file = os.environ.get("DB_FILE")
+    if not db_file:
+        return None
+    token = secrets.token_urlsafe(32)
+    with sqlite3.connect(db_file) as conn:
+        row = conn.execute("SELECT token FROM invitations WHERE id = ?", (invitation_id,)).fetchone()
+        if not row:
+            return None
+        if len(row[0]) != 64:
+            print(f"{Colors.FAIL}[FAIL] Invitation token stored in the clear.{Colors.ENDC}")
+            return None
+        conn.execute("UPDATE invitations SET token = ? WHERE id = ?", (hashlib.sha256(token.encode()).hexdigest(), invitation_id))
+    return token
+
+def invite(email, role, output, headers=None):
+    return send_and_print(f"{BASE_URL}/invitations", method="POST", headers=headers or admin_headers, body={"email": email, "role": role}, output_file=output)
+
+def accept(token, name, pw, output):
+    return send_and_print(f"{BASE_URL}/auth/invitations/accept?token={token}", method="POST", body={"name": name, "password": pw}, output_file=output)
+
+def pending_ids():
+    resp = send_and_print(f"{BASE_URL}/invitations", headers=admin_headers, output_file="test_invite_list.json")
+    return [i['id'] for i in (resp.json() or [])]
+
+print(f"\n{Colors.BOLD}=== TEST: INVITE-ONLY REGISTRATION ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"invitee_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+# 1. Self-registration is refused
+print(f"\n>> Step 1: Registering without an invitation...")
+resp = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Gatecrasher", "email": f"gatecrasher_{timestamp}@test.com", "password": password,
+}, output_file="test_invite_register.json")
+check(resp.status_code == 403, "Registration refused (403).", f"Unexpected status: {resp.status_code}. Is REGISTRATION_MODE=invite-only?")
+
+# 2. Admins invite
+print(f"\n>> Step 2: Creating invitations...")
+resp = invite(email, "owner", "test_invite_bad_role.json")
+check(resp.status_code == 400, "Unknown role rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = invite("admin@example.com", "user", "test_invite_taken.json")
+check(resp.status_code == 400, "Registered email rejected (400).", f"Unexpected status: {resp.status_code}.")
+
+member = send_and_print(f"{BASE_URL}/users", method="POST", headers=admin_headers, body={
+    "name": "Member", "email": f"member_{timestamp}@test.com", "password": password, "role": "user",
+}, output_file="test_invite_member.json")
+login = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": f"member_{timestamp}@test.com", "password": password}, output_file="test_invite_member_login.json")
+if login.status_code == 200:
+    member_headers = {"Authorization": f"Bearer {login.json()['tokens']['access']['token']}"}
+    resp = invite(email, "user", "test_invite_by_member.json", headers=member_headers)
+    check(resp.status_code == 403, "Non-admins cannot invite (403).", f"Unexpected status: {resp.status_code}.")
+
+resp = invite(email, "admin", "test_invite_create.json")
+invitation = resp.json() or {}
+check(resp.status_code == 201 and invitation.get('role') == "admin" and invitation.get('acceptedAt') is None,
+      "Invitation created (201).", f"Invitation failed (Status: {resp.status_code}).")
+check('token' not in invitation, "Token is not in the response.", "Token leaked in the response.")
+invitation_id = invitation.get('id')
+check(invitation_id in pending_ids(), "Invitation listed as pending.", "Invitation missing from the pending list.")
+
+resp = invite(email, "admin", "test_invite_recreate.json")
+replaced_id = invitation_id
+invitation_id = (resp.json() or {}).get('id')
+ids = pending_ids()
+check(resp.status_code == 201 and invitation_id in ids and replaced_id not in ids, "Inviting again replaces the pending invitation.", f"Unexpected pending list: {ids}")
+
+token = mailed_token(invitation_id)
+if not token:
+    print(f"{Colors.WARNING}DB_FILE not set, skipping acceptance.{Colors.ENDC}")
+    sys.exit(0)
+check(mailed_token(replaced_id) is None, "Replaced invitation deleted.", "Replaced invitation still stored.")
+
+# 3. Accepting
+print(f"\n>> Step 3: Accepting the invitation...")
+resp = accept("not-a-token", "Invitee", password, "test_invite_accept_bad.json")
+check(resp.status_code == 401, "Unknown token rejected (401).", f"Unexpected status: {resp.status_code}.")
+resp = accept(token, "Invitee", "short", "test_invite_accept_weak.json")
+check(resp.status_code == 400, "Password policy applies (400).", f"Unexpected status: {resp.status_code}.")
+
+resp = accept(token, "Invitee", password, "test_invite_accept.json")
+body = resp.json() or {}
+user = body.get('user') or {}
+check(resp.status_code == 201, "Account created (201).", f"Accepting failed (Status: {resp.status_code}).")
+check(user.get('email') == email and user.get('role') == "admin" and user.get('isEmailVerified') is True,
+      "Account has the invited email and role, verified.", f"Unexpected user: {user}")
+if body.get('tokens'):
+    resp = send_and_print(f"{BASE_URL}/users/me", headers={"Authorization": f"Bearer {body['tokens']['access']['token']}"}, output_file="test_invite_me.json")
+    check(resp.status_code == 200, "Returned tokens work.", f"Tokens refused (Status: {resp.status_code}).")
+
+resp = accept(token, "Invitee", password, "test_invite_accept_again.json")
+check(resp.status_code == 401, "Invitation is single-use (401).", f"Unexpected status: {resp.status_code}.")
+check(invitation_id not in pending_ids(), "Accepted invitation no longer pending.", "Accepted invitation still listed.")
+resp = send_and_print(f"{BASE_URL}/invitations/{invitation_id}", method="DELETE", headers=admin_headers, output_file="test_invite_revoke_accepted.json")
+check(resp.status_code == 404, "Accepted invitation cannot be revoked (404).", f"Unexpected status: {resp.status_code}.")
+
+# 4. Revoking
+print(f"\n>> Step 4: Revoking an invitation...")
+resp = invite(f"revoked_{timestamp}@test.com", "user", "test_invite_create_2.json")
+revoked_id = (resp.json() or {}).get('id')
+revoked_token = mailed_token(revoked_id)
+resp = send_and_print(f"{BASE_URL}/invitations/{revoked_id}", method="DELETE", headers=admin_headers, output_file="test_invite_revoke.json")
+check(resp.status_code == 204, "Invitation revoked (204).", f"Unexpected status: {resp.status_code}.")
+resp = accept(revoked_token, "Revoked", password, "test_invite_accept_revoked.json")
+check(resp.status_code == 401, "Revoked invitation cannot be accepted (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/invitations/{revoked_id}", method="DELETE", headers=admin_headers, output_file="test_invite_revoke_again.json")
+check(resp.status_code == 404, "Revoking twice returns 404.", f"Unexpected status: {resp.status_code}.")
+
+# 5. Organizations do not get around invite-only registration
+print(f"\n>> Step 5: Inviting new users into an organization...")
+if login.status_code != 200 or not os.environ.get("DB_FILE"):
+    print(f"{Colors.WARNING}Member login failed or DB_FILE not set, skipping.{Colors.ENDC}")
+    sys.exit(0)
+resp = send_and_print(f"{BASE_URL}/organizations", method="POST", headers=member_headers, body={"name": f"Club {timestamp}"}, output_file="test_invite_org.json")
+org_id = (resp.json() or {}).get('id')
+newcomer = f"newcomer_{timestamp}@test.com"
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/invitations", method="POST", headers=member_headers, body={"email": newcomer, "role": "member"}, output_file="test_invite_org_by_owner.json")
+check(resp.status_code == 403, "Organization owners cannot invite people without an account (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/invitations", method="POST", headers=admin_headers, body={"email": newcomer, "role": "member"}, output_file="test_invite_org_by_admin.json")
+check(resp.status_code == 201, "Holders of invitations:manage can (201).", f"Unexpected status: {resp.status_code}.")
+resp = accept(mailed_token((resp.json() or {}).get('id')), "Newcomer", password, "test_invite_org_accept.json")
+check(resp.status_code == 201, "Newcomer registered through the invitation (201).", f"Unexpected status: {resp.status_code}.")
+newcomer_id = ((resp.json() or {}).get('user') or {}).get('id')
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members", headers=member_headers, output_file="test_invite_org_members.json")
+members = {m['userId']: m['role'] for m in resp.json()} if resp.status_code == 200 else {}
+check(members.get(newcomer_id) == "member", "Newcomer joined the organization.", f"Unexpected members: {members}")
@@ -0,0 +1,135 @@
+import sys
+import os
+import time
+import sqlite3
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# Two modes, each against an API started with the matching settings:
+#   EMAIL_VERIFICATION_REQUIRED=false   python T20.auth_email_verification.py resend
+#   EMAIL_VERIFICATION_REQUIRED=true    python T20.auth_email_verification.py required
+#     (with EMAIL_VERIFICATION_RESEND_COOLDOWN_SECONDS=1 for the resend steps)
+# Set DB_FILE to the SQLite database of the API to pick up the mailed tokens.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def mailed_tokens(user_id):
+    db_file = os.environ.get("DB_FILE")
+    if not db_file:
+        return None
+    with sqlite3.connect(db_file) as conn:
+        rows = conn.execute("SELECT token FROM tokens WHERE user_id = ? AND type = 'verifyEmail' ORDER BY id", (user_id,)).fetchall()
+    return [row[0] for row in rows]
+
+def login(output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=output)
+
+def retry_after(resp):
+    return int(resp.result_dict.get("response", {}).get("headers", {}).get("Retry-After", "0"))
+
+def resend(headers, output):
+    return send_and_print(f"{BASE_URL}/auth/send-verification-email", method="POST", headers=headers, output_file=output)
+
+def resend_by_email(address, output):
+    return send_and_print(f"{BASE_URL}/auth/resend-verification-email", method="POST", body={"email": address}, output_file=output)
+
+def verify(token, output):
+    return send_and_print(f"{BASE_URL}/auth/verify-email?token={token}", method="POST", output_file=output)
+
+mode = sys.argv[1] if len(sys.argv) > 1 else "resend"
+timestamp = int(time.time())
+email = f"verify_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+print(f"\n{Colors.BOLD}=== TEST: EMAIL VERIFICATION ({mode}) ==={Colors.ENDC}")
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Verifier", "email": email, "password": password,
+}, output_file="test_verify_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+tokens = reg.json().get('tokens')
+
+if mode == "resend":
+    headers = {"Authorization": f"Bearer {tokens['access']['token']}"}
+
+    # 1. The link goes to the user's address, at most once per cooldown
+    print(f"\n>> Step 1: Requesting verification emails...")
+    resp = resend(headers, "test_verify_resend_1.json")
+    check(resp.status_code == 204, "Verification email sent (204).", f"Unexpected status: {resp.status_code}.")
+    resp = resend(headers, "test_verify_resend_2.json")
+    wait = retry_after(resp)
+    check(resp.status_code == 429 and wait >= 1, f"Resend throttled (429, Retry-After {wait}s).", f"Unexpected status: {resp.status_code}.")
+
+    sent = mailed_tokens(user_id)
+    if sent is None:
+        print(f"{Colors.WARNING}DB_FILE not set, skipping verification.{Colors.ENDC}")
+        sys.exit(0)
+    check(len(sent) == 1, "Only one link was issued.", f"Unexpected links: {len(sent)}")
+
+    # 2. Verifying
+    print(f"\n>> Step 2: Verifying the email...")
+    check(verify(sent[0], "test_verify_email.json").status_code == 204, "Email verified (204).", "Verification failed.")
+    resp = send_and_print(f"{BASE_URL}/users/me", headers=headers, output_file="test_verify_me.json")
+    check((resp.json() or {}).get('isEmailVerified') is True, "User is marked verified.", "User still unverified.")
+    resp = resend(headers, "test_verify_resend_verified.json")
+    check(resp.status_code == 400, "No link for a verified email (400).", f"Unexpected status: {resp.status_code}.")
+    sys.exit(0)
+
+# 1. Registration signs nobody in
+print(f"\n>> Step 1: Registering while verification is required...")
+check(tokens is None, "No tokens before verification.", "Registration returned tokens.")
+check("resend-verification-email" in (reg.json().get('message') or ""), "Registration points to the resend endpoint.", "No resend hint.")
+check(reg.json().get('user', {}).get('status') == "pending", "Account is pending.", f"Unexpected status: {reg.json().get('user', {}).get('status')}")
+sent = mailed_tokens(user_id)
+if sent is not None:
+    check(len(sent) == 1, "Verification email sent on registration.", f"Unexpected links: {len(sent)}")
+
+# 2. Login is refused until verified
+print(f"\n>> Step 2: Logging in unverified...")
+resp = login("test_verify_login_1.json")
+check(resp.status_code == 403 and "not verified" in ((resp.json() or {}).get('message') or ""), "Login refused (403).", f"Unexpected status: {resp.status_code}.")
+time.sleep(1.1)
+resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": "wrong-password"}, output_file="test_verify_login_wrong.json")
+check(resp.status_code == 401, "Wrong password still 401.", f"Unexpected status: {resp.status_code}.")
+if sent is None:
+    print(f"{Colors.WARNING}DB_FILE not set, skipping verification.{Colors.ENDC}")
+    sys.exit(0)
+check(len(mailed_tokens(user_id)) == 1, "Refused logins respect the resend cooldown.", "Another link was mailed within the cooldown.")
+
+# 3. A new link without signing in, answered the same for unknown addresses
+print(f"\n>> Step 3: Asking for a new link by email...")
+known = resend_by_email(email, "test_verify_resend_email_known.json")
+unknown = resend_by_email(f"nobody_{timestamp}@test.com", "test_verify_resend_email_unknown.json")
+check(known.status_code == 200 and unknown.status_code == 200 and known.json() == unknown.json(),
+      "Same answer for known and unknown emails (200).", f"Answers differ: {known.status_code} / {unknown.status_code}.")
+resp = resend_by_email(email, "test_verify_resend_email_again.json")
+check(resp.status_code == 429 and retry_after(resp) >= 1, "Repeated request throttled (429).", f"Unexpected status: {resp.status_code}.")
+for _ in range(20):
+    sent = mailed_tokens(user_id)
+    if len(sent) == 2:
+        break
+    time.sleep(0.1)
+check(len(sent) == 2, "A new link was mailed to the account.", f"Unexpected links: {len(sent)}")
+
+# 4. Verifying lets the user in
+print(f"\n>> Step 4: Verifying and logging in...")
+check(verify(sent[-1], "test_verify_email.json").status_code == 204, "Email verified (204).", "Verification failed.")
+time.sleep(2.1)
+resp = login("test_verify_login_2.json")
+check(resp.status_code == 200, "Login allowed once verified (200).", f"Unexpected status: {resp.status_code}.")
+check((resp.json() or {}).get('user', {}).get('status') == "active", "Verification activated the account.", f"Unexpected status: {(resp.json() or {}).get('user', {}).get('status')}")
@@ -0,0 +1,155 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def admin(path, method="GET", body=None, output="test_rbac.json"):
+    return send_and_print(f"{BASE_URL}{path}", method=method, headers=admin_headers, body=body, output_file=output)
+
+def create_user(name, role, output):
+    return admin("/users", "POST", {
+        "name": name, "email": f"{name.lower()}_{timestamp}@test.com", "password": password, "role": role,
+    }, output)
+
+def login(name, output):
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": f"{name.lower()}_{timestamp}@test.com", "password": password}, output_file=output)
+    return {"Authorization": f"Bearer {resp.json()['tokens']['access']['token']}"} if resp.status_code == 200 else None
+
+print(f"\n{Colors.BOLD}=== TEST: ROLES AND PERMISSIONS ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+password = "Correct-horse-battery-9"
+role_name = f"support-{timestamp}"
+permission_name = f"reports-{timestamp}:read"
+
+# 1. Built-in roles and permissions
+print(f"\n>> Step 1: Listing the built-in roles and permissions...")
+resp = admin("/roles", output="test_rbac_roles.json")
+roles = {r['name']: r for r in (resp.json() or [])}
+check(resp.status_code == 200 and roles.get('admin', {}).get('builtIn') and 'user' in roles, "admin and user roles exist.", f"Unexpected roles: {list(roles)}")
+admin_permissions = [p['name'] for p in roles.get('admin', {}).get('permissions', [])]
+check("users:delete" in admin_permissions and "roles:manage" in admin_permissions, "admin holds the built-in permissions.", f"Unexpected permissions: {admin_permissions}")
+resp = admin("/permissions", output="test_rbac_permissions.json")
+builtin = [p for p in (resp.json() or []) if p['builtIn']]
+check(len(builtin) >= 10, f"{len(builtin)} built-in permissions listed.", "Built-in permissions missing.")
+
+# 2. Custom permissions and roles
+print(f"\n>> Step 2: Creating a permission and a role...")
+resp = admin("/permissions", "POST", {"name": "Not A Name"}, "test_rbac_permission_bad.json")
+check(resp.status_code == 400, "Malformed permission name rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = admin("/permissions", "POST", {"name": permission_name, "description": "Read reports"}, "test_rbac_permission.json")
+permission_id = (resp.json() or {}).get('id')
+check(resp.status_code == 201, "Permission created (201).", f"Unexpected status: {resp.status_code}.")
+resp = admin("/permissions", "POST", {"name": permission_name}, "test_rbac_permission_dup.json")
+check(resp.status_code == 400, "Duplicate permission rejected (400).", f"Unexpected status: {resp.status_code}.")
+
+resp = admin("/roles", "POST", {"name": role_name, "permissions": ["users:read", "nope:nope"]}, "test_rbac_role_bad.json")
+check(resp.status_code == 400, "Role with an unknown permission rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = admin("/roles", "POST", {"name": role_name, "description": "Support staff", "permissions": ["users:read", permission_name]}, "test_rbac_role.json")
+role = resp.json() or {}
+role_id = role.get('id')
+check(resp.status_code == 201 and sorted(p['name'] for p in role.get('permissions', [])) == sorted(["users:read", permission_name]),
+      "Role created with its permissions (201).", f"Unexpected response: {resp.status_code} {role}")
+
+# 3. Users with the role get its permissions, and only those
+print(f"\n>> Step 3: Checking permissions of a support user...")
+resp = create_user("Bogus", "no-such-role", "test_rbac_user_bad_role.json")
+check(resp.status_code == 400, "User with an unknown role rejected (400).", f"Unexpected status: {resp.status_code}.")
+resp = create_user("Agent", role_name, "test_rbac_user.json")
+agent_id = (resp.json() or {}).get('id')
+check(resp.status_code == 201 and (resp.json() or {}).get('role') == role_name, "User created with the custom role (201).", f"Unexpected status: {resp.status_code}.")
+victim_id = (create_user("Victim", "user", "test_rbac_victim.json").json() or {}).get('id')
+
+agent = login("Agent", "test_rbac_agent_login.json")
+if not agent:
+    print(f"{Colors.FAIL}Agent could not log in.{Colors.ENDC}")
+    sys.exit(1)
+resp = send_and_print(f"{BASE_URL}/users", headers=agent, output_file="test_rbac_agent_list.json")
+check(resp.status_code == 200, "users:read lets the agent list users (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{victim_id}", method="DELETE", headers=agent, output_file="test_rbac_agent_delete.json")
+check(resp.status_code == 403, "Without users:delete the agent cannot delete (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/roles", headers=agent, output_file="test_rbac_agent_roles.json")
+check(resp.status_code == 403, "Without roles:manage the agent cannot see roles (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{agent_id}/roles", headers=agent, output_file="test_rbac_agent_own_roles.json")
+check(resp.status_code == 200 and [r['name'] for r in resp.json()] == [role_name], "Agent reads its own roles (200).", f"Unexpected response: {resp.status_code}")
+
+# 4. Changing a role's permissions applies to new tokens
+print(f"\n>> Step 4: Granting users:delete to the role...")
+resp = admin(f"/roles/{role_id}", "PATCH", {"permissions": ["users:read", "users:delete", "users:create", "invitations:manage"]}, "test_rbac_role_update.json")
+check(resp.status_code == 200 and "users:delete" in [p['name'] for p in resp.json().get('permissions', [])], "Role permissions replaced (200).", f"Unexpected status: {resp.status_code}.")
+# Tokens issued before the change are stale, see T22
+agent = login("Agent", "test_rbac_agent_login_2.json")
+resp = send_and_print(f"{BASE_URL}/users/{victim_id}", method="DELETE", headers=agent, output_file="test_rbac_agent_delete_2.json")
+check(resp.status_code == 204, "Agent can now delete users (204).", f"Unexpected status: {resp.status_code}.")
+
+# 5. Several roles per user
+print(f"\n>> Step 5: Assigning roles to a user...")
+resp = send_and_print(f"{BASE_URL}/users/{agent_id}/roles", method="PUT", headers=agent, body={"roles": ["admin"]}, output_file="test_rbac_agent_escalate.json")
+check(resp.status_code == 403, "Agent cannot change roles (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=agent, body={
+    "name": "Sidekick", "email": f"sidekick_{timestamp}@test.com", "password": password, "role": "admin",
+}, output_file="test_rbac_agent_create_admin.json")
+check(resp.status_code == 403, "Agent cannot create an admin without users:roles (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/invitations", method="POST", headers=agent, body={"email": f"sidekick_{timestamp}@test.com", "role": "admin"}, output_file="test_rbac_agent_invite_admin.json")
+check(resp.status_code == 403, "Agent cannot invite an admin without users:roles (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=agent, body={
+    "name": "Sidekick", "email": f"sidekick_{timestamp}@test.com", "password": password, "role": "user",
+}, output_file="test_rbac_agent_create_user.json")
+check(resp.status_code == 201 and (resp.json() or {}).get('role') == "user", "Agent creates users with the default role (201).", f"Unexpected status: {resp.status_code}.")
+sidekick_id = (resp.json() or {}).get('id')
+managers = f"role-managers-{timestamp}"
+admin("/roles", "POST", {"name": managers, "permissions": ["users:read", "users:roles"]}, "test_rbac_managers_role.json")
+create_user("Manager", managers, "test_rbac_manager.json")
+manager = login("Manager", "test_rbac_manager_login.json")
+resp = send_and_print(f"{BASE_URL}/users/{sidekick_id}/roles", method="PUT", headers=manager, body={"roles": ["admin"]}, output_file="test_rbac_manager_admin.json")
+check(resp.status_code == 403, "users:roles alone cannot assign admin (403).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{sidekick_id}/roles", method="PUT", headers=manager, body={"roles": [role_name]}, output_file="test_rbac_manager_custom.json")
+check(resp.status_code == 403, "Nor a role with permissions it does not hold (403).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{sidekick_id}/roles", method="PUT", headers=manager, body={"roles": ["user", managers]}, output_file="test_rbac_manager_own.json")
+check(resp.status_code == 200, "It assigns roles within its permissions (200).", f"Unexpected status: {resp.status_code}.")
+resp = admin(f"/users?role={managers}", output="test_rbac_role_filter.json")
+ids = [u['id'] for u in (resp.json() or {}).get('results', [])]
+check(sidekick_id in ids, "Role filter lists holders whose main role is another (200).", f"Unexpected users: {ids}")
+resp = admin(f"/users/{agent_id}/roles", "PUT", {"roles": ["user", role_name]}, "test_rbac_assign.json")
+check(resp.status_code == 200 and sorted(r['name'] for r in resp.json()) == sorted(["user", role_name]), "User has two roles (200).", f"Unexpected response: {resp.status_code}")
+resp = admin(f"/users/{agent_id}/roles", "PUT", {"roles": ["ghost"]}, "test_rbac_assign_bad.json")
+check(resp.status_code == 400, "Unknown role cannot be assigned (400).", f"Unexpected status: {resp.status_code}.")
+
+resp = admin(f"/roles/{role_id}", "DELETE", output="test_rbac_role_delete_used.json")
+check(resp.status_code == 400, "Assigned role cannot be deleted (400).", f"Unexpected status: {resp.status_code}.")
+resp = admin(f"/users/{agent_id}/roles", "PUT", {"roles": ["user"]}, "test_rbac_unassign.json")
+check(resp.status_code == 200, "Custom role taken away (200).", f"Unexpected status: {resp.status_code}.")
+resp = admin(f"/users/{agent_id}", output="test_rbac_agent_user.json")
+check((resp.json() or {}).get('role') == "user", "Main role follows the remaining roles.", f"Unexpected role: {(resp.json() or {}).get('role')}")
+agent = login("Agent", "test_rbac_agent_login_3.json")
+resp = send_and_print(f"{BASE_URL}/users", headers=agent, output_file="test_rbac_agent_list_2.json")
+check(resp.status_code == 403, "Agent lost users:read (403).", f"Unexpected status: {resp.status_code}.")
+
+# 6. Deleting
+print(f"\n>> Step 6: Deleting roles and permissions...")
+check(admin(f"/roles/{role_id}", "DELETE", output="test_rbac_role_delete.json").status_code == 204, "Role deleted (204).", "Role could not be deleted.")
+check(admin(f"/roles/{role_id}", output="test_rbac_role_gone.json").status_code == 404, "Deleted role not found (404).", "Deleted role still found.")
+check(admin(f"/roles/{roles['admin']['id']}", "DELETE", output="test_rbac_role_delete_admin.json").status_code == 400, "Built-in role cannot be deleted (400).", "Built-in role deleted.")
+check(admin(f"/permissions/{builtin[0]['id']}", "DELETE", output="test_rbac_permission_delete_builtin.json").status_code == 400, "Built-in permission cannot be deleted (400).", "Built-in permission deleted.")
+check(admin(f"/permissions/{permission_id}", "DELETE", output="test_rbac_permission_delete.json").status_code == 204, "Permission deleted (204).", "Permission could not be deleted.")
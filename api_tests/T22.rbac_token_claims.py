@@ -0,0 +1,111 @@
+import sys
+import os
+import time
+import json
+import base64
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def jwt_claims(token):
+    payload = token.split(".")[1]
+    return json.loads(base64.urlsafe_b64decode(payload + "=" * (-len(payload) % 4)))
+
+def bearer(tokens):
+    return {"Authorization": f"Bearer {tokens['access']['token']}"}
+
+def refresh(tokens, output):
+    resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": tokens['refresh']['token']}, output_file=output)
+    return resp.json() if resp.status_code == 200 else None
+
+print(f"\n{Colors.BOLD}=== TEST: PERMISSIONS IN ACCESS TOKENS ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+
+timestamp = int(time.time())
+email = f"claims_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+role_name = f"auditor-{timestamp}"
+
+# 1. Claims of an admin token
+print(f"\n>> Step 1: Reading the admin token...")
+claims = jwt_claims(admin_token)
+check("admin" in claims.get('roles', []) and "users:delete" in claims.get('permissions', []) and claims.get('pv', 0) >= 1,
+      "Admin token carries its roles, permissions and version.", f"Unexpected claims: {claims}")
+
+# 2. A plain user
+print(f"\n>> Step 2: Signing in a plain user...")
+resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=admin_headers, body={"name": "Claims", "email": email, "password": password, "role": "user"}, output_file="test_claims_user.json")
+user_id = (resp.json() or {}).get('id')
+resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file="test_claims_login.json")
+if resp.status_code != 200:
+    print(f"{Colors.FAIL}User could not log in.{Colors.ENDC}")
+    sys.exit(1)
+tokens = resp.json()['tokens']
+claims = jwt_claims(tokens['access']['token'])
+check(claims.get('roles') == ["user"] and not claims.get('permissions'), "User token has the user role and no permissions.", f"Unexpected claims: {claims}")
+check('roles' not in jwt_claims(tokens['refresh']['token']), "Refresh token carries no grants.", "Refresh token carries grants.")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(tokens), output_file="test_claims_list_denied.json")
+check(resp.status_code == 403, "User cannot list users (403).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/api-keys", method="POST", headers=bearer(tokens), body={"name": "Claims"}, output_file="test_claims_api_key.json")
+api_key = (resp.json() or {}).get('key')
+check(resp.status_code == 201, "User created an API key (201).", f"Unexpected status: {resp.status_code}.")
+
+# 3. A role change makes the token stale
+print(f"\n>> Step 3: Granting a role...")
+resp = send_and_print(f"{BASE_URL}/roles", method="POST", headers=admin_headers, body={"name": role_name, "permissions": ["users:read"]}, output_file="test_claims_role.json")
+role_id = (resp.json() or {}).get('id')
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/roles", method="PUT", headers=admin_headers, body={"roles": ["user", role_name]}, output_file="test_claims_assign.json")
+check(resp.status_code == 200, "Role granted (200).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/users/me", headers=bearer(tokens), output_file="test_claims_stale.json")
+check(resp.status_code == 401, "Token issued before the change is stale (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers={"X-API-Key": api_key}, output_file="test_claims_api_key_list.json")
+check(resp.status_code == 200, "API key gets the new permission at once (200).", f"Unexpected status: {resp.status_code}.")
+
+tokens = refresh(tokens, "test_claims_refresh.json")
+check(tokens is not None, "Refresh still works.", "Refresh failed.")
+if not tokens:
+    sys.exit(1)
+claims = jwt_claims(tokens['access']['token'])
+check(sorted(claims.get('roles', [])) == sorted(["user", role_name]) and claims.get('permissions') == ["users:read"],
+      "Refreshed token carries the new grants.", f"Unexpected claims: {claims}")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(tokens), output_file="test_claims_list.json")
+check(resp.status_code == 200, "Refreshed token lists users (200).", f"Unexpected status: {resp.status_code}.")
+
+# 4. Changing the permissions of the role does too
+print(f"\n>> Step 4: Emptying the role...")
+resp = send_and_print(f"{BASE_URL}/roles/{role_id}", method="PATCH", headers=admin_headers, body={"permissions": []}, output_file="test_claims_role_update.json")
+check(resp.status_code == 200, "Role emptied (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(tokens), output_file="test_claims_stale_2.json")
+check(resp.status_code == 401, "Token is stale again (401).", f"Unexpected status: {resp.status_code}.")
+tokens = refresh(tokens, "test_claims_refresh_2.json")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(tokens), output_file="test_claims_list_denied_2.json")
+check(resp.status_code == 403, "Permission gone after refresh (403).", f"Unexpected status: {resp.status_code}.")
+
+# 5. Other users are not affected
+print(f"\n>> Step 5: Checking the admin token...")
+resp = send_and_print(f"{BASE_URL}/users", headers=admin_headers, output_file="test_claims_admin_list.json")
+check(resp.status_code == 200, "Admin token still valid (200).", f"Unexpected status: {resp.status_code}.")
+
+# Cleanup
+send_and_print(f"{BASE_URL}/users/{user_id}/roles", method="PUT", headers=admin_headers, body={"roles": ["user"]}, output_file="test_claims_cleanup.json")
+send_and_print(f"{BASE_URL}/roles/{role_id}", method="DELETE", headers=admin_headers, output_file="test_claims_role_delete.json")
@@ -0,0 +1,163 @@
+import sys
+import os
+import time
+import json
+import sqlite3
+import hashlib
+import secrets
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def authz_check(body, output):
+    resp = send_and_print(f"{BASE_URL}/authz/check", method="POST", headers=admin_headers, body=body, output_file=output)
+    return resp.json() if resp.status_code == 200 else {}
+
+def create_user(name, role):
+    email = f"{name.lower()}_{timestamp}@test.com"
+    resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=admin_headers, body={"name": name, "email": email, "password": password, "role": role}, output_file=f"test_authz_{name.lower()}.json")
+    user_id = resp.json()['id']
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=f"test_authz_{name.lower()}_login.json")
+    return user_id, {"Authorization": f"Bearer {resp.json()['tokens']['access']['token']}"}
+
+def login(name, output):
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": f"{name.lower()}_{timestamp}@test.com", "password": password}, output_file=output)
+    return resp.json()['tokens']
+
+def mailed_token(invitation_id):
+    token = secrets.token_urlsafe(32)
+    with sqlite3.connect(db_file) as conn:
+        conn.execute("UPDATE invitations SET token = ? WHERE id = ?", (hashlib.sha256(token.encode()).hexdigest(), invitation_id))
+    return token
+
+def join(owner_headers, organization_id, name, headers):
+    resp = send_and_print(f"{BASE_URL}/organizations/{organization_id}/invitations", method="POST", headers=owner_headers,
+                          body={"email": f"{name.lower()}_{timestamp}@test.com", "role": "member"}, output_file=f"test_authz_invite_{name.lower()}.json")
+    if resp.status_code != 201:
+        return False
+    token = mailed_token(resp.json()['id'])
+    resp = send_and_print(f"{BASE_URL}/organizations/invitations/accept?token={token}", method="POST", headers=headers, output_file=f"test_authz_accept_{name.lower()}.json")
+    return resp.status_code == 200
+
+def write_policy(document):
+    with open(policy_file, "w") as f:
+        f.write(document if isinstance(document, str) else json.dumps(document))
+    # The server checks the file every POLICY_RELOAD_SECONDS; mtimes may have a one second granularity
+    time.sleep(2.5)
+
+print(f"\n{Colors.BOLD}=== TEST: ATTRIBUTE-BASED ACCESS POLICIES ==={Colors.ENDC}")
+# Steps 1-3 pass with the built-in rules or policies.example.json. For step 4, start the server with
+# POLICY_FILE set to a copy of policies.example.json and POLICY_RELOAD_SECONDS=1, and run this script
+# with the same POLICY_FILE and with DB_FILE set to the SQLite database of the API, to accept the
+# mailed organization invitations.
+policy_file = os.environ.get("POLICY_FILE")
+db_file = os.environ.get("DB_FILE")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+password = "Correct-horse-battery-9"
+
+# 1. Debugging endpoint
+print(f"\n>> Step 1: Asking the policy for decisions...")
+decision = authz_check({"subject": {"id": "a", "permissions": ["users:read"]}, "action": "users:read"}, "test_authz_check_permission.json")
+check(decision.get('allowed') and decision.get('rule') == "role-permissions", "Permission allows its action.", f"Unexpected decision: {decision}")
+decision = authz_check({"subject": {"id": "a"}, "action": "users:delete", "resource": {"type": "user", "id": "a"}}, "test_authz_check_self_delete.json")
+check(decision and not decision.get('allowed'), f"Nothing allows deleting oneself ({decision.get('reason')}).", f"Unexpected decision: {decision}")
+decision = authz_check({"subject": {"id": "a"}, "action": "users:update", "resource": {"id": "a"}, "request": {"fields": ["name"]}}, "test_authz_check_name.json")
+check(decision.get('allowed') and decision.get('rule') == "self-update-name", "Users may update their own name.", f"Unexpected decision: {decision}")
+decision = authz_check({"subject": {"id": "a"}, "action": "users:update", "resource": {"id": "a"}, "request": {"fields": ["email", "name"]}}, "test_authz_check_email.json")
+check(decision and not decision.get('allowed'), "Users may not update their own email.", f"Unexpected decision: {decision}")
+resp = send_and_print(f"{BASE_URL}/authz/check", method="POST", headers=admin_headers, body={"subject": {"id": "a"}}, output_file="test_authz_check_invalid.json")
+check(resp.status_code == 400, "Action is required (400).", f"Unexpected status: {resp.status_code}.")
+
+# 2. Enforcement on the user routes
+print(f"\n>> Step 2: Enforcing the policy...")
+user_id, user_headers = create_user("Member", "user")
+other_id, _ = create_user("Neighbour", "user")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"name": "Renamed"}, output_file="test_authz_self_name.json")
+check(resp.status_code == 200 and resp.json().get('name') == "Renamed", "User renamed itself (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"name": "Renamed", "email": f"stolen_{timestamp}@test.com"}, output_file="test_authz_self_email.json")
+check(resp.status_code == 403, "User cannot change its own email here (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"Email": f"stolen_{timestamp}@test.com"}, output_file="test_authz_self_email_case.json")
+check(resp.status_code == 403, "Field names are matched whatever their case (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"name": "Renamed", "padding": "x" * (1 << 20), "email": f"stolen_{timestamp}@test.com"}, output_file="test_authz_self_oversize.json")
+check(resp.status_code == 413, "Oversize bodies are refused, not evaluated in part (413).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{other_id}", method="PATCH", headers=user_headers, body={"name": "Hijacked"}, output_file="test_authz_other_name.json")
+check(resp.status_code == 403, "User cannot rename someone else (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=user_headers, output_file="test_authz_self_read.json")
+check(resp.status_code == 200, "User reads itself (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{other_id}", headers=user_headers, output_file="test_authz_other_read.json")
+check(resp.status_code == 403, "User cannot read someone else (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{other_id}", method="PATCH", headers=admin_headers, body={"name": "Renamed by admin"}, output_file="test_authz_admin_name.json")
+check(resp.status_code == 200, "Admin renames anyone (200).", f"Unexpected status: {resp.status_code}.")
+
+# 3. Only admins debug the policy
+print(f"\n>> Step 3: Checking access to the debugging endpoint...")
+resp = send_and_print(f"{BASE_URL}/authz/check", method="POST", headers=user_headers, body={"action": "users:read"}, output_file="test_authz_check_user.json")
+check(resp.status_code == 403, "Users cannot call /authz/check (403).", f"Unexpected status: {resp.status_code}.")
+
+# 4. Hot reload
+if not policy_file or not db_file:
+    print(f"\n{Colors.WARNING}POLICY_FILE or DB_FILE not set, skipping the policy file checks.{Colors.ENDC}")
+    sys.exit(0)
+
+print(f"\n>> Step 4: Applying the example policy file...")
+owner_id, owner_headers = create_user("Owner", "user")
+customer_id, customer_headers = create_user("Customer", "user")
+boss_id, boss_headers = create_user("Boss", "admin")
+support_id, _ = create_user("Helpdesk", "user")
+send_and_print(f"{BASE_URL}/roles", method="POST", headers=admin_headers, body={"name": "support", "description": "Support staff"}, output_file="test_authz_support_role.json")
+send_and_print(f"{BASE_URL}/users/{support_id}/roles", method="PUT", headers=admin_headers, body={"roles": ["user", "support"]}, output_file="test_authz_support_assign.json")
+support_tokens = login("Helpdesk", "test_authz_support_login.json")
+
+# Support staff, a customer and an admin are plain members of the same organization
+resp = send_and_print(f"{BASE_URL}/organizations", method="POST", headers=owner_headers, body={"name": f"Helpdesk {timestamp}"}, output_file="test_authz_org.json")
+org_id = (resp.json() or {}).get('id')
+joined = [join(owner_headers, org_id, name, headers) for name, headers in [
+    ("Customer", customer_headers), ("Boss", boss_headers), ("Helpdesk", {"Authorization": f"Bearer {support_tokens['access']['token']}"})]]
+check(resp.status_code == 201 and all(joined), "Customer, admin and support joined the organization.", f"Joining failed: {resp.status_code} {joined}")
+resp = send_and_print(f"{BASE_URL}/auth/switch-organization", method="POST", body={"refreshToken": support_tokens['refresh']['token'], "organizationId": org_id}, output_file="test_authz_support_switch.json")
+support_headers = {"Authorization": f"Bearer {resp.json()['access']['token']}"} if resp.status_code == 200 else {}
+
+resp = send_and_print(f"{BASE_URL}/users/{customer_id}", headers=support_headers, output_file="test_authz_support_read.json")
+check(resp.status_code == 200, "Support reads a user of its organization (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{boss_id}", headers=support_headers, output_file="test_authz_support_read_admin.json")
+check(resp.status_code == 403, "Support cannot read an admin of its organization (403).", f"Unexpected status: {resp.status_code}.")
+check((resp.json() or {}).get('message') == "Forbidden", "The denial does not name the policy's rules.", f"Unexpected message: {(resp.json() or {}).get('message')}")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=support_headers, output_file="test_authz_support_read_outsider.json")
+check(resp.status_code == 403, "Support cannot read users outside its organization (403).", f"Unexpected status: {resp.status_code}.")
+
+with open(policy_file) as f:
+    original = f.read()
+document = json.loads(original)
+document['rules'].append({"name": "no-self-updates", "effect": "deny", "actions": ["users:update"],
+                          "conditions": [{"attribute": "resource.id", "operator": "eq", "valueFrom": "subject.id"}]})
+write_policy(document)
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"name": "Again"}, output_file="test_authz_reloaded.json")
+check(resp.status_code == 403, "Added deny rule applies (403).", f"Unexpected status: {resp.status_code}.")
+
+write_policy('{"rules": [{"name": "broken", "effect": "maybe", "actions": ["*"]}]}')
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers=user_headers, output_file="test_authz_invalid_policy.json")
+check(resp.status_code == 200, "Invalid file is refused, the last policy stays (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", method="PATCH", headers=user_headers, body={"name": "Again"}, output_file="test_authz_invalid_policy_self.json")
+check(resp.status_code == 403, "Last valid policy still enforced (403).", f"Unexpected status: {resp.status_code}.")
+
+# Cleanup: restore the policy file
+write_policy(original)
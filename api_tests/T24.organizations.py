@@ -0,0 +1,181 @@
+import sys
+import os
+import time
+import json
+import base64
+import sqlite3
+import hashlib
+import secrets
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Set DB_FILE to the SQLite database of the API to pick up the mailed invitation tokens.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def jwt_claims(token):
+    payload = token.split(".")[1]
+    return json.loads(base64.urlsafe_b64decode(payload + "=" * (-len(payload) % 4)))
+
+def bearer(tokens):
+    return {"Authorization": f"Bearer {tokens['access']['token']}"}
+
+# Only a hash of the mailed token is stored, so the test swaps in the hash of a token of its own
+def mailed_token(invitation_id):
+    token = secrets.token_urlsafe(32)
+    with sqlite3.connect(db_file) as conn:
+        row = conn.execute("SELECT token FROM invitations WHERE id = ?", (invitation_id,)).fetchone()
+        if not row:
+            return None
+        if len(row[0]) != 64:
+            print(f"{Colors.FAIL}[FAIL] Invitation token stored in the clear.{Colors.ENDC}")
+            return None
+        conn.execute("UPDATE invitations SET token = ? WHERE id = ?", (hashlib.sha256(token.encode()).hexdigest(), invitation_id))
+    return token
+
+def create_user(name):
+    email = f"{name.lower()}_{timestamp}@test.com"
+    resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=admin_headers, body={"name": name, "email": email, "password": password, "role": "user"}, output_file=f"test_org_{name.lower()}.json")
+    user_id = resp.json()['id']
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=f"test_org_{name.lower()}_login.json")
+    return user_id, email, resp.json()['tokens']
+
+def switch(tokens, organization_id, output):
+    resp = send_and_print(f"{BASE_URL}/auth/switch-organization", method="POST", body={"refreshToken": tokens['refresh']['token'], "organizationId": organization_id}, output_file=output)
+    return resp, (resp.json() if resp.status_code == 200 else None)
+
+def invite(headers, email, role, output):
+    return send_and_print(f"{BASE_URL}/organizations/{org_id}/invitations", method="POST", headers=headers, body={"email": email, "role": role}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: ORGANIZATIONS (MULTI-TENANCY) ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+db_file = os.environ.get("DB_FILE")
+if not db_file:
+    print(f"{Colors.WARNING}DB_FILE not set, the invitations cannot be accepted. Skipping.{Colors.ENDC}")
+    sys.exit(0)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+password = "Correct-horse-battery-9"
+
+owner_id, _, owner_tokens = create_user("Owner")
+alice_id, alice_email, alice_tokens = create_user("Alice")
+outsider_id, _, outsider_tokens = create_user("Outsider")
+
+# 1. Creating an organization
+print(f"\n>> Step 1: Creating an organization...")
+resp = send_and_print(f"{BASE_URL}/organizations", method="POST", headers=bearer(owner_tokens), body={"name": f"Acme {timestamp}"}, output_file="test_org_create.json")
+check(resp.status_code == 201, "Organization created (201).", f"Unexpected status: {resp.status_code}.")
+org_id = resp.json().get('id')
+resp = send_and_print(f"{BASE_URL}/organizations", method="POST", headers=bearer(owner_tokens), body={"name": f"Acme {timestamp}\r\nBcc: victim@example.com"}, output_file="test_org_create_header.json")
+check(resp.status_code == 400, "Names with line breaks are refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}", method="PATCH", headers=bearer(owner_tokens), body={"name": "A" * 101}, output_file="test_org_update_long.json")
+check(resp.status_code == 400, "Names over 100 characters are refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations", headers=bearer(owner_tokens), output_file="test_org_mine.json")
+memberships = resp.json() if resp.status_code == 200 else []
+check(len(memberships) == 1 and memberships[0]['role'] == "owner" and memberships[0]['organization']['id'] == org_id, "Creator is the owner.", f"Unexpected memberships: {memberships}")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}", headers=bearer(outsider_tokens), output_file="test_org_outsider_get.json")
+check(resp.status_code == 404, "Non-members do not see the organization (404).", f"Unexpected status: {resp.status_code}.")
+
+# 2. Invitations
+print(f"\n>> Step 2: Inviting members...")
+resp = invite(bearer(owner_tokens), alice_email, "admin", "test_org_invite_alice.json")
+check(resp.status_code == 201 and 'token' not in resp.json(), "Existing user invited as admin (201).", f"Unexpected status: {resp.status_code}.")
+alice_token = mailed_token(resp.json().get('id'))
+resp = send_and_print(f"{BASE_URL}/organizations/invitations/accept?token={alice_token}", method="POST", headers=bearer(outsider_tokens), output_file="test_org_accept_wrong_user.json")
+check(resp.status_code == 403, "Invitation of someone else refused (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/invitations/accept?token={alice_token}", method="POST", headers=bearer(alice_tokens), output_file="test_org_accept_alice.json")
+check(resp.status_code == 200 and resp.json().get('role') == "admin", "Alice joined as admin (200).", f"Unexpected status: {resp.status_code}.")
+
+bob_email = f"bob_{timestamp}@test.com"
+resp = invite(bearer(alice_tokens), bob_email, "owner", "test_org_invite_owner_by_admin.json")
+check(resp.status_code == 403, "Admins cannot invite owners (403).", f"Unexpected status: {resp.status_code}.")
+resp = invite(bearer(alice_tokens), bob_email, "member", "test_org_invite_bob.json")
+check(resp.status_code == 201, "New user invited as member (201).", f"Unexpected status: {resp.status_code}.")
+bob_token = mailed_token(resp.json().get('id'))
+resp = send_and_print(f"{BASE_URL}/auth/invitations/accept?token={bob_token}", method="POST", body={"name": "Bob", "password": password}, output_file="test_org_accept_bob.json")
+check(resp.status_code == 201, "Bob registered through the invitation (201).", f"Unexpected status: {resp.status_code}.")
+bob_id = resp.json()['user']['id']
+bob_tokens = resp.json()['tokens']
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members", headers=bearer(bob_tokens), output_file="test_org_members.json")
+members = {m['userId']: m['role'] for m in resp.json()} if resp.status_code == 200 else {}
+check(members == {owner_id: "owner", alice_id: "admin", bob_id: "member"}, "Members listed with their roles.", f"Unexpected members: {members}")
+
+# 3. Organization switcher
+print(f"\n>> Step 3: Switching organizations...")
+resp, _ = switch(outsider_tokens, org_id, "test_org_switch_outsider.json")
+check(resp.status_code == 403, "Non-members cannot switch in (403).", f"Unexpected status: {resp.status_code}.")
+resp, alice_tokens = switch(alice_tokens, org_id, "test_org_switch_alice.json")
+claims = jwt_claims(alice_tokens['access']['token']) if alice_tokens else {}
+check(claims.get('org') == org_id and claims.get('orgRole') == "admin", "Access token carries the organization and role.", f"Unexpected claims: {claims}")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": alice_tokens['refresh']['token']}, output_file="test_org_refresh.json")
+alice_tokens = resp.json()
+check(jwt_claims(alice_tokens['access']['token']).get('org') == org_id, "Refreshing stays in the organization.", "Organization lost on refresh.")
+_, bob_tokens = switch(bob_tokens, org_id, "test_org_switch_bob.json")
+
+# 4. Tenant scoping of the user list
+print(f"\n>> Step 4: Scoping users to the organization...")
+resp = send_and_print(f"{BASE_URL}/users?limit=100", headers=bearer(alice_tokens), output_file="test_org_users_admin.json")
+ids = {u['id'] for u in resp.json().get('results', [])} if resp.status_code == 200 else set()
+check(ids == {owner_id, alice_id, bob_id}, "Org admin sees only the members.", f"Unexpected users (Status: {resp.status_code}): {ids}")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(bob_tokens), output_file="test_org_users_member.json")
+check(resp.status_code == 403, "Plain members cannot list users (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(owner_tokens), output_file="test_org_users_no_org.json")
+check(resp.status_code == 403, "Outside an organization the list is refused (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users?limit=100", headers=admin_headers, output_file="test_org_users_super.json")
+check(resp.status_code == 200 and outsider_id in {u['id'] for u in resp.json()['results']}, "Super-admin keeps the global view.", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{bob_id}", headers=bearer(alice_tokens), output_file="test_org_user_member.json")
+check(resp.status_code == 200, "Org admin reads a member (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{outsider_id}", headers=bearer(alice_tokens), output_file="test_org_user_outsider.json")
+check(resp.status_code == 403, "Org admin cannot read outsiders (403).", f"Unexpected status: {resp.status_code}.")
+
+# 5. Member roles
+print(f"\n>> Step 5: Managing members...")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members/{bob_id}", method="PATCH", headers=bearer(alice_tokens), body={"role": "owner"}, output_file="test_org_promote_by_admin.json")
+check(resp.status_code == 403, "Admins cannot name owners (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members/{owner_id}", method="PATCH", headers=bearer(owner_tokens), body={"role": "member"}, output_file="test_org_last_owner.json")
+check(resp.status_code == 400, "The last owner keeps the role (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members/{bob_id}", method="PATCH", headers=bearer(alice_tokens), body={"role": "admin"}, output_file="test_org_promote_bob.json")
+check(resp.status_code == 200 and resp.json().get('role') == "admin", "Admin promoted Bob (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(bob_tokens), output_file="test_org_users_stale.json")
+check(resp.status_code == 401, "Bob's token with the old role is stale (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": bob_tokens['refresh']['token']}, output_file="test_org_refresh_bob.json")
+bob_tokens = resp.json()
+check(jwt_claims(bob_tokens['access']['token']).get('orgRole') == "admin", "Refreshed token has the new role.", "Role not updated on refresh.")
+
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members/{alice_id}", method="DELETE", headers=bearer(owner_tokens), output_file="test_org_remove_alice.json")
+check(resp.status_code == 204, "Owner removed Alice (204).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=bearer(alice_tokens), output_file="test_org_users_removed.json")
+check(resp.status_code == 401, "Removed member's token is stale (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": alice_tokens['refresh']['token']}, output_file="test_org_refresh_removed.json")
+check(resp.status_code == 200 and 'org' not in jwt_claims(resp.json()['access']['token']), "Session left the organization.", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}/members/{bob_id}", method="DELETE", headers=bearer(bob_tokens), output_file="test_org_leave.json")
+check(resp.status_code == 204, "Bob left the organization (204).", f"Unexpected status: {resp.status_code}.")
+
+# 6. Super-admins and deletion
+print(f"\n>> Step 6: Super-admin view and deletion...")
+resp = send_and_print(f"{BASE_URL}/organizations?all=true", headers=bearer(owner_tokens), output_file="test_org_all_forbidden.json")
+check(resp.status_code == 403, "Only super-admins list every organization (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations?all=true", headers=admin_headers, output_file="test_org_all.json")
+check(resp.status_code == 200 and org_id in [o['id'] for o in resp.json()], "Super-admin lists every organization.", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}", method="PATCH", headers=admin_headers, body={"name": f"Acme Corp {timestamp}"}, output_file="test_org_super_update.json")
+check(resp.status_code == 200, "Super-admin manages organizations it is not in (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}", method="DELETE", headers=bearer(owner_tokens), output_file="test_org_delete.json")
+check(resp.status_code == 204, "Owner deleted the organization (204).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/organizations/{org_id}", headers=admin_headers, output_file="test_org_deleted.json")
+check(resp.status_code == 404, "Organization is gone (404).", f"Unexpected status: {resp.status_code}.")
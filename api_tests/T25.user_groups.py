@@ -0,0 +1,147 @@
+import sys
+import os
+import time
+import json
+import base64
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def jwt_claims(token):
+    payload = token.split(".")[1]
+    return json.loads(base64.urlsafe_b64decode(payload + "=" * (-len(payload) % 4)))
+
+def login(email, output):
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=output)
+    token = resp.json()['tokens']['access']['token']
+    return token, {"Authorization": f"Bearer {token}"}
+
+def create_user(name):
+    email = f"{name.lower()}_{timestamp}@test.com"
+    resp = send_and_print(f"{BASE_URL}/users", method="POST", headers=admin_headers, body={"name": name, "email": email, "password": password, "role": "user"}, output_file=f"test_group_{name.lower()}.json")
+    return resp.json()['id'], email
+
+print(f"\n{Colors.BOLD}=== TEST: USER GROUPS ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+password = "Correct-horse-battery-9"
+group_name = f"billing-{timestamp}"
+
+# 1. Managing groups
+print(f"\n>> Step 1: Creating a group...")
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=admin_headers, body={"name": group_name, "description": "Billing team", "permissions": ["users:read"]}, output_file="test_group_create.json")
+check(resp.status_code == 201 and [p['name'] for p in resp.json().get('permissions', [])] == ["users:read"], "Group created with its permissions (201).", f"Unexpected status: {resp.status_code}.")
+group_id = resp.json().get('id')
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=admin_headers, body={"name": group_name}, output_file="test_group_duplicate.json")
+check(resp.status_code == 400, "Duplicate name refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=admin_headers, body={"name": "Billing Team"}, output_file="test_group_bad_name.json")
+check(resp.status_code == 400, "Invalid name refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=admin_headers, body={"name": f"other-{timestamp}", "permissions": ["nope:nope"]}, output_file="test_group_bad_permission.json")
+check(resp.status_code == 400, "Unknown permission refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups", headers=admin_headers, output_file="test_group_list.json")
+check(resp.status_code == 200 and group_id in [g['id'] for g in resp.json()], "Group listed.", f"Unexpected status: {resp.status_code}.")
+
+# 2. Membership grants the group's permissions
+print(f"\n>> Step 2: Adding members...")
+member_id, member_email = create_user("Biller")
+other_id, _ = create_user("Bystander")
+_, member_headers = login(member_email, "test_group_member_login.json")
+resp = send_and_print(f"{BASE_URL}/users", headers=member_headers, output_file="test_group_users_before.json")
+check(resp.status_code == 403, "Without the group, the user cannot list users (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups", headers=member_headers, output_file="test_group_list_forbidden.json")
+check(resp.status_code == 403, "Managing groups needs groups:manage (403).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members", method="POST", headers=admin_headers, body={"userIds": [member_id]}, output_file="test_group_add.json")
+check(resp.status_code == 200 and [u['id'] for u in resp.json()] == [member_id], "Member added (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members", method="POST", headers=admin_headers, body={"userIds": [member_id]}, output_file="test_group_add_again.json")
+check(resp.status_code == 200 and len(resp.json()) == 1, "Adding again keeps one membership.", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members", method="POST", headers=admin_headers, body={"userIds": ["not-a-uuid"]}, output_file="test_group_add_invalid.json")
+check(resp.status_code == 400, "Invalid user ID refused (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=member_headers, output_file="test_group_users_stale.json")
+check(resp.status_code == 401, "Token issued before joining is stale (401).", f"Unexpected status: {resp.status_code}.")
+
+member_token, member_headers = login(member_email, "test_group_member_relogin.json")
+claims = jwt_claims(member_token)
+check(group_name in claims.get('groups', []) and "users:read" in claims.get('permissions', []), "Token carries the group and its permissions.", f"Unexpected claims: {claims}")
+resp = send_and_print(f"{BASE_URL}/users", headers=member_headers, output_file="test_group_users_after.json")
+check(resp.status_code == 200, "Group permission lets the member list users (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{member_id}/groups", headers=member_headers, output_file="test_group_user_groups.json")
+check(resp.status_code == 200 and [g['name'] for g in resp.json()] == [group_name], "User's groups listed (200).", f"Unexpected status: {resp.status_code}.")
+
+# 3. Filtering users by group
+print(f"\n>> Step 3: Filtering users by group...")
+resp = send_and_print(f"{BASE_URL}/users?group={group_name}", headers=admin_headers, output_file="test_group_filter.json")
+ids = [u['id'] for u in resp.json().get('results', [])] if resp.status_code == 200 else []
+check(ids == [member_id], "Only the members are returned.", f"Unexpected users: {ids}")
+resp = send_and_print(f"{BASE_URL}/users?group=no-such-group-{timestamp}", headers=admin_headers, output_file="test_group_filter_unknown.json")
+check(resp.status_code == 200 and resp.json().get('totalResults') == 0, "Unknown group matches nobody.", f"Unexpected status: {resp.status_code}.")
+
+# 4. API keys use the current group permissions
+print(f"\n>> Step 4: API keys...")
+resp = send_and_print(f"{BASE_URL}/users/{member_id}/api-keys", method="POST", headers=member_headers, body={"name": "Reports"}, output_file="test_group_api_key.json")
+api_key = resp.json().get('key')
+resp = send_and_print(f"{BASE_URL}/users", headers={"X-API-Key": api_key}, output_file="test_group_api_key_list.json")
+check(resp.status_code == 200, "API key has the group permission (200).", f"Unexpected status: {resp.status_code}.")
+
+# 5. Changing the group's permissions
+print(f"\n>> Step 5: Revoking the group's permissions...")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}", method="PATCH", headers=admin_headers, body={"permissions": []}, output_file="test_group_clear.json")
+check(resp.status_code == 200 and resp.json().get('permissions') == [], "Permissions cleared (200).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers=member_headers, output_file="test_group_users_cleared_stale.json")
+check(resp.status_code == 401, "Member's token is stale (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users", headers={"X-API-Key": api_key}, output_file="test_group_api_key_cleared.json")
+check(resp.status_code == 403, "API key lost the permission (403).", f"Unexpected status: {resp.status_code}.")
+
+# 6. Leaving and deleting
+print(f"\n>> Step 6: Removing members and the group...")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members/{other_id}", method="DELETE", headers=admin_headers, output_file="test_group_remove_outsider.json")
+check(resp.status_code == 400, "Removing a non-member fails (400).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members/{member_id}", method="DELETE", headers=admin_headers, output_file="test_group_remove.json")
+check(resp.status_code == 204, "Member removed (204).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}/members", headers=admin_headers, output_file="test_group_members_empty.json")
+check(resp.status_code == 200 and resp.json() == [], "Group is empty.", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}", method="DELETE", headers=admin_headers, output_file="test_group_delete.json")
+check(resp.status_code == 204, "Group deleted (204).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{group_id}", headers=admin_headers, output_file="test_group_deleted.json")
+check(resp.status_code == 404, "Group is gone (404).", f"Unexpected status: {resp.status_code}.")
+
+# 7. Group managers only grant what they hold
+print(f"\n>> Step 7: Managing groups without roles:manage...")
+role_name = f"group-managers-{timestamp}"
+send_and_print(f"{BASE_URL}/roles", method="POST", headers=admin_headers, body={"name": role_name, "permissions": ["groups:manage", "users:read"]}, output_file="test_group_manager_role.json")
+organizer_id, organizer_email = create_user("Organizer")
+send_and_print(f"{BASE_URL}/users/{organizer_id}/roles", method="PUT", headers=admin_headers, body={"roles": ["user", role_name]}, output_file="test_group_manager_assign.json")
+_, organizer_headers = login(organizer_email, "test_group_manager_login.json")
+
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=organizer_headers, body={"name": f"escalate-{timestamp}", "permissions": ["users:delete"]}, output_file="test_group_manager_escalate.json")
+check(resp.status_code == 403, "Cannot grant a permission it does not hold (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=organizer_headers, body={"name": f"readers-{timestamp}", "permissions": ["users:read"]}, output_file="test_group_manager_create.json")
+check(resp.status_code == 201, "Grants a permission it holds (201).", f"Unexpected status: {resp.status_code}.")
+readers_id = (resp.json() or {}).get('id')
+resp = send_and_print(f"{BASE_URL}/groups/{readers_id}", method="PATCH", headers=organizer_headers, body={"permissions": ["roles:manage"]}, output_file="test_group_manager_update.json")
+check(resp.status_code == 403, "Cannot add such a permission later either (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/groups/{readers_id}/members", method="POST", headers=organizer_headers, body={"userIds": [other_id]}, output_file="test_group_manager_add.json")
+check(resp.status_code == 200, "Adds members to a group within its permissions (200).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/groups", method="POST", headers=admin_headers, body={"name": f"deleters-{timestamp}", "permissions": ["users:delete"]}, output_file="test_group_deleters.json")
+deleters_id = (resp.json() or {}).get('id')
+resp = send_and_print(f"{BASE_URL}/groups/{deleters_id}/members", method="POST", headers=organizer_headers, body={"userIds": [organizer_id]}, output_file="test_group_manager_join.json")
+check(resp.status_code == 403, "Cannot join a group granting more (403).", f"Unexpected status: {resp.status_code}.")
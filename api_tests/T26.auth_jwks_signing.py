@@ -0,0 +1,115 @@
+import sys
+import os
+import time
+import json
+import base64
+import hashlib
+import hmac
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def b64decode(value):
+    return base64.urlsafe_b64decode(value + "=" * (-len(value) % 4))
+
+def b64encode(data):
+    return base64.urlsafe_b64encode(data).rstrip(b"=").decode()
+
+def jwt_part(token, index):
+    return json.loads(b64decode(token.split(".")[index]))
+
+def forge(header, claims, signature=b""):
+    signing_input = b64encode(json.dumps(header).encode()) + "." + b64encode(json.dumps(claims).encode())
+    return signing_input, signing_input + "." + b64encode(signature)
+
+# RSASSA-PKCS1-v1_5 with SHA-256, enough to check the server's signature with the published key
+SHA256_PREFIX = bytes.fromhex("3031300d060960864801650304020105000420")
+
+def rs256_valid(token, jwk):
+    signing_input, signature = token.rsplit(".", 1)
+    n = int.from_bytes(b64decode(jwk['n']), "big")
+    e = int.from_bytes(b64decode(jwk['e']), "big")
+    size = (n.bit_length() + 7) // 8
+    decoded = pow(int.from_bytes(b64decode(signature), "big"), e, n).to_bytes(size, "big")
+    digest = SHA256_PREFIX + hashlib.sha256(signing_input.encode()).digest()
+    return decoded == b"\x00\x01" + b"\xff" * (size - len(digest) - 3) + b"\x00" + digest
+
+def me(token, output):
+    return send_and_print(f"{BASE_URL}/users/me", headers={"Authorization": f"Bearer {token}"}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: JWT SIGNING KEYS AND JWKS ==={Colors.ENDC}")
+
+root_url = BASE_URL.rsplit("/v1", 1)[0]
+timestamp = int(time.time())
+email = f"jwks_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Jwks User", "email": email, "password": password,
+}, output_file="test_jwks_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+access = reg.json()['tokens']['access']['token']
+header = jwt_part(access, 0)
+claims = jwt_part(access, 1)
+
+# 1. The JWKS endpoint
+print(f"\n>> Step 1: Fetching the JWKS...")
+resp = send_and_print(f"{root_url}/.well-known/jwks.json", output_file="test_jwks_keys.json")
+keys = (resp.json() or {}).get('keys') if resp.status_code == 200 else None
+check(isinstance(keys, list), "JWKS served (200).", f"Unexpected status: {resp.status_code}.")
+keys = keys or []
+check(all('d' not in k and 'k' not in k for k in keys), "No private or shared key material is published.", f"Secret material leaked: {keys}")
+
+# 2. The token header names a published key
+print(f"\n>> Step 2: Matching the token to its key (alg {header.get('alg')})...")
+if header.get('alg') == "HS256":
+    check(keys == [], "HS256 secret is never published.", f"Keys published in HS256 mode: {keys}")
+    print(f"{Colors.WARNING}Server signs with HS256; start it with JWT_ALGORITHM=RS256 or EdDSA to check public-key verification.{Colors.ENDC}")
+else:
+    jwk = next((k for k in keys if k.get('kid') == header.get('kid')), None)
+    check(jwk is not None and jwk.get('alg') == header.get('alg'), "Token kid is in the JWKS with the same alg.", f"kid {header.get('kid')} not published.")
+    if jwk and header.get('alg') == "RS256":
+        check(rs256_valid(access, jwk), "Signature verifies with the published public key.", "Signature does not verify with the JWKS key.")
+
+# 3. Forged tokens are refused
+print(f"\n>> Step 3: Presenting forged tokens...")
+resp = me(access, "test_jwks_me.json")
+check(resp.status_code == 200, "Genuine token accepted (200).", f"Unexpected status: {resp.status_code}.")
+
+_, unsigned = forge({"alg": "none", "typ": "JWT"}, claims)
+resp = me(unsigned, "test_jwks_alg_none.json")
+check(resp.status_code == 401, "alg=none token refused (401).", f"Unexpected status: {resp.status_code}.")
+
+if header.get('alg') != "HS256":
+    # Algorithm confusion: an HMAC token naming an asymmetric key must not verify
+    signing_input, _ = forge({"alg": "HS256", "typ": "JWT", "kid": header.get('kid', "")}, claims)
+    confused = signing_input + "." + b64encode(hmac.new(json.dumps(keys).encode(), signing_input.encode(), hashlib.sha256).digest())
+    resp = me(confused, "test_jwks_alg_confusion.json")
+    check(resp.status_code == 401, "HS256 token naming an asymmetric key refused (401).", f"Unexpected status: {resp.status_code}.")
+
+unknown = dict(header, kid="unknown-kid")
+signing_input, _ = forge(unknown, claims)
+resp = me(signing_input + "." + access.rsplit(".", 1)[1], "test_jwks_unknown_kid.json")
+check(resp.status_code == 401, "Unknown kid refused (401).", f"Unexpected status: {resp.status_code}.")
+
+tampered = dict(claims, sub="00000000-0000-0000-0000-000000000000")
+signing_input, _ = forge(header, tampered)
+resp = me(signing_input + "." + access.rsplit(".", 1)[1], "test_jwks_tampered.json")
+check(resp.status_code == 401, "Tampered claims refused (401).", f"Unexpected status: {resp.status_code}.")
+
+print(f"\n{Colors.BOLD}=== JWKS TEST COMPLETE ==={Colors.ENDC}")
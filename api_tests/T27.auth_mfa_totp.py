@@ -0,0 +1,124 @@
+import sys
+import os
+import time
+import base64
+import hashlib
+import hmac
+import struct
+import uuid
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+# RFC 6238 with the server's parameters: SHA-1, 6 digits, 30 second steps
+def totp(secret, step):
+    key = base64.b32decode(secret + "=" * (-len(secret) % 8))
+    digest = hmac.new(key, struct.pack(">Q", step), hashlib.sha1).digest()
+    offset = digest[-1] & 0x0F
+    value = struct.unpack(">I", digest[offset:offset + 4])[0] & 0x7FFFFFFF
+    return f"{value % 1000000:06d}"
+
+def current_step():
+    return int(time.time()) // 30
+
+def login(output):
+    return send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": password}, output_file=output)
+
+def verify(mfa_token, code, output):
+    return send_and_print(f"{BASE_URL}/auth/mfa/verify", method="POST", body={"mfaToken": mfa_token, "code": code}, output_file=output)
+
+def pending_token(output):
+    body = login(output).json() or {}
+    return body.get('mfaToken', {}).get('token')
+
+print(f"\n{Colors.BOLD}=== TEST: TOTP MULTI-FACTOR AUTHENTICATION ==={Colors.ENDC}")
+
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+email = f"mfa_{timestamp}@test.com"
+password = "Correct-horse-battery-9"
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Mfa User", "email": email, "password": password,
+}, output_file="test_mfa_register.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+headers = {"Authorization": f"Bearer {reg.json()['tokens']['access']['token']}"}
+
+# 1. Enrollment
+print(f"\n>> Step 1: Enrolling an authenticator...")
+resp = send_and_print(f"{BASE_URL}/auth/mfa/enroll", method="POST", headers=headers, output_file="test_mfa_enroll.json")
+secret = (resp.json() or {}).get('secret', "")
+check(resp.status_code == 200 and secret and f"secret={secret}" in resp.json().get('otpauthUrl', ""), "Secret and otpauth URI issued (200).", f"Unexpected status: {resp.status_code}.")
+resp = login("test_mfa_login_unconfirmed.json")
+check(resp.status_code == 200 and 'tokens' in (resp.json() or {}), "MFA stays off until confirmed.", f"Unexpected response: {resp.json()}")
+
+resp = send_and_print(f"{BASE_URL}/auth/mfa/confirm", method="POST", headers=headers, body={"code": "000000" if totp(secret, current_step()) != "000000" else "111111"}, output_file="test_mfa_confirm_wrong.json")
+check(resp.status_code == 400, "Wrong confirmation code refused (400).", f"Unexpected status: {resp.status_code}.")
+step = current_step()
+resp = send_and_print(f"{BASE_URL}/auth/mfa/confirm", method="POST", headers=headers, body={"code": totp(secret, step)}, output_file="test_mfa_confirm.json")
+recovery_codes = (resp.json() or {}).get('recoveryCodes', [])
+check(resp.status_code == 200 and len(recovery_codes) == 10, "MFA enabled with 10 recovery codes (200).", f"Unexpected status: {resp.status_code}.")
+
+# 2. Login needs the second factor
+print(f"\n>> Step 2: Logging in with a TOTP code...")
+resp = login("test_mfa_login.json")
+body = resp.json() or {}
+mfa_token = body.get('mfaToken', {}).get('token')
+check(resp.status_code == 200 and body.get('mfaRequired') and 'tokens' not in body, "Password alone only yields a pending MFA token.", f"Unexpected response: {body}")
+resp = send_and_print(f"{BASE_URL}/users/me", headers={"Authorization": f"Bearer {mfa_token}"}, output_file="test_mfa_pending_as_access.json")
+check(resp.status_code == 401, "Pending MFA token is not an access token (401).", f"Unexpected status: {resp.status_code}.")
+
+resp = verify(mfa_token, totp(secret, step), "test_mfa_verify_replay.json")
+check(resp.status_code == 401, "Code already used for confirmation is refused (401).", f"Unexpected status: {resp.status_code}.")
+resp = verify(mfa_token, totp(secret, step + 1), "test_mfa_verify.json")
+check(resp.status_code == 200 and 'access' in (resp.json() or {}).get('tokens', {}), "Valid code exchanged for tokens (200).", f"Unexpected status: {resp.status_code}.")
+resp = verify(mfa_token, totp(secret, step + 1), "test_mfa_verify_token_reuse.json")
+check(resp.status_code == 401, "Pending token works only once (401).", f"Unexpected status: {resp.status_code}.")
+
+# 3. Recovery codes are single use
+print(f"\n>> Step 3: Logging in with a recovery code...")
+resp = verify(pending_token("test_mfa_login_recovery.json"), recovery_codes[0].upper(), "test_mfa_verify_recovery.json")
+check(resp.status_code == 200, "Recovery code accepted (200).", f"Unexpected status: {resp.status_code}.")
+resp = verify(pending_token("test_mfa_login_recovery_again.json"), recovery_codes[0], "test_mfa_verify_recovery_again.json")
+check(resp.status_code == 401, "Used recovery code refused (401).", f"Unexpected status: {resp.status_code}.")
+
+# 4. Guessing burns the pending token
+print(f"\n>> Step 4: Guessing codes...")
+mfa_token = pending_token("test_mfa_login_guess.json")
+statuses = [verify(mfa_token, f"{n:06d}", f"test_mfa_guess_{n}.json").status_code for n in range(5)]
+check(all(s == 401 for s in statuses), "Wrong codes refused (401).", f"Unexpected statuses: {statuses}")
+resp = verify(mfa_token, recovery_codes[1], "test_mfa_guess_then_valid.json")
+check(resp.status_code == 401, "Pending token revoked after 5 wrong codes (401).", f"Unexpected status: {resp.status_code}.")
+
+# 5. Admin reset
+print(f"\n>> Step 5: Resetting MFA as an admin...")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/mfa", method="DELETE", headers=headers, output_file="test_mfa_reset_self.json")
+check(resp.status_code == 403, "Regular users cannot reset MFA (403).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{uuid.uuid4()}/mfa", method="DELETE", headers=admin_headers, output_file="test_mfa_reset_unknown.json")
+check(resp.status_code == 404, "Unknown user (404).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/mfa", method="DELETE", headers=admin_headers, output_file="test_mfa_reset.json")
+check(resp.status_code == 204, "MFA reset (204).", f"Unexpected status: {resp.status_code}.")
+resp = login("test_mfa_login_after_reset.json")
+check(resp.status_code == 200 and 'tokens' in (resp.json() or {}), "Password login works again without a code.", f"Unexpected response: {resp.json()}")
+
+print(f"\n{Colors.BOLD}=== MFA TEST COMPLETE ==={Colors.ENDC}")
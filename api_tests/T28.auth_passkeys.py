@@ -0,0 +1,235 @@
+import sys
+import os
+import time
+import json
+import base64
+import hashlib
+import secrets
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+# Must match WEBAUTHN_RP_ID / WEBAUTHN_RP_ORIGINS of the server
+RP_ID = os.environ.get("WEBAUTHN_RP_ID", "localhost")
+ORIGIN = os.environ.get("WEBAUTHN_RP_ORIGIN", "http://localhost:3000")
+
+def b64(data):
+    return base64.urlsafe_b64encode(data).rstrip(b"=").decode()
+
+def unb64(value):
+    return base64.urlsafe_b64decode(value + "=" * (-len(value) % 4))
+
+# --- Minimal CBOR encoder (ints, byte/text strings, maps) ---
+def cbor_head(major, value):
+    if value < 24:
+        return bytes([major << 5 | value])
+    for info, size in ((24, 1), (25, 2), (26, 4), (27, 8)):
+        if value < 1 << (8 * size):
+            return bytes([major << 5 | info]) + value.to_bytes(size, "big")
+
+def cbor(value):
+    if isinstance(value, int):
+        return cbor_head(0, value) if value >= 0 else cbor_head(1, -1 - value)
+    if isinstance(value, bytes):
+        return cbor_head(2, len(value)) + value
+    if isinstance(value, str):
+        return cbor_head(3, len(value.encode())) + value.encode()
+    if isinstance(value, dict):
+        return cbor_head(5, len(value)) + b"".join(cbor(k) + cbor(v) for k, v in value.items())
+    raise TypeError(value)
+
+# --- P-256 ECDSA, enough for a software authenticator ---
+P = 0xffffffff00000001000000000000000000000000ffffffffffffffffffffffff
+N = 0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551
+G = (0x6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296,
+     0x4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5)
+
+def point_add(p1, p2):
+    if p1 is None:
+        return p2
+    if p2 is None:
+        return p1
+    if p1[0] == p2[0] and (p1[1] + p2[1]) % P == 0:
+        return None
+    if p1 == p2:
+        slope = (3 * p1[0] * p1[0] - 3) * pow(2 * p1[1], -1, P) % P
+    else:
+        slope = (p2[1] - p1[1]) * pow(p2[0] - p1[0], -1, P) % P
+    x = (slope * slope - p1[0] - p2[0]) % P
+    return x, (slope * (p1[0] - x) - p1[1]) % P
+
+def point_mul(k, point):
+    result = None
+    while k:
+        if k & 1:
+            result = point_add(result, point)
+        point = point_add(point, point)
+        k >>= 1
+    return result
+
+def der_int(value):
+    raw = value.to_bytes((value.bit_length() + 8) // 8, "big")
+    return b"\x02" + bytes([len(raw)]) + raw
+
+def ecdsa_sign(private, message):
+    z = int.from_bytes(hashlib.sha256(message).digest(), "big")
+    while True:
+        k = secrets.randbelow(N - 1) + 1
+        r = point_mul(k, G)[0] % N
+        s = pow(k, -1, N) * (z + r * private) % N
+        if r and s:
+            body = der_int(r) + der_int(s)
+            return b"\x30" + bytes([len(body)]) + body
+
+class Authenticator:
+    """A FIDO2 authenticator holding one discoverable P-256 passkey."""
+    def __init__(self):
+        self.private = secrets.randbelow(N - 1) + 1
+        self.public = point_mul(self.private, G)
+        self.credential_id = secrets.token_bytes(16)
+        self.user_handle = b""
+        self.sign_count = 0
+
+    def auth_data(self, flags):
+        return hashlib.sha256(RP_ID.encode()).digest() + bytes([flags]) + self.sign_count.to_bytes(4, "big")
+
+    def client_data(self, ceremony, challenge, origin=ORIGIN):
+        return json.dumps({"type": ceremony, "challenge": challenge, "origin": origin}).encode()
+
+    def credential(self, response):
+        return {"id": b64(self.credential_id), "rawId": b64(self.credential_id), "type": "public-key", "response": response}
+
+    def create(self, options, origin=ORIGIN):
+        self.user_handle = unb64(options['user']['id'])
+        cose_key = cbor({1: 2, 3: -7, -1: 1, -2: self.public[0].to_bytes(32, "big"), -3: self.public[1].to_bytes(32, "big")})
+        auth_data = self.auth_data(0x45) + bytes(16) + len(self.credential_id).to_bytes(2, "big") + self.credential_id + cose_key
+        attestation = cbor({"fmt": "none", "attStmt": {}, "authData": auth_data})
+        return self.credential({
+            "clientDataJSON": b64(self.client_data("webauthn.create", options['challenge'], origin)),
+            "attestationObject": b64(attestation),
+        })
+
+    def get(self, options):
+        self.sign_count += 1
+        client_data = self.client_data("webauthn.get", options['challenge'])
+        auth_data = self.auth_data(0x05)
+        signature = ecdsa_sign(self.private, auth_data + hashlib.sha256(client_data).digest())
+        return self.credential({
+            "clientDataJSON": b64(client_data),
+            "authenticatorData": b64(auth_data),
+            "signature": b64(signature),
+            "userHandle": b64(self.user_handle),
+        })
+
+def login_options(email, output):
+    body = {"email": email} if email else None
+    resp = send_and_print(f"{BASE_URL}/auth/passkeys/login/options", method="POST", body=body, output_file=output)
+    data = resp.json() or {}
+    return resp, data.get('sessionId'), data.get('options', {}).get('publicKey', {})
+
+def passkey_login(session_id, credential, output):
+    return send_and_print(f"{BASE_URL}/auth/passkeys/login", method="POST", body={"sessionId": session_id, "credential": credential}, output_file=output)
+
+print(f"\n{Colors.BOLD}=== TEST: PASSKEYS (WEBAUTHN) ==={Colors.ENDC}")
+
+timestamp = int(time.time())
+email = f"passkey_{timestamp}@test.com"
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Passkey User", "email": email, "password": "Correct-horse-battery-9",
+}, output_file="test_passkey_register_user.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Setup failed (Status: {reg.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+headers = {"Authorization": f"Bearer {reg.json()['tokens']['access']['token']}"}
+authenticator = Authenticator()
+
+# 1. Registration
+print(f"\n>> Step 1: Registering a passkey...")
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/register/options", method="POST", headers=headers, output_file="test_passkey_register_options.json")
+check(resp.status_code == 200, "Registration options issued (200).", f"Unexpected status: {resp.status_code}.")
+session_id, options = resp.json()['sessionId'], resp.json()['options']['publicKey']
+check(options.get('rp', {}).get('id') == RP_ID and options.get('challenge'), "Options name the relying party and carry a challenge.", f"Unexpected options: {options}")
+
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/register", method="POST", headers=headers, body={
+    "sessionId": session_id, "name": "Laptop", "credential": Authenticator().create(options, origin="https://evil.example"),
+}, output_file="test_passkey_register_wrong_origin.json")
+check(resp.status_code == 400, "Response from a foreign origin refused (400).", f"Unexpected status: {resp.status_code}.")
+
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/register/options", method="POST", headers=headers, output_file="test_passkey_register_options_2.json")
+session_id, options = resp.json()['sessionId'], resp.json()['options']['publicKey']
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/register", method="POST", headers=headers, body={
+    "sessionId": session_id, "name": "Laptop", "credential": authenticator.create(options),
+}, output_file="test_passkey_register.json")
+passkey = resp.json() or {}
+check(resp.status_code == 201 and passkey.get('credentialId') == b64(authenticator.credential_id), "Passkey registered (201).", f"Unexpected status: {resp.status_code}.")
+check('publicKey' not in passkey, "Public key material is not echoed back.", f"Unexpected fields: {list(passkey)}")
+
+# 2. Login with the email
+print(f"\n>> Step 2: Logging in with the passkey...")
+resp, session_id, options = login_options(email, "test_passkey_login_options.json")
+allowed = [c.get('id') for c in options.get('allowCredentials', [])]
+check(resp.status_code == 200 and allowed == [b64(authenticator.credential_id)], "Options list the registered passkey.", f"Unexpected credentials: {allowed}")
+assertion = authenticator.get(options)
+resp = passkey_login(session_id, assertion, "test_passkey_login.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('user', {}).get('id') == user_id and 'access' in resp.json().get('tokens', {}), "Passkey login returns the token pair (200).", f"Unexpected status: {resp.status_code}.")
+resp = passkey_login(session_id, assertion, "test_passkey_login_replay.json")
+check(resp.status_code == 401, "Replayed assertion refused (401).", f"Unexpected status: {resp.status_code}.")
+
+# 3. Discoverable login
+print(f"\n>> Step 3: Logging in without an email...")
+resp, session_id, options = login_options(None, "test_passkey_discoverable_options.json")
+check(resp.status_code == 200 and not options.get('allowCredentials'), "Discoverable options name no credential.", f"Unexpected options: {options}")
+resp = passkey_login(session_id, authenticator.get(options), "test_passkey_discoverable.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('user', {}).get('id') == user_id, "Discoverable login finds the user (200).", f"Unexpected status: {resp.status_code}.")
+resp, _, options = login_options(f"nobody_{timestamp}@test.com", "test_passkey_unknown_options.json")
+check(resp.status_code == 200 and not options.get('allowCredentials'), "Unknown email gets discoverable options.", f"Unexpected options: {options}")
+
+# 4. Forged and cloned authenticators
+print(f"\n>> Step 4: Presenting forged assertions...")
+forger = Authenticator()
+forger.credential_id, forger.user_handle = authenticator.credential_id, authenticator.user_handle
+resp, session_id, options = login_options(email, "test_passkey_forged_options.json")
+resp = passkey_login(session_id, forger.get(options), "test_passkey_forged.json")
+check(resp.status_code == 401, "Assertion signed by another key refused (401).", f"Unexpected status: {resp.status_code}.")
+
+clone = Authenticator()
+clone.private, clone.public, clone.credential_id, clone.user_handle = authenticator.private, authenticator.public, authenticator.credential_id, authenticator.user_handle
+resp, session_id, options = login_options(email, "test_passkey_clone_options.json")
+resp = passkey_login(session_id, clone.get(options), "test_passkey_clone.json")
+check(resp.status_code == 401, "Regressed sign count refused (401).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/auth/passkeys", headers=headers, output_file="test_passkey_list.json")
+listed = resp.json() if resp.status_code == 200 else []
+check(len(listed) == 1 and listed[0].get('cloneWarning') and listed[0].get('signCount') == authenticator.sign_count, "Passkey flagged as possibly cloned.", f"Unexpected passkeys: {listed}")
+
+# 5. Management
+print(f"\n>> Step 5: Renaming and deleting...")
+passkey_id = passkey.get('id')
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/{passkey_id}", method="PATCH", headers=headers, body={"name": "Work laptop"}, output_file="test_passkey_rename.json")
+check(resp.status_code == 200 and (resp.json() or {}).get('name') == "Work laptop", "Passkey renamed (200).", f"Unexpected status: {resp.status_code}.")
+other = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={
+    "name": "Other User", "email": f"passkey_other_{timestamp}@test.com", "password": "Correct-horse-battery-9",
+}, output_file="test_passkey_register_other.json")
+other_headers = {"Authorization": f"Bearer {other.json()['tokens']['access']['token']}"}
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/{passkey_id}", method="DELETE", headers=other_headers, output_file="test_passkey_delete_foreign.json")
+check(resp.status_code == 404, "Another user's passkey cannot be deleted (404).", f"Unexpected status: {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/auth/passkeys/{passkey_id}", method="DELETE", headers=headers, output_file="test_passkey_delete.json")
+check(resp.status_code == 204, "Passkey deleted (204).", f"Unexpected status: {resp.status_code}.")
+resp, session_id, options = login_options(None, "test_passkey_deleted_options.json")
+resp = passkey_login(session_id, authenticator.get(options), "test_passkey_deleted_login.json")
+check(resp.status_code == 401, "Deleted passkey no longer signs in (401).", f"Unexpected status: {resp.status_code}.")
+
+print(f"\n{Colors.BOLD}=== PASSKEY TEST COMPLETE ==={Colors.ENDC}")
@@ -0,0 +1,85 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+print(f"\n{Colors.BOLD}=== TEST: REFRESH TOKEN REUSE DETECTION ==={Colors.ENDC}")
+
+# 1. SETUP: Register a fresh user so we own the whole token family
+timestamp = int(time.time())
+email = f"refresh_reuse_{timestamp}@test.com"
+print(f"\n>> Step 1: Registering a Standard User ({email})...")
+
+reg_payload = {
+    "name": "Refresh Reuse User",
+    "email": email,
+    "password": "password123"
+}
+reg_response = send_and_print(f"{BASE_URL}/auth/register", method="POST", body=reg_payload, output_file="temp_refresh_user.json")
+
+if reg_response.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+
+original_refresh = reg_response.json()['tokens']['refresh']['token']
+url_refresh = f"{BASE_URL}/auth/refresh-tokens"
+
+# 2. Legitimate rotation, in the same second the token was issued
+print(f"\n>> Step 2: Rotating the refresh token (legitimate client)...")
+resp_rotate = send_and_print(url_refresh, method="POST", body={"refreshToken": original_refresh}, output_file="test_refresh_rotate.json")
+
+if resp_rotate.status_code == 200:
+    print(f"{Colors.OKGREEN}[PASS] Refresh token rotated (200).{Colors.ENDC}")
+else:
+    print(f"{Colors.FAIL}[FAIL] Rotation failed (Status: {resp_rotate.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+
+rotated_refresh = resp_rotate.json()['refresh']['token']
+
+# Tokens carry a jti, so a rotation within the same second still yields a new token
+if rotated_refresh != original_refresh:
+    print(f"{Colors.OKGREEN}[PASS] Rotated token differs from the original.{Colors.ENDC}")
+else:
+    print(f"{Colors.FAIL}[FAIL] Rotation returned the same token.{Colors.ENDC}")
+
+# 2b. Two rotations in a row (same second) must chain, not collide
+print(f"\n>> Step 2b: Rotating twice in quick succession...")
+resp_first = send_and_print(url_refresh, method="POST", body={"refreshToken": rotated_refresh}, output_file="test_refresh_rapid_1.json")
+resp_second = send_and_print(url_refresh, method="POST", body={"refreshToken": resp_first.json()['refresh']['token']}, output_file="test_refresh_rapid_2.json") if resp_first.status_code == 200 else resp_first
+
+if resp_first.status_code == 200 and resp_second.status_code == 200 and resp_first.json()['refresh']['token'] != resp_second.json()['refresh']['token']:
+    print(f"{Colors.OKGREEN}[PASS] Same-second rotations issued distinct tokens (200).{Colors.ENDC}")
+else:
+    print(f"{Colors.FAIL}[FAIL] Same-second rotation failed (Status: {resp_first.status_code}/{resp_second.status_code}).{Colors.ENDC}")
+    sys.exit(1)
+
+newest_refresh = resp_second.json()['refresh']['token']
+
+# 3. ATTACK: Replay the already rotated token
+print(f"\n>> Step 3: Replaying the ORIGINAL refresh token (attacker)...")
+resp_replay = send_and_print(url_refresh, method="POST", body={"refreshToken": original_refresh}, output_file="test_refresh_replay.json")
+
+if resp_replay.status_code == 401:
+    print(f"{Colors.OKGREEN}[PASS] Replayed token was rejected (401).{Colors.ENDC}")
+else:
+    print(f"{Colors.FAIL}[FAIL] Security Breach! Replayed token accepted (Status: {resp_replay.status_code}).{Colors.ENDC}")
+
+# 4. The replay must have revoked the whole family, including the newest token
+print(f"\n>> Step 4: Using the NEWEST refresh token after the replay...")
+resp_family = send_and_print(url_refresh, method="POST", body={"refreshToken": newest_refresh}, output_file="test_refresh_family.json")
+
+if resp_family.status_code == 401:
+    print(f"{Colors.OKGREEN}[PASS] Token family was revoked (401).{Colors.ENDC}")
+else:
+    print(f"{Colors.FAIL}[FAIL] Token family still active (Status: {resp_family.status_code}).{Colors.ENDC}")
+
+print(f"\n{Colors.BOLD}=== REFRESH REUSE TEST COMPLETE ==={Colors.ENDC}")
@@ -0,0 +1,275 @@
+import sys
+import os
+import time
+import json
+import base64
+import hashlib
+import secrets
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from urllib.parse import urlparse, parse_qs, urlencode
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL
+
+# Runs a fake OpenID Connect provider in this process. Start the API with:
+#   OIDC_PROVIDERS=fake
+#   OIDC_FAKE_ISSUER=http://localhost:9999
+#   OIDC_FAKE_CLIENT_ID=test-client
+#   OIDC_FAKE_CLIENT_SECRET=test-secret
+#   OIDC_FAKE_REDIRECT_URL=http://localhost:8080/v1/auth/oidc/fake/callback
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+ISSUER = "http://localhost:9999"
+CLIENT_ID = "test-client"
+CLIENT_SECRET = "test-secret"
+
+# --- FAKE PROVIDER: RS256 signing with the standard library only ---
+
+def b64url(data):
+    return base64.urlsafe_b64encode(data).rstrip(b"=").decode()
+
+def int_bytes(n):
+    return n.to_bytes((n.bit_length() + 7) // 8, "big")
+
+def is_probable_prime(n, rounds=40):
+    if n < 2:
+        return False
+    for p in (2, 3, 5, 7, 11, 13, 17, 19, 23, 29):
+        if n % p == 0:
+            return n == p
+    d, r = n - 1, 0
+    while d % 2 == 0:
+        d, r = d // 2, r + 1
+    for _ in range(rounds):
+        x = pow(secrets.randbelow(n - 3) + 2, d, n)
+        if x in (1, n - 1):
+            continue
+        for _ in range(r - 1):
+            x = pow(x, 2, n)
+            if x == n - 1:
+                break
+        else:
+            return False
+    return True
+
+def random_prime(bits):
+    while True:
+        candidate = secrets.randbits(bits) | (1 << (bits - 1)) | 1
+        if is_probable_prime(candidate):
+            return candidate
+
+def generate_rsa_key(bits=2048):
+    e = 65537
+    while True:
+        p, q = random_prime(bits // 2), random_prime(bits // 2)
+        phi = (p - 1) * (q - 1)
+        if p != q and phi % e != 0:
+            n = p * q
+            return {"n": n, "e": e, "d": pow(e, -1, phi)}
+
+# DER prefix of DigestInfo for SHA-256 (PKCS#1 v1.5)
+SHA256_PREFIX = bytes.fromhex("3031300d060960864801650304020105000420")
+
+def sign_rs256(key, message):
+    k = (key["n"].bit_length() + 7) // 8
+    t = SHA256_PREFIX + hashlib.sha256(message).digest()
+    em = b"\x00\x01" + b"\xff" * (k - len(t) - 3) + b"\x00" + t
+    return pow(int.from_bytes(em, "big"), key["d"], key["n"]).to_bytes(k, "big")
+
+KEY = generate_rsa_key()
+KID = "fake-key-1"
+
+def make_id_token(claims):
+    header = b64url(json.dumps({"alg": "RS256", "typ": "JWT", "kid": KID}).encode())
+    payload = b64url(json.dumps(claims).encode())
+    signing_input = f"{header}.{payload}".encode()
+    return f"{header}.{payload}.{b64url(sign_rs256(KEY, signing_input))}"
+
+# The identity the next /authorize call signs in as (the test plays the user)
+NEXT_IDENTITY = {}
+CODES = {}
+
+class FakeProvider(BaseHTTPRequestHandler):
+    def log_message(self, format, *args):
+        pass
+
+    def send_json(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def do_GET(self):
+        url = urlparse(self.path)
+        query = {k: v[0] for k, v in parse_qs(url.query).items()}
+        if url.path == "/.well-known/openid-configuration":
+            self.send_json(200, {
+                "issuer": ISSUER,
+                "authorization_endpoint": f"{ISSUER}/authorize",
+                "token_endpoint": f"{ISSUER}/token",
+                "jwks_uri": f"{ISSUER}/jwks",
+                "response_types_supported": ["code"],
+                "subject_types_supported": ["public"],
+                "id_token_signing_alg_values_supported": ["RS256"],
+            })
+        elif url.path == "/jwks":
+            self.send_json(200, {"keys": [{
+                "kty": "RSA", "alg": "RS256", "use": "sig", "kid": KID,
+                "n": b64url(int_bytes(KEY["n"])), "e": b64url(int_bytes(KEY["e"])),
+            }]})
+        elif url.path == "/authorize":
+            if query.get("code_challenge_method") != "S256" or not query.get("code_challenge"):
+                self.send_json(400, {"error": "invalid_request", "error_description": "PKCE required"})
+                return
+            code = secrets.token_urlsafe(16)
+            CODES[code] = {
+                "identity": dict(NEXT_IDENTITY),
+                "challenge": query["code_challenge"],
+                "nonce": query.get("nonce"),
+                "redirect_uri": query["redirect_uri"],
+            }
+            self.send_response(302)
+            # Lower case so send_and_print follows the redirect to the API callback
+            self.send_header("location", f"{query['redirect_uri']}?{urlencode({'code': code, 'state': query['state']})}")
+            self.send_header("Content-Length", "0")
+            self.end_headers()
+        else:
+            self.send_json(404, {"error": "not_found"})
+
+    def do_POST(self):
+        length = int(self.headers.get("Content-Length", 0))
+        form = {k: v[0] for k, v in parse_qs(self.rfile.read(length).decode()).items()}
+        if urlparse(self.path).path != "/token":
+            self.send_json(404, {"error": "not_found"})
+            return
+
+        client_id, client_secret = form.get("client_id"), form.get("client_secret")
+        auth = self.headers.get("Authorization", "")
+        if auth.startswith("Basic "):
+            client_id, client_secret = base64.b64decode(auth[6:]).decode().split(":", 1)
+        if client_id != CLIENT_ID or client_secret != CLIENT_SECRET:
+            self.send_json(401, {"error": "invalid_client"})
+            return
+
+        grant = CODES.pop(form.get("code", ""), None)
+        verifier = form.get("code_verifier", "")
+        if grant is None or b64url(hashlib.sha256(verifier.encode()).digest()) != grant["challenge"]:
+            self.send_json(400, {"error": "invalid_grant"})
+            return
+
+        now = int(time.time())
+        claims = {"iss": ISSUER, "aud": CLIENT_ID, "iat": now, "exp": now + 300, "nonce": grant["nonce"]}
+        claims.update(grant["identity"])
+        self.send_json(200, {
+            "access_token": secrets.token_urlsafe(16),
+            "token_type": "Bearer",
+            "expires_in": 300,
+            "id_token": make_id_token(claims),
+        })
+
+server = HTTPServer(("localhost", 9999), FakeProvider)
+threading.Thread(target=server.serve_forever, daemon=True).start()
+
+# --- HELPERS ---
+
+def oidc_sign_in(identity, authorize_url, output_file):
+    """Signs in at the fake provider and follows its redirect to the API callback."""
+    NEXT_IDENTITY.clear()
+    NEXT_IDENTITY.update(identity)
+    return send_and_print(authorize_url, output_file=output_file)
+
+def authorize_url():
+    resp = send_and_print(f"{BASE_URL}/auth/oidc/fake/authorize", output_file="test_oidc_authorize.json")
+    if resp.status_code != 200:
+        print(f"{Colors.FAIL}Critical: Could not start OIDC sign-in (Status: {resp.status_code}). Is the API configured for the fake provider?{Colors.ENDC}")
+        sys.exit(1)
+    return resp.json()['authorizationUrl']
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+print(f"\n{Colors.BOLD}=== TEST: OIDC LOGIN AND ACCOUNT LINKING ==={Colors.ENDC}")
+timestamp = int(time.time())
+
+# 1. Provider discovery
+print(f"\n>> Step 1: Listing configured providers...")
+resp = send_and_print(f"{BASE_URL}/auth/oidc/providers", output_file="test_oidc_providers.json")
+check(resp.status_code == 200 and "fake" in (resp.json() or []), "Fake provider is configured.", f"Fake provider missing (Status: {resp.status_code}).")
+
+# 2. First sign-in auto-provisions an account
+new_email = f"oidc_new_{timestamp}@test.com"
+print(f"\n>> Step 2: First sign-in with an unknown subject ({new_email})...")
+url = authorize_url()
+resp = oidc_sign_in({"sub": f"new-{timestamp}", "email": new_email, "email_verified": True, "name": "OIDC User"}, url, "test_oidc_provision.json")
+check(resp.status_code == 200 and resp.json()['user']['email'] == new_email, "Account provisioned and tokens issued.", f"Provisioning failed (Status: {resp.status_code}).")
+provisioned_id = resp.json()['user']['id'] if resp.status_code == 200 else None
+provisioned_access = resp.json()['tokens']['access']['token'] if resp.status_code == 200 else None
+
+# 3. Replaying the callback (same state and code) must fail
+print(f"\n>> Step 3: Replaying the callback...")
+resp = send_and_print(url, output_file="test_oidc_replay.json")
+check(resp.status_code == 401, "Replayed state was rejected (401).", f"Replay accepted (Status: {resp.status_code}).")
+
+# 4. Second sign-in with the same subject reaches the same user
+print(f"\n>> Step 4: Signing in again with the same subject...")
+resp = oidc_sign_in({"sub": f"new-{timestamp}", "email": new_email, "email_verified": True}, authorize_url(), "test_oidc_returning.json")
+check(resp.status_code == 200 and resp.json()['user']['id'] == provisioned_id, "Returning identity maps to the same user.", f"Different user or failure (Status: {resp.status_code}).")
+
+# 5. Verified email of an existing local account links to it
+local_email = f"oidc_local_{timestamp}@test.com"
+print(f"\n>> Step 5: Verified email matches an existing account ({local_email})...")
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Local User", "email": local_email, "password": "password123"}, output_file="test_oidc_local_user.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+local_id = reg.json()['user']['id']
+local_access = reg.json()['tokens']['access']['token']
+resp = oidc_sign_in({"sub": f"verified-{timestamp}", "email": local_email, "email_verified": True}, authorize_url(), "test_oidc_link_verified.json")
+check(resp.status_code == 200 and resp.json()['user']['id'] == local_id, "Linked to the existing account.", f"Not linked (Status: {resp.status_code}).")
+
+# 6. ATTACK: Unverified email must not take over an existing account
+print(f"\n>> Step 6: Unverified email matches an existing account (takeover attempt)...")
+resp = oidc_sign_in({"sub": f"unverified-{timestamp}", "email": local_email, "email_verified": False}, authorize_url(), "test_oidc_link_unverified.json")
+check(resp.status_code == 401, "Unverified email was refused (401).", f"Security Breach! Account taken over (Status: {resp.status_code}).")
+
+# 7. Explicit linking by a signed-in user
+print(f"\n>> Step 7: Linking another subject while signed in...")
+headers = {"Authorization": f"Bearer {local_access}"}
+resp = send_and_print(f"{BASE_URL}/auth/oidc/fake/link", method="POST", headers=headers, output_file="test_oidc_link_start.json")
+link_sub = f"linked-{timestamp}"
+resp = oidc_sign_in({"sub": link_sub, "email": f"other_{timestamp}@test.com", "email_verified": False}, resp.json()['authorizationUrl'], "test_oidc_link_finish.json")
+check(resp.status_code == 200 and resp.json().get('linked') is True, "Identity linked.", f"Linking failed (Status: {resp.status_code}).")
+
+resp = oidc_sign_in({"sub": link_sub, "email": f"other_{timestamp}@test.com", "email_verified": False}, authorize_url(), "test_oidc_linked_login.json")
+check(resp.status_code == 200 and resp.json()['user']['id'] == local_id, "Linked subject signs in to the account.", f"Linked login failed (Status: {resp.status_code}).")
+
+# 8. Listing and unlinking
+print(f"\n>> Step 8: Listing and unlinking identities...")
+resp = send_and_print(f"{BASE_URL}/auth/identities", headers=headers, output_file="test_oidc_identities.json")
+identities = resp.json() if resp.status_code == 200 else []
+check(len(identities) == 2, "Both linked identities listed.", f"Expected 2 identities, got {len(identities)}.")
+linked = [i for i in identities if i['subject'] == link_sub]
+
+if provisioned_access:
+    resp = send_and_print(f"{BASE_URL}/auth/identities/{linked[0]['id'] if linked else 0}", method="DELETE", headers={"Authorization": f"Bearer {provisioned_access}"}, output_file="test_oidc_unlink_other.json")
+    check(resp.status_code == 404, "Cannot unlink another user's identity (404).", f"Security Breach! Foreign identity unlinked (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{BASE_URL}/auth/identities/{linked[0]['id'] if linked else 0}", method="DELETE", headers=headers, output_file="test_oidc_unlink.json")
+check(resp.status_code == 204, "Identity unlinked (204).", f"Unlink failed (Status: {resp.status_code}).")
+
+resp = oidc_sign_in({"sub": link_sub, "email": f"other_{timestamp}@test.com", "email_verified": True}, authorize_url(), "test_oidc_after_unlink.json")
+check(resp.status_code == 200 and resp.json()['user']['id'] != local_id, "Unlinked subject no longer reaches the account.", f"Unlinked subject still signs in (Status: {resp.status_code}).")
+
+server.shutdown()
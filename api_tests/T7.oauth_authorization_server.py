@@ -0,0 +1,231 @@
+import sys
+import os
+import time
+import json
+import base64
+import hashlib
+import secrets
+import sqlite3
+from urllib.parse import urlencode, urlparse, parse_qs
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Set DB_FILE to the SQLite database of the API to check that a password reset revokes client tokens.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def jwt_claims(token):
+    payload = token.split(".")[1]
+    return json.loads(base64.urlsafe_b64decode(payload + "=" * (-len(payload) % 4)))
+
+def basic_auth(client_id, client_secret):
+    return "Basic " + base64.b64encode(f"{client_id}:{client_secret}".encode()).decode()
+
+def token_request(form, output_file, auth=None):
+    headers = {"Content-Type": "application/x-www-form-urlencoded"}
+    if auth:
+        headers["Authorization"] = auth
+    return send_and_print(f"{BASE_URL}/oauth/token", headers=headers, method="POST", body=urlencode(form), output_file=output_file)
+
+def pkce_pair():
+    verifier = secrets.token_urlsafe(48)
+    challenge = base64.urlsafe_b64encode(hashlib.sha256(verifier.encode()).digest()).rstrip(b"=").decode()
+    return verifier, challenge
+
+print(f"\n{Colors.BOLD}=== TEST: OAUTH2 AUTHORIZATION SERVER ==={Colors.ENDC}")
+
+# 0. Load Admin Token
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+
+# 1. Machine-to-machine client
+print(f"\n>> Step 1: Registering a confidential client_credentials client...")
+resp = send_and_print(f"{BASE_URL}/oauth/clients", headers=admin_headers, method="POST", body={
+    "name": f"Reports Service {timestamp}",
+    "grantTypes": ["client_credentials"],
+    "scopes": ["reports:read", "reports:write"],
+    "audiences": ["reports-service"],
+}, output_file="test_oauth_client_m2m.json")
+if resp.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to create client (Status: {resp.status_code}). Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+m2m_id = resp.json()['client']['id']
+m2m_secret = resp.json()['clientSecret']
+
+# 2. client_credentials grant
+print(f"\n>> Step 2: client_credentials grant with a narrowed scope...")
+resp = token_request({"grant_type": "client_credentials", "scope": "reports:read"}, "test_oauth_cc.json", basic_auth(m2m_id, m2m_secret))
+check(resp.status_code == 200, "Client token issued (200).", f"Token request failed (Status: {resp.status_code}).")
+if resp.status_code == 200:
+    claims = jwt_claims(resp.json()['access_token'])
+    check(claims.get('scope') == "reports:read" and claims.get('client_id') == m2m_id and "reports-service" in claims.get('aud', []),
+          "Token carries scope, client_id and aud claims.", f"Unexpected claims: {claims}")
+    m2m_access = resp.json()['access_token']
+
+    # The client token is not a user session on this API
+    resp = send_and_print(f"{BASE_URL}/auth/passkeys", headers={"Authorization": f"Bearer {m2m_access}"}, output_file="test_oauth_cc_on_api.json")
+    check(resp.status_code == 401, "Client token rejected by user endpoints (401).", f"Security Breach! Client token accepted (Status: {resp.status_code}).")
+
+# 3. ATTACK: wrong secret and scope escalation
+print(f"\n>> Step 3: Wrong secret and unknown scope...")
+resp = token_request({"grant_type": "client_credentials"}, "test_oauth_cc_bad_secret.json", basic_auth(m2m_id, "wrong"))
+check(resp.status_code == 401 and resp.json().get('error') == "invalid_client", "Wrong secret rejected (401 invalid_client).", f"Wrong secret accepted (Status: {resp.status_code}).")
+resp = token_request({"grant_type": "client_credentials", "scope": "users:delete", "client_id": m2m_id, "client_secret": m2m_secret}, "test_oauth_cc_bad_scope.json")
+check(resp.status_code == 400 and resp.json().get('error') == "invalid_scope", "Scope escalation rejected (400 invalid_scope).", f"Scope escalation accepted (Status: {resp.status_code}).")
+
+# 4. Public client for the authorization code flow
+redirect_uri = "http://localhost:3000/oauth/callback"
+print(f"\n>> Step 4: Registering a public authorization_code client...")
+resp = send_and_print(f"{BASE_URL}/oauth/clients", headers=admin_headers, method="POST", body={
+    "name": f"Mobile App {timestamp}",
+    "public": True,
+    "grantTypes": ["authorization_code", "refresh_token"],
+    "redirectUris": [redirect_uri],
+    "scopes": ["profile", "reports:read"],
+}, output_file="test_oauth_client_public.json")
+if resp.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to create client (Status: {resp.status_code}). Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+app_id = resp.json()['client']['id']
+check(resp.json()['clientSecret'] == "", "Public client has no secret.", "Public client got a secret.")
+
+# 5. User signs in and looks at the consent screen
+email = f"oauth_user_{timestamp}@test.com"
+print(f"\n>> Step 5: Consent screen for a signed-in user ({email})...")
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "OAuth User", "email": email, "password": "password123"}, output_file="test_oauth_user.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+user_headers = {"Authorization": f"Bearer {reg.json()['tokens']['access']['token']}"}
+
+def authorize_params(challenge, redirect=redirect_uri, scope="profile"):
+    return {"response_type": "code", "client_id": app_id, "redirect_uri": redirect, "scope": scope,
+            "state": "xyz", "code_challenge": challenge, "code_challenge_method": "S256"}
+
+verifier, challenge = pkce_pair()
+resp = send_and_print(f"{BASE_URL}/oauth/authorize?{urlencode(authorize_params(challenge))}", headers=user_headers, output_file="test_oauth_consent.json")
+check(resp.status_code == 200 and resp.json()['scopes'] == ["profile"], "Consent details returned.", f"Consent request failed (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{BASE_URL}/oauth/authorize?{urlencode(authorize_params(challenge, redirect='http://evil.example/cb'))}", headers=user_headers, output_file="test_oauth_consent_bad_redirect.json")
+check(resp.status_code == 400, "Unregistered redirect_uri refused (400).", f"Open redirect! (Status: {resp.status_code}).")
+
+def approve(challenge, approved=True):
+    body = dict(authorize_params(challenge))
+    body["approved"] = approved
+    resp = send_and_print(f"{BASE_URL}/oauth/authorize", headers=user_headers, method="POST", body=body, output_file="test_oauth_approve.json")
+    return parse_qs(urlparse(resp.json()['redirectUri']).query)
+
+# 6. Denied consent
+print(f"\n>> Step 6: User denies consent...")
+params = approve(challenge, approved=False)
+check(params.get('error') == ["access_denied"] and params.get('state') == ["xyz"], "Denial redirected with access_denied.", f"Unexpected redirect: {params}")
+
+# 7. ATTACK: code exchanged without the right PKCE verifier
+print(f"\n>> Step 7: Exchanging a code with a wrong code_verifier...")
+params = approve(challenge)
+resp = token_request({"grant_type": "authorization_code", "code": params['code'][0], "redirect_uri": redirect_uri, "client_id": app_id, "code_verifier": "wrong" * 10}, "test_oauth_code_bad_verifier.json")
+check(resp.status_code == 400 and resp.json().get('error') == "invalid_grant", "Wrong verifier rejected (invalid_grant).", f"Security Breach! Code exchanged (Status: {resp.status_code}).")
+
+# 8. Happy path and code replay
+print(f"\n>> Step 8: Exchanging a code with PKCE...")
+verifier, challenge = pkce_pair()
+params = approve(challenge)
+code_form = {"grant_type": "authorization_code", "code": params['code'][0], "redirect_uri": redirect_uri, "client_id": app_id, "code_verifier": verifier}
+resp = token_request(code_form, "test_oauth_code.json")
+check(resp.status_code == 200 and resp.json().get('refresh_token'), "Access and refresh token issued.", f"Exchange failed (Status: {resp.status_code}).")
+if resp.status_code != 200:
+    sys.exit(1)
+claims = jwt_claims(resp.json()['access_token'])
+check(claims.get('sub') == user_id and claims.get('client_id') == app_id and claims.get('scope') == "profile", "Token is for the user, the client and the consented scope.", f"Unexpected claims: {claims}")
+refresh_token = resp.json()['refresh_token']
+
+resp = token_request(code_form, "test_oauth_code_replay.json")
+check(resp.status_code == 400 and resp.json().get('error') == "invalid_grant", "Code replay rejected (invalid_grant).", f"Security Breach! Code reused (Status: {resp.status_code}).")
+
+# 9. ATTACK: the OAuth refresh token is not a first-party session
+print(f"\n>> Step 9: Presenting the OAuth refresh token to /auth/refresh-tokens...")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": refresh_token}, output_file="test_oauth_refresh_first_party.json")
+check(resp.status_code == 401, "OAuth refresh token rejected by first-party refresh (401).", f"Security Breach! Session minted (Status: {resp.status_code}).")
+
+# 10. refresh_token grant and reuse detection
+print(f"\n>> Step 10: Rotating the refresh token and replaying the old one...")
+resp = token_request({"grant_type": "refresh_token", "refresh_token": refresh_token, "client_id": app_id}, "test_oauth_refresh.json")
+check(resp.status_code == 200, "Refresh token rotated (200).", f"Refresh failed (Status: {resp.status_code}).")
+new_refresh = resp.json().get('refresh_token') if resp.status_code == 200 else ""
+
+resp = token_request({"grant_type": "refresh_token", "refresh_token": refresh_token, "client_id": app_id}, "test_oauth_refresh_replay.json")
+check(resp.status_code == 400, "Replayed refresh token rejected.", f"Replay accepted (Status: {resp.status_code}).")
+resp = token_request({"grant_type": "refresh_token", "refresh_token": new_refresh, "client_id": app_id}, "test_oauth_refresh_after_replay.json")
+check(resp.status_code == 400, "Whole token family revoked after the replay.", f"Family still valid (Status: {resp.status_code}).")
+
+# 11. Scopes removed from the client are dropped on refresh
+print(f"\n>> Step 11: Narrowing the client's scopes after the grant...")
+verifier, challenge = pkce_pair()
+body = dict(authorize_params(challenge, scope="profile reports:read"), approved=True)
+resp = send_and_print(f"{BASE_URL}/oauth/authorize", headers=user_headers, method="POST", body=body, output_file="test_oauth_approve_wide.json")
+code = parse_qs(urlparse(resp.json()['redirectUri']).query)['code'][0]
+resp = token_request({"grant_type": "authorization_code", "code": code, "redirect_uri": redirect_uri, "client_id": app_id, "code_verifier": verifier}, "test_oauth_code_wide.json")
+check(resp.status_code == 200 and resp.json().get('scope') == "profile reports:read", "Both scopes granted.", f"Unexpected response (Status: {resp.status_code}).")
+wide_refresh = resp.json().get('refresh_token', "")
+
+resp = send_and_print(f"{BASE_URL}/oauth/clients/{app_id}", headers=admin_headers, method="PATCH", body={"scopes": ["profile"]}, output_file="test_oauth_client_narrow.json")
+check(resp.status_code == 200, "Client scopes narrowed (200).", f"Update failed (Status: {resp.status_code}).")
+resp = token_request({"grant_type": "refresh_token", "refresh_token": wide_refresh, "client_id": app_id, "scope": "reports:read"}, "test_oauth_refresh_removed_scope.json")
+check(resp.status_code == 400 and resp.json().get('error') == "invalid_scope", "Removed scope cannot be requested (invalid_scope).", f"Removed scope granted (Status: {resp.status_code}).")
+resp = token_request({"grant_type": "refresh_token", "refresh_token": wide_refresh, "client_id": app_id}, "test_oauth_refresh_narrowed.json")
+narrowed = jwt_claims(resp.json()['access_token']).get('scope') if resp.status_code == 200 else None
+check(resp.status_code == 200 and resp.json().get('scope') == "profile" and narrowed == "profile", "Refresh keeps only the scopes the client still has.", f"Unexpected scope: {narrowed} (Status: {resp.status_code}).")
+
+# 12. A password reset locks clients out as well
+print(f"\n>> Step 12: Resetting the password of the user who approved the client...")
+db_file = os.environ.get("DB_FILE")
+if db_file:
+    verifier, challenge = pkce_pair()
+    params = approve(challenge)
+    resp = token_request({"grant_type": "authorization_code", "code": params['code'][0], "redirect_uri": redirect_uri, "client_id": app_id, "code_verifier": verifier}, "test_oauth_code_before_reset.json")
+    reset_refresh = resp.json().get('refresh_token', "")
+    send_and_print(f"{BASE_URL}/auth/forgot-password", method="POST", body={"email": email}, output_file="test_oauth_forgot_password.json")
+    with sqlite3.connect(db_file) as conn:
+        row = conn.execute("SELECT token FROM tokens WHERE user_id = ? AND type = 'resetPassword' ORDER BY id DESC LIMIT 1", (user_id,)).fetchone()
+    resp = send_and_print(f"{BASE_URL}/auth/reset-password?token={row[0] if row else ''}", method="POST", body={"password": "newPassword123"}, output_file="test_oauth_reset_password.json")
+    check(resp.status_code == 204, "Password reset (204).", f"Reset failed (Status: {resp.status_code}).")
+    resp = token_request({"grant_type": "refresh_token", "refresh_token": reset_refresh, "client_id": app_id}, "test_oauth_refresh_after_reset.json")
+    check(resp.status_code == 400, "Client refresh tokens revoked by the reset.", f"Refresh still works (Status: {resp.status_code}).")
+    time.sleep(1.1)  # Revocation cutoffs have one-second precision
+    resp = send_and_print(f"{BASE_URL}/auth/login", method="POST", body={"email": email, "password": "newPassword123"}, output_file="test_oauth_login_after_reset.json")
+    user_headers = {"Authorization": f"Bearer {resp.json()['tokens']['access']['token']}"}
+else:
+    print(f"{Colors.WARNING}DB_FILE not set, skipping.{Colors.ENDC}")
+
+# 13. Suspended users cannot be acted for
+print(f"\n>> Step 13: Suspending the user who approved the client...")
+verifier, challenge = pkce_pair()
+params = approve(challenge)
+pending_code = {"grant_type": "authorization_code", "code": params['code'][0], "redirect_uri": redirect_uri, "client_id": app_id, "code_verifier": verifier}
+resp = send_and_print(f"{BASE_URL}/users/{user_id}/suspend", headers=admin_headers, method="POST", body={"reason": "OAuth test"}, output_file="test_oauth_suspend.json")
+check(resp.status_code == 200, "User suspended (200).", f"Suspension failed (Status: {resp.status_code}).")
+resp = token_request(pending_code, "test_oauth_code_suspended.json")
+check(resp.status_code == 400 and resp.json().get('error') == "invalid_grant", "Code approved before the suspension refused (invalid_grant).", f"Tokens issued for a suspended user (Status: {resp.status_code}).")
+resp = token_request({"grant_type": "refresh_token", "refresh_token": wide_refresh, "client_id": app_id}, "test_oauth_refresh_suspended.json")
+check(resp.status_code == 400, "Client refresh tokens revoked by the suspension.", f"Refresh still works (Status: {resp.status_code}).")
+
+# Cleanup
+send_and_print(f"{BASE_URL}/oauth/clients/{m2m_id}", headers=admin_headers, method="DELETE", output_file="test_oauth_cleanup.json")
+send_and_print(f"{BASE_URL}/oauth/clients/{app_id}", headers=admin_headers, method="DELETE", output_file="test_oauth_cleanup.json")
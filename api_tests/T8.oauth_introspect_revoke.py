@@ -0,0 +1,148 @@
+import sys
+import os
+import time
+import base64
+import sqlite3
+from urllib.parse import urlencode
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# Set DB_FILE to the SQLite database of the API to check tokens of disabled accounts.
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+def basic_auth(client_id, client_secret):
+    return "Basic " + base64.b64encode(f"{client_id}:{client_secret}".encode()).decode()
+
+def form_request(path, form, auth, output_file):
+    headers = {"Content-Type": "application/x-www-form-urlencoded", "Authorization": auth}
+    return send_and_print(f"{BASE_URL}{path}", headers=headers, method="POST", body=urlencode(form), output_file=output_file)
+
+print(f"\n{Colors.BOLD}=== TEST: TOKEN INTROSPECTION AND REVOCATION ==={Colors.ENDC}")
+
+# 0. Load Admin Token
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.FAIL}No access token found. Run A2.auth_login.py first.{Colors.ENDC}")
+    sys.exit(1)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+timestamp = int(time.time())
+
+# 1. SETUP: a gateway client and a user session
+print(f"\n>> Step 1: Registering a gateway client and a user...")
+resp = send_and_print(f"{BASE_URL}/oauth/clients", headers=admin_headers, method="POST", body={
+    "name": f"API Gateway {timestamp}",
+    "grantTypes": ["client_credentials"],
+    "scopes": ["gateway"],
+}, output_file="test_introspect_client.json")
+if resp.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to create client (Status: {resp.status_code}). Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+gateway_id = resp.json()['client']['id']
+gateway = basic_auth(gateway_id, resp.json()['clientSecret'])
+
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Introspect User", "email": f"introspect_{timestamp}@test.com", "password": "password123"}, output_file="test_introspect_user.json")
+if reg.status_code != 201:
+    print(f"{Colors.FAIL}Critical: Failed to register user. Cannot proceed.{Colors.ENDC}")
+    sys.exit(1)
+user_id = reg.json()['user']['id']
+access_token = reg.json()['tokens']['access']['token']
+refresh_token = reg.json()['tokens']['refresh']['token']
+
+# 2. Introspection of live tokens
+print(f"\n>> Step 2: Introspecting the user's tokens...")
+resp = form_request("/oauth/introspect", {"token": access_token}, gateway, "test_introspect_access.json")
+body = resp.json() or {}
+check(resp.status_code == 200 and body.get('active') is True and body.get('sub') == user_id and body.get('type') == "access" and body.get('exp'),
+      "Access token is active with sub, type and exp.", f"Unexpected introspection: {body}")
+resp = form_request("/oauth/introspect", {"token": refresh_token, "token_type_hint": "refresh_token"}, gateway, "test_introspect_refresh.json")
+check((resp.json() or {}).get('active') is True and resp.json().get('type') == "refresh", "Refresh token is active.", f"Unexpected introspection: {resp.json()}")
+resp = form_request("/oauth/introspect", {"token": "not-a-token"}, gateway, "test_introspect_garbage.json")
+check(resp.status_code == 200 and resp.json() == {"active": False}, "Garbage token reported inactive.", f"Unexpected introspection: {resp.json()}")
+
+# 3. ATTACK: unauthenticated and public callers
+print(f"\n>> Step 3: Introspecting without valid client credentials...")
+resp = form_request("/oauth/introspect", {"token": access_token}, basic_auth(gateway_id, "wrong"), "test_introspect_bad_client.json")
+check(resp.status_code == 401, "Wrong client secret rejected (401).", f"Security Breach! (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{BASE_URL}/oauth/clients", headers=admin_headers, method="POST", body={
+    "name": f"Public App {timestamp}", "public": True,
+    "grantTypes": ["authorization_code"], "redirectUris": ["http://localhost:3000/cb"],
+}, output_file="test_introspect_public_client.json")
+public_id = resp.json()['client']['id']
+resp = send_and_print(f"{BASE_URL}/oauth/introspect", headers={"Content-Type": "application/x-www-form-urlencoded"}, method="POST",
+                      body=urlencode({"token": access_token, "client_id": public_id}), output_file="test_introspect_public.json")
+check(resp.status_code == 401, "Public client cannot introspect (401).", f"Public client introspected (Status: {resp.status_code}).")
+
+# 4. Revoking the access token
+print(f"\n>> Step 4: Revoking the access token...")
+resp = form_request("/oauth/revoke", {"token": access_token}, gateway, "test_revoke_access.json")
+check(resp.status_code == 200, "Revocation accepted (200).", f"Revocation failed (Status: {resp.status_code}).")
+resp = form_request("/oauth/introspect", {"token": access_token}, gateway, "test_introspect_revoked_access.json")
+check(resp.json() == {"active": False}, "Revoked access token is inactive.", f"Still active: {resp.json()}")
+resp = send_and_print(f"{BASE_URL}/auth/passkeys", headers={"Authorization": f"Bearer {access_token}"}, output_file="test_revoked_access_use.json")
+check(resp.status_code == 401, "Revoked access token refused by the API (401).", f"Revoked token accepted (Status: {resp.status_code}).")
+
+# 5. Revoking the refresh token
+print(f"\n>> Step 5: Revoking the refresh token...")
+resp = form_request("/oauth/revoke", {"token": refresh_token, "token_type_hint": "refresh_token"}, gateway, "test_revoke_refresh.json")
+check(resp.status_code == 200, "Revocation accepted (200).", f"Revocation failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/auth/refresh-tokens", method="POST", body={"refreshToken": refresh_token}, output_file="test_revoked_refresh_use.json")
+check(resp.status_code == 401, "Revoked refresh token cannot be used (401).", f"Revoked refresh token accepted (Status: {resp.status_code}).")
+
+resp = form_request("/oauth/revoke", {"token": "not-a-token"}, gateway, "test_revoke_garbage.json")
+check(resp.status_code == 200, "Unknown token revocation still answers 200.", f"Unexpected status {resp.status_code}.")
+
+# 6. Client tokens
+print(f"\n>> Step 6: Introspecting and revoking a client_credentials token...")
+resp = form_request("/oauth/token", {"grant_type": "client_credentials"}, gateway, "test_introspect_cc.json")
+client_token = resp.json()['access_token']
+resp = form_request("/oauth/introspect", {"token": client_token}, gateway, "test_introspect_cc_active.json")
+check(resp.json().get('active') is True and resp.json().get('client_id') == gateway_id and resp.json().get('scope') == "gateway",
+      "Client token is active with client_id and scope.", f"Unexpected introspection: {resp.json()}")
+form_request("/oauth/revoke", {"token": client_token}, gateway, "test_revoke_cc.json")
+resp = form_request("/oauth/introspect", {"token": client_token}, gateway, "test_introspect_cc_revoked.json")
+check(resp.json() == {"active": False}, "Revoked client token is inactive.", f"Still active: {resp.json()}")
+
+# 7. Tokens of an account that is no longer active
+print(f"\n>> Step 7: Introspecting the token of a disabled account...")
+db_file = os.environ.get("DB_FILE")
+if db_file:
+    reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Disabled User", "email": f"introspect_disabled_{timestamp}@test.com", "password": "password123"}, output_file="test_introspect_disabled_user.json")
+    disabled_token = reg.json()['tokens']['access']['token']
+    resp = form_request("/oauth/introspect", {"token": disabled_token}, gateway, "test_introspect_disabled_before.json")
+    check(resp.json().get('active') is True, "Token is active while the account is.", f"Unexpected introspection: {resp.json()}")
+    # Status changed outside the API (e.g. by another service), so no session was revoked
+    with sqlite3.connect(db_file) as conn:
+        conn.execute("UPDATE users SET status = 'banned' WHERE id = ?", (reg.json()['user']['id'],))
+    resp = form_request("/oauth/introspect", {"token": disabled_token}, gateway, "test_introspect_disabled_after.json")
+    check(resp.json() == {"active": False}, "Token of a banned account is inactive.", f"Still active: {resp.json()}")
+else:
+    print(f"{Colors.WARNING}DB_FILE not set, skipping.{Colors.ENDC}")
+
+# 8. Tokens issued before a change of permissions
+print(f"\n>> Step 8: Introspecting a token after a role change...")
+reg = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": "Promoted User", "email": f"introspect_promoted_{timestamp}@test.com", "password": "password123"}, output_file="test_introspect_promoted_user.json")
+promoted_token = reg.json()['tokens']['access']['token']
+role_name = f"introspect-readers-{timestamp}"
+send_and_print(f"{BASE_URL}/roles", headers=admin_headers, method="POST", body={"name": role_name, "permissions": ["users:read"]}, output_file="test_introspect_role.json")
+resp = send_and_print(f"{BASE_URL}/users/{reg.json()['user']['id']}/roles", headers=admin_headers, method="PUT", body={"roles": ["user", role_name]}, output_file="test_introspect_promote.json")
+check(resp.status_code == 200, "Role granted (200).", f"Unexpected status: {resp.status_code}.")
+resp = form_request("/oauth/introspect", {"token": promoted_token}, gateway, "test_introspect_stale.json")
+check(resp.json() == {"active": False}, "Token with the old permissions is inactive.", f"Still active: {resp.json()}")
+
+# Cleanup
+send_and_print(f"{BASE_URL}/oauth/clients/{gateway_id}", headers=admin_headers, method="DELETE", output_file="test_introspect_cleanup.json")
+send_and_print(f"{BASE_URL}/oauth/clients/{public_id}", headers=admin_headers, method="DELETE", output_file="test_introspect_cleanup.json")
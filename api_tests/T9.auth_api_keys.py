@@ -0,0 +1,131 @@
+import sys
+import os
+import time
+sys.path.append(os.path.abspath(os.path.dirname(__file__)))
+from utils import send_and_print, BASE_URL, load_config
+
+# --- COLORS ---
+class Colors:
+    OKGREEN = '\033[92m'
+    FAIL = '\033[91m'
+    WARNING = '\033[93m'
+    ENDC = '\033[0m'
+    BOLD = '\033[1m'
+
+def check(condition, passed, failed):
+    if condition:
+        print(f"{Colors.OKGREEN}[PASS] {passed}{Colors.ENDC}")
+    else:
+        print(f"{Colors.FAIL}[FAIL] {failed}{Colors.ENDC}")
+
+print(f"\n{Colors.BOLD}=== TEST: PERSONAL API KEYS ==={Colors.ENDC}")
+
+timestamp = int(time.time())
+
+def register(name):
+    resp = send_and_print(f"{BASE_URL}/auth/register", method="POST", body={"name": name, "email": f"{name.lower()}_{timestamp}@test.com", "password": "password123"}, output_file=f"test_apikey_{name.lower()}.json")
+    if resp.status_code != 201:
+        print(f"{Colors.FAIL}Critical: Failed to register {name}. Cannot proceed.{Colors.ENDC}")
+        sys.exit(1)
+    return resp.json()['user']['id'], {"Authorization": f"Bearer {resp.json()['tokens']['access']['token']}"}
+
+# 1. SETUP
+print(f"\n>> Step 1: Registering the key owner and another user...")
+user_id, user_headers = register("Owner")
+other_id, other_headers = register("Other")
+keys_url = f"{BASE_URL}/users/{user_id}/api-keys"
+
+# 2. Create a full access key
+print(f"\n>> Step 2: Creating a full access key...")
+resp = send_and_print(keys_url, headers=user_headers, method="POST", body={"name": "CI pipeline"}, output_file="test_apikey_create.json")
+check(resp.status_code == 201, "API key created (201).", f"Creation failed (Status: {resp.status_code}).")
+if resp.status_code != 201:
+    sys.exit(1)
+api_key = resp.json()['key']
+key_id = resp.json()['apiKey']['id']
+check(api_key.startswith(resp.json()['apiKey']['prefix']) and 'keyHash' not in resp.json()['apiKey'],
+      "Key shown once with its visible prefix, hash not exposed.", f"Unexpected body: {resp.json()}")
+
+# 3. Use the key with both header styles
+print(f"\n>> Step 3: Authenticating with the key...")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"Authorization": f"ApiKey {api_key}"}, output_file="test_apikey_use_auth.json")
+check(resp.status_code == 200, "Authorization: ApiKey accepted (200).", f"Key rejected (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": api_key}, output_file="test_apikey_use_header.json")
+check(resp.status_code == 200, "X-API-Key accepted (200).", f"Key rejected (Status: {resp.status_code}).")
+
+resp = send_and_print(keys_url, headers=user_headers, output_file="test_apikey_list.json")
+listed = resp.json() if resp.status_code == 200 else []
+check(len(listed) == 1 and listed[0].get('lastUsedAt'), "Key listed with its last-used time.", f"Unexpected list: {listed}")
+
+# 4. Scopes
+print(f"\n>> Step 4: A read-only key cannot write...")
+resp = send_and_print(keys_url, headers=user_headers, method="POST", body={"name": "Dashboard", "scopes": ["read"]}, output_file="test_apikey_create_read.json")
+read_key = resp.json()['key']
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": read_key}, output_file="test_apikey_read_get.json")
+check(resp.status_code == 200, "Read-only key can read (200).", f"Read refused (Status: {resp.status_code}).")
+resp = send_and_print(keys_url, headers={"X-API-Key": read_key}, method="POST", body={"name": "Escalation"}, output_file="test_apikey_read_post.json")
+check(resp.status_code == 403, "Read-only key cannot write (403).", f"Security Breach! Write allowed (Status: {resp.status_code}).")
+
+resp = send_and_print(keys_url, headers={"X-API-Key": api_key}, method="POST", body={"name": "Escalation"}, output_file="test_apikey_key_post.json")
+check(resp.status_code == 403, "A key cannot create another key (403).", f"Security Breach! Key minted by a key (Status: {resp.status_code}).")
+
+# Nor another way to sign in, or a change to MFA
+for path, name in [("auth/passkeys/register/options", "passkey_options"), ("auth/passkeys/register", "passkey_register"), ("auth/oidc/google/link", "oidc_link"), ("auth/mfa/enroll", "mfa_enroll"), ("auth/mfa/disable", "mfa_disable")]:
+    resp = send_and_print(f"{BASE_URL}/{path}", headers={"X-API-Key": api_key}, method="POST", body={}, output_file=f"test_apikey_{name}.json")
+    check(resp.status_code == 403, f"A key cannot use /{path} (403).", f"Security Breach! /{path} allowed (Status: {resp.status_code}).")
+
+resp = send_and_print(keys_url, headers=user_headers, method="POST", body={"name": "Bad", "scopes": ["admin"]}, output_file="test_apikey_bad_scope.json")
+check(resp.status_code == 400, "Unknown scope rejected (400).", f"Unknown scope accepted (Status: {resp.status_code}).")
+resp = send_and_print(keys_url, headers=user_headers, method="POST", body={"name": "Old", "expiresAt": "2020-01-01T00:00:00Z"}, output_file="test_apikey_past_expiry.json")
+check(resp.status_code == 400, "Expiry in the past rejected (400).", f"Past expiry accepted (Status: {resp.status_code}).")
+
+# 5. ATTACK: someone else's keys
+print(f"\n>> Step 5: Another user manages the owner's keys...")
+resp = send_and_print(keys_url, headers=other_headers, method="POST", body={"name": "Stolen"}, output_file="test_apikey_other_create.json")
+check(resp.status_code == 403, "Creating keys for another user forbidden (403).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{other_id}/api-keys/{key_id}", headers=other_headers, method="DELETE", output_file="test_apikey_other_delete.json")
+check(resp.status_code == 404, "Revoking through another user's path finds nothing (404).", f"Unexpected status {resp.status_code}.")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": "sk_" + "A" * 52}, output_file="test_apikey_garbage.json")
+check(resp.status_code == 401, "Unknown key rejected (401).", f"Security Breach! (Status: {resp.status_code}).")
+
+# 6. Revocation
+print(f"\n>> Step 6: Revoking the key...")
+resp = send_and_print(f"{keys_url}/{key_id}", headers=user_headers, method="DELETE", output_file="test_apikey_revoke.json")
+check(resp.status_code == 204, "Key revoked (204).", f"Revocation failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{user_id}", headers={"X-API-Key": api_key}, output_file="test_apikey_revoked_use.json")
+check(resp.status_code == 401, "Revoked key rejected (401).", f"Revoked key still works (Status: {resp.status_code}).")
+
+# 7. Service accounts
+print(f"\n>> Step 7: Service accounts...")
+admin_token = load_config("accessToken")
+if not admin_token:
+    print(f"{Colors.WARNING}No admin access token found, skipping. Run A2.auth_login.py as an admin first.{Colors.ENDC}")
+    sys.exit(0)
+admin_headers = {"Authorization": f"Bearer {admin_token}"}
+accounts_url = f"{BASE_URL}/service-accounts"
+
+resp = send_and_print(accounts_url, headers=user_headers, method="POST", body={"name": "Intruder"}, output_file="test_apikey_sa_create_user.json")
+check(resp.status_code == 403, "Regular users cannot create service accounts (403).", f"Security Breach! (Status: {resp.status_code}).")
+resp = send_and_print(accounts_url, headers=admin_headers, method="POST", body={"name": f"Nightly export {timestamp}"}, output_file="test_apikey_sa_create.json")
+account = resp.json() if resp.status_code == 201 else {}
+check(account.get('serviceAccount') is True, "Service account created (201).", f"Creation failed (Status: {resp.status_code}).")
+account_id = account.get('id')
+
+resp = send_and_print(accounts_url, headers=admin_headers, output_file="test_apikey_sa_list.json")
+listed = (resp.json() or {}).get('results', []) if resp.status_code == 200 else []
+check(any(a.get('id') == account_id for a in listed) and all(a.get('serviceAccount') for a in listed), "Only service accounts listed.", f"Unexpected list: {listed}")
+resp = send_and_print(f"{accounts_url}/{user_id}/api-keys", headers=admin_headers, method="POST", body={"name": "Not a service account"}, output_file="test_apikey_sa_human.json")
+check(resp.status_code == 404, "Human users are not service accounts (404).", f"Unexpected status {resp.status_code}.")
+
+resp = send_and_print(f"{accounts_url}/{account_id}/api-keys", headers=admin_headers, method="POST", body={"name": "Export job"}, output_file="test_apikey_sa_key.json")
+check(resp.status_code == 201, "Key issued to the service account (201).", f"Creation failed (Status: {resp.status_code}).")
+account_key = (resp.json() or {}).get('key', "")
+resp = send_and_print(f"{BASE_URL}/users/{account_id}", headers={"X-API-Key": account_key}, output_file="test_apikey_sa_use.json")
+check(resp.status_code == 200, "Service account acts with its key (200).", f"Key rejected (Status: {resp.status_code}).")
+resp = send_and_print(f"{accounts_url}/{account_id}/api-keys", headers={"X-API-Key": account_key}, method="POST", body={"name": "Escalation"}, output_file="test_apikey_sa_key_post.json")
+check(resp.status_code == 403, "Its key cannot create keys (403).", f"Security Breach! (Status: {resp.status_code}).")
+
+resp = send_and_print(f"{accounts_url}/{account_id}", headers=admin_headers, method="DELETE", output_file="test_apikey_sa_delete.json")
+check(resp.status_code == 204, "Service account deleted (204).", f"Deletion failed (Status: {resp.status_code}).")
+resp = send_and_print(f"{BASE_URL}/users/{account_id}", headers={"X-API-Key": account_key}, output_file="test_apikey_sa_deleted_use.json")
+check(resp.status_code == 401, "Keys of a deleted service account rejected (401).", f"Key still works (Status: {resp.status_code}).")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"starter-kit-restapi-gonethttp/config"
 	"starter-kit-restapi-gonethttp/internal/handlers"
@@ -11,6 +12,7 @@ import (
 	"starter-kit-restapi-gonethttp/internal/routes"
 	"starter-kit-restapi-gonethttp/internal/services"
 	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
 )
 
 func main() {
@@ -20,24 +22,93 @@ func main() {
 
 	config.ConnectDB(cfg)
 
+	passwordHasher, err := utils.NewPasswordHasher(cfg.PasswordHash)
+	if err != nil {
+		logger.Log.Error("Invalid password hashing configuration", "error", err)
+		os.Exit(1)
+	}
+	utils.SetPasswordHasher(passwordHasher)
+
 	userRepo := repository.NewUserRepository(config.DB)
 	tokenRepo := repository.NewTokenRepository(config.DB)
+	signingKeyRepo := repository.NewSigningKeyRepository(config.DB)
+	mfaRepo := repository.NewMFARecoveryCodeRepository(config.DB)
+	webAuthnRepo := repository.NewWebAuthnRepository(config.DB)
+	identityRepo := repository.NewIdentityRepository(config.DB)
+	oauthRepo := repository.NewOAuthRepository(config.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(config.DB)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(config.DB)
+	invitationRepo := repository.NewInvitationRepository(config.DB)
+	roleRepo := repository.NewRoleRepository(config.DB)
+	organizationRepo := repository.NewOrganizationRepository(config.DB)
+	groupRepo := repository.NewGroupRepository(config.DB)
+
+	rbacService := services.NewRBACService(roleRepo, userRepo)
+	if err := rbacService.EnsureDefaults(); err != nil {
+		logger.Log.Error("Failed to create the default roles", "error", err)
+		os.Exit(1)
+	}
+	rbacService.StartSync(time.Minute)
 
-	tokenService := services.NewTokenService(tokenRepo, cfg)
+	keyService, err := services.NewKeyService(signingKeyRepo, cfg)
+	if err != nil {
+		logger.Log.Error("Failed to load JWT signing keys", "error", err)
+		os.Exit(1)
+	}
+	keyService.StartRotation(time.Hour)
+
+	tokenService := services.NewTokenService(tokenRepo, roleRepo, groupRepo, organizationRepo, keyService, cfg)
 	emailService := services.NewEmailService(cfg)
-	userService := services.NewUserService(userRepo)
+	tokenDenylist := services.NewTokenDenylist(tokenRepo, cfg)
+	tokenDenylist.StartSync(time.Minute)
+	passwordPolicy, err := services.NewPasswordPolicy(passwordHistoryRepo, cfg)
+	if err != nil {
+		logger.Log.Error("Failed to load password policy", "error", err)
+		os.Exit(1)
+	}
+	userService := services.NewUserService(userRepo, tokenRepo, mfaRepo, organizationRepo, tokenDenylist, rbacService, passwordPolicy, cfg)
+	userService.StartPurge(time.Hour)
+	userService.StartSync(time.Minute)
 	
-	authService := services.NewAuthService(userRepo, tokenRepo, tokenService, emailService, cfg)
+	authService := services.NewAuthService(userRepo, tokenRepo, mfaRepo, organizationRepo, tokenService, emailService, tokenDenylist, passwordPolicy, cfg)
+
+	passkeyService, err := services.NewPasskeyService(webAuthnRepo, userRepo, tokenService, cfg)
+	if err != nil {
+		logger.Log.Error("Invalid WebAuthn configuration", "error", err)
+		os.Exit(1)
+	}
+
+	oidcService := services.NewOIDCService(identityRepo, userRepo, tokenService, cfg)
+	oauthService := services.NewOAuthService(oauthRepo, userRepo, tokenRepo, tokenService, tokenDenylist, cfg)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	serviceAccountService := services.NewServiceAccountService(userRepo, apiKeyService)
+	groupService := services.NewGroupService(groupRepo, roleRepo, userRepo, rbacService)
+	organizationService := services.NewOrganizationService(organizationRepo, invitationRepo, userRepo, emailService, rbacService, cfg)
+	invitationService, err := services.NewInvitationService(invitationRepo, userRepo, roleRepo, tokenService, emailService, passwordPolicy, cfg)
+	if err != nil {
+		logger.Log.Error("Invalid registration configuration", "error", err)
+		os.Exit(1)
+	}
+
+	policyService, err := services.NewPolicyService(cfg)
+	if err != nil {
+		logger.Log.Error("Failed to load the access policy", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Policy.ReloadSeconds > 0 {
+		policyService.StartWatch(time.Duration(cfg.Policy.ReloadSeconds) * time.Second)
+	}
 
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, passkeyService, oidcService, invitationService)
 	userHandler := handlers.NewUserHandler(userService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
 
-	router := routes.RegisterRoutes(cfg, authHandler, userHandler, userService)
+	router := routes.RegisterRoutes(cfg, authHandler, userHandler, oauthHandler, userService, apiKeyService, serviceAccountService, rbacService, groupService, policyService, organizationService, keyService, tokenDenylist)
 
 	serverAddr := fmt.Sprintf(":%s", cfg.Port)
 	logger.Log.Info("Server listening", "address", serverAddr)
 	
-	err := http.ListenAndServe(serverAddr, router)
+	err = http.ListenAndServe(serverAddr, router)
 	if err != nil {
 		logger.Log.Error("Server failed to start", "error", err)
 		os.Exit(1)
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +14,21 @@ type Config struct {
 	Database DatabaseConfig
 	JWT      JWTConfig
 	SMTP     SMTPConfig
+	MFA      MFAConfig
+	WebAuthn WebAuthnConfig
+	OIDC     map[string]OIDCProviderConfig // Keyed by provider name as used in URLs
+	OAuth    OAuthConfig
+	Lockout  LockoutConfig
+	Password PasswordPolicy
+	// Hashing of stored passwords
+	PasswordHash PasswordHashConfig
+	MagicLink    MagicLinkConfig
+	Users        UsersConfig
+	Registration RegistrationConfig
+	// Email verification before sign-in
+	EmailVerification EmailVerificationConfig
+	// Attribute-based access policies
+	Policy PolicyConfig
 }
 
 type DatabaseConfig struct {
@@ -26,11 +42,111 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret                       string
-	AccessExpirationMinutes      int
-	RefreshExpirationDays        int
+	Secret                         string
+	Algorithm                      string // HS256 (shared secret), RS256 or EdDSA
+	KeyRotationDays                int    // 0 disables automatic rotation
+	KeyGraceDays                   int    // How long retired keys still verify tokens
+	AccessExpirationMinutes        int
+	RefreshExpirationDays          int
 	ResetPasswordExpirationMinutes int
 	VerifyEmailExpirationMinutes   int
+	EmailChangeExpirationMinutes   int // Lifetime of the confirmation and cancel links of an email change
+	MFAExpirationMinutes           int // Lifetime of the token between password and second factor
+}
+
+type MFAConfig struct {
+	Issuer string // Shown as the account name prefix in authenticator apps
+}
+
+type WebAuthnConfig struct {
+	RPID          string // Relying party ID, the site's registrable domain (no scheme or port)
+	RPDisplayName string
+	RPOrigins     []string // Frontend origins allowed to run the ceremonies
+}
+
+// OIDCProviderConfig describes an external OpenID Connect identity provider
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string // Discovery is done against <Issuer>/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Where the provider sends the browser back with the code
+	Scopes       []string
+}
+
+// OAuthConfig configures this service as an OAuth2 authorization server
+type OAuthConfig struct {
+	AccessExpirationMinutes int
+	RefreshExpirationDays   int
+	CodeExpirationMinutes   int    // Lifetime of authorization codes
+	DefaultAudience         string // "aud" of tokens for clients without configured audiences
+}
+
+// LockoutConfig throttles password logins. Every failure doubles the wait before the
+// next attempt on the account, and MaxAttempts failures lock it for DurationMinutes.
+type LockoutConfig struct {
+	MaxAttempts     int // Failures per account before it is locked, 0 disables the lockout
+	IPMaxAttempts   int // Failures per source IP (any account) before it is locked out, 0 disables
+	DurationMinutes int // Length of a lockout, also how long failures are remembered
+	DelaySeconds    int // Wait after the first failure, 0 disables the progressive delay
+	MaxDelaySeconds int
+}
+
+// PasswordPolicy is enforced whenever a password is set
+type PasswordPolicy struct {
+	MinLength            int
+	MaxLength            int // In bytes; bcrypt cannot hash more than 72
+	RequireUpper         bool
+	RequireLower         bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	DisallowPersonalInfo bool   // Reject passwords containing the name or the email's local part
+	BreachedRangesDir    string // Directory of SHA-1 range files of breached passwords, empty disables the check
+	HistorySize          int    // Last passwords (the current one included) that cannot be reused, 0 disables
+}
+
+// PasswordHashConfig selects how new passwords are hashed. Hashes made with another
+// algorithm or other parameters keep working and are upgraded at the next login.
+type PasswordHashConfig struct {
+	Algorithm         string // argon2id or bcrypt
+	Argon2Memory      int    // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+}
+
+type UsersConfig struct {
+	DeletedRetentionDays int // Days a deleted user can be restored before it is purged, 0 keeps them forever
+}
+
+const (
+	RegistrationModeOpen       = "open"        // Anyone can register
+	RegistrationModeInviteOnly = "invite-only" // Accounts come from invitations and admins
+	RegistrationModeClosed     = "closed"      // Only admins create accounts
+)
+
+// RegistrationConfig decides who can create an account
+type RegistrationConfig struct {
+	Mode                      string
+	InvitationExpirationHours int
+}
+
+type EmailVerificationConfig struct {
+	Required              bool // Refuse sign-in until the email is verified
+	ResendCooldownSeconds int  // Minimum time between verification emails to the same user
+}
+
+// PolicyConfig locates the access policy document (see services.Policy)
+type PolicyConfig struct {
+	File          string // JSON policy document, the built-in rules are used when empty
+	ReloadSeconds int    // How often the file is checked for changes, 0 disables hot reloading
+}
+
+// MagicLinkConfig configures passwordless login through a link sent by email
+type MagicLinkConfig struct {
+	ExpirationMinutes int
+	MaxRequests       int // Links that can be requested per email address within WindowMinutes, 0 disables the limit
+	WindowMinutes     int
 }
 
 type SMTPConfig struct {
@@ -59,11 +175,16 @@ func LoadConfig() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret:                       getEnv("JWT_SECRET", "secret"),
-			AccessExpirationMinutes:      getEnvAsInt("JWT_ACCESS_EXPIRATION_MINUTES", 30),
-			RefreshExpirationDays:        getEnvAsInt("JWT_REFRESH_EXPIRATION_DAYS", 30),
+			Secret:                         getEnv("JWT_SECRET", "secret"),
+			Algorithm:                      getEnv("JWT_ALGORITHM", "HS256"),
+			KeyRotationDays:                getEnvAsInt("JWT_KEY_ROTATION_DAYS", 30),
+			KeyGraceDays:                   getEnvAsInt("JWT_KEY_GRACE_DAYS", getEnvAsInt("JWT_REFRESH_EXPIRATION_DAYS", 30)),
+			AccessExpirationMinutes:        getEnvAsInt("JWT_ACCESS_EXPIRATION_MINUTES", 30),
+			RefreshExpirationDays:          getEnvAsInt("JWT_REFRESH_EXPIRATION_DAYS", 30),
 			ResetPasswordExpirationMinutes: getEnvAsInt("JWT_RESET_PASSWORD_EXPIRATION_MINUTES", 10),
 			VerifyEmailExpirationMinutes:   getEnvAsInt("JWT_VERIFY_EMAIL_EXPIRATION_MINUTES", 10),
+			EmailChangeExpirationMinutes:   getEnvAsInt("JWT_EMAIL_CHANGE_EXPIRATION_MINUTES", 60),
+			MFAExpirationMinutes:           getEnvAsInt("JWT_MFA_EXPIRATION_MINUTES", 5),
 		},
 		SMTP: SMTPConfig{
 			Host:     getEnv("SMTP_HOST", ""),
@@ -72,7 +193,86 @@ func LoadConfig() *Config {
 			Password: getEnv("SMTP_PASSWORD", ""),
 			From:     getEnv("EMAIL_FROM", ""),
 		},
+		MFA: MFAConfig{
+			Issuer: getEnv("MFA_ISSUER", "Starter Kit"),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:          getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPDisplayName: getEnv("WEBAUTHN_RP_NAME", "Starter Kit"),
+			RPOrigins:     getEnvAsSlice("WEBAUTHN_RP_ORIGINS", []string{"http://localhost:3000"}),
+		},
+		OIDC: loadOIDCProviders(),
+		OAuth: OAuthConfig{
+			AccessExpirationMinutes: getEnvAsInt("OAUTH_ACCESS_EXPIRATION_MINUTES", 60),
+			RefreshExpirationDays:   getEnvAsInt("OAUTH_REFRESH_EXPIRATION_DAYS", 30),
+			CodeExpirationMinutes:   getEnvAsInt("OAUTH_CODE_EXPIRATION_MINUTES", 5),
+			DefaultAudience:         getEnv("OAUTH_DEFAULT_AUDIENCE", "starter-kit-api"),
+		},
+		Password: PasswordPolicy{
+			MinLength:            getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:            getEnvAsInt("PASSWORD_MAX_LENGTH", 72),
+			RequireUpper:         getEnvAsBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:         getEnvAsBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:         getEnvAsBool("PASSWORD_REQUIRE_DIGIT", false),
+			RequireSymbol:        getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+			DisallowPersonalInfo: getEnvAsBool("PASSWORD_DISALLOW_PERSONAL_INFO", true),
+			BreachedRangesDir:    getEnv("PASSWORD_BREACHED_RANGES_DIR", ""),
+			HistorySize:          getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+		},
+		PasswordHash: PasswordHashConfig{
+			Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			Argon2Memory:      getEnvAsInt("PASSWORD_HASH_ARGON2_MEMORY_KIB", 19456),
+			Argon2Iterations:  getEnvAsInt("PASSWORD_HASH_ARGON2_ITERATIONS", 2),
+			Argon2Parallelism: getEnvAsInt("PASSWORD_HASH_ARGON2_PARALLELISM", 1),
+			BcryptCost:        getEnvAsInt("PASSWORD_HASH_BCRYPT_COST", 10),
+		},
+		MagicLink: MagicLinkConfig{
+			ExpirationMinutes: getEnvAsInt("MAGIC_LINK_EXPIRATION_MINUTES", 10),
+			MaxRequests:       getEnvAsInt("MAGIC_LINK_MAX_REQUESTS", 3),
+			WindowMinutes:     getEnvAsInt("MAGIC_LINK_WINDOW_MINUTES", 15),
+		},
+		Users: UsersConfig{
+			DeletedRetentionDays: getEnvAsInt("USER_DELETED_RETENTION_DAYS", 30),
+		},
+		Registration: RegistrationConfig{
+			Mode:                      getEnv("REGISTRATION_MODE", RegistrationModeOpen),
+			InvitationExpirationHours: getEnvAsInt("INVITATION_EXPIRATION_HOURS", 72),
+		},
+		EmailVerification: EmailVerificationConfig{
+			Required:              getEnvAsBool("EMAIL_VERIFICATION_REQUIRED", false),
+			ResendCooldownSeconds: getEnvAsInt("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECONDS", 60),
+		},
+		Policy: PolicyConfig{
+			File:          getEnv("POLICY_FILE", ""),
+			ReloadSeconds: getEnvAsInt("POLICY_RELOAD_SECONDS", 10),
+		},
+		Lockout: LockoutConfig{
+			MaxAttempts:     getEnvAsInt("LOCKOUT_MAX_ATTEMPTS", 5),
+			IPMaxAttempts:   getEnvAsInt("LOCKOUT_IP_MAX_ATTEMPTS", 50),
+			DurationMinutes: getEnvAsInt("LOCKOUT_DURATION_MINUTES", 15),
+			DelaySeconds:    getEnvAsInt("LOCKOUT_DELAY_SECONDS", 1),
+			MaxDelaySeconds: getEnvAsInt("LOCKOUT_MAX_DELAY_SECONDS", 30),
+		},
+	}
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (e.g. "google,microsoft") and the
+// OIDC_<NAME>_* variables of each listed provider
+func loadOIDCProviders() map[string]OIDCProviderConfig {
+	providers := make(map[string]OIDCProviderConfig)
+	for _, name := range getEnvAsSlice("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[name] = OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       getEnvAsSlice(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+		}
 	}
+	return providers
 }
 
 func getEnv(key, fallback string) string {
@@ -88,4 +288,27 @@ func getEnvAsInt(key string, fallback int) int {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsSlice reads a comma-separated list
+func getEnvAsSlice(key string, fallback []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return fallback
+	}
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
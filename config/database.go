@@ -45,7 +45,7 @@ func ConnectDB(cfg *Config) {
 	}
 
 	// Auto Migrate the schema (creates tables based on structs)
-	err = DB.AutoMigrate(&models.User{}, &models.Token{})
+	err = DB.AutoMigrate(&models.User{}, &models.Token{}, &models.SigningKey{}, &models.MFARecoveryCode{}, &models.WebAuthnCredential{}, &models.WebAuthnSession{}, &models.Identity{}, &models.OIDCAuthRequest{}, &models.OAuthClient{}, &models.OAuthAuthorizationCode{}, &models.APIKey{}, &models.PasswordHistory{}, &models.Invitation{}, &models.Permission{}, &models.Role{}, &models.Organization{}, &models.Membership{}, &models.Group{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	service services.APIKeyService
+}
+
+func NewAPIKeyHandler(service services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// Otherwise a scoped or expiring key could mint itself an unrestricted one
+	if authenticatedWithAPIKey(r) {
+		response.Error(w, http.StatusForbidden, "API keys cannot create API keys, sign in to create one")
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	key, secret, err := h.service.CreateKey(userID, req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The key is only ever shown here
+	response.Success(w, http.StatusCreated, map[string]interface{}{
+		"apiKey": key,
+		"key":    secret,
+	})
+}
+
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	keys, err := h.service.ListKeys(userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	keyID, err := strconv.ParseUint(r.PathValue("keyId"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.service.RevokeKey(userID, uint(keyID)); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
@@ -2,23 +2,27 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"starter-kit-restapi-gonethttp/internal/middleware"
-	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/services"
 	"starter-kit-restapi-gonethttp/pkg/response"
 	"starter-kit-restapi-gonethttp/pkg/utils"
-
-	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	service services.AuthService
+	service     services.AuthService
+	passkeys    services.PasskeyService
+	oidc        services.OIDCService
+	invitations services.InvitationService
 }
 
-func NewAuthHandler(service services.AuthService) *AuthHandler {
-	return &AuthHandler{service: service}
+func NewAuthHandler(service services.AuthService, passkeys services.PasskeyService, oidc services.OIDCService, invitations services.InvitationService) *AuthHandler {
+	return &AuthHandler{service: service, passkeys: passkeys, oidc: oidc, invitations: invitations}
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -34,15 +38,24 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, tokens, err := h.service.Register(req)
+	if writeRegistrationClosed(w, err) || writePasswordPolicyError(w, err) {
+		return
+	}
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	h.trackSession(r, tokens)
 
-	response.JSON(w, http.StatusCreated, map[string]interface{}{
+	body := map[string]interface{}{
 		"user":   user,
 		"tokens": tokens,
-	})
+	}
+	// No tokens: the email has to be verified first
+	if tokens == nil {
+		body["message"] = "Check your inbox to verify your email. If no link arrives, ask for a new one with POST /v1/auth/resend-verification-email"
+	}
+	response.JSON(w, http.StatusCreated, body)
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -52,11 +65,27 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	user, tokens, err := h.service.Login(req.Email, req.Password)
+	user, tokens, err := h.service.Login(req.Email, req.Password, middleware.ClientIP(r))
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		// Password was correct; the client must now call /auth/mfa/verify
+		writeMFARequired(w, mfaErr)
+		return
+	}
+	if writeAccountStatusError(w, err) {
+		return
+	}
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		response.Error(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
 	if err != nil {
 		response.Error(w, http.StatusUnauthorized, err.Error())
 		return
 	}
+	h.trackSession(r, tokens)
 
 	response.Success(w, http.StatusOK, map[string]interface{}{
 		"user":   user,
@@ -64,13 +93,55 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writePasswordPolicyError answers a password rejected by the policy like any other validation error,
+// with the broken rules as tags. It reports whether err was such an error.
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	response.JSON(w, http.StatusBadRequest, map[string]interface{}{
+		"code":    400,
+		"message": "Validation error",
+		"errors": map[string]string{
+			"Password": "Failed validation: " + strings.Join(policyErr.Violations, ","),
+		},
+	})
+	return true
+}
+
+// writeAccountStatusError answers 403 for a suspended, banned or pending account, or one
+// whose email must be verified first. It reports whether err was such an error.
+func writeAccountStatusError(w http.ResponseWriter, err error) bool {
+	var statusErr *services.AccountStatusError
+	if !errors.As(err, &statusErr) && !errors.Is(err, services.ErrEmailNotVerified) {
+		return false
+	}
+	response.Error(w, http.StatusForbidden, err.Error())
+	return true
+}
+
+// writeMFARequired answers a first factor that succeeded with the token for the second one
+func writeMFARequired(w http.ResponseWriter, mfaErr *services.MFARequiredError) {
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"mfaRequired": true,
+		"mfaToken": map[string]interface{}{
+			"token":   mfaErr.Token,
+			"expires": mfaErr.Expires,
+		},
+	})
+}
+
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		RefreshToken string `json:"refreshToken"`
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := h.service.Logout(req.RefreshToken); err != nil {
+	// The access token is optional; when sent it is revoked together with the session
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if err := h.service.Logout(req.RefreshToken, accessToken); err != nil {
 		response.Error(w, http.StatusNotFound, "Not found")
 		return
 	}
@@ -78,6 +149,62 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, http.StatusNoContent, nil)
 }
 
+func (h *AuthHandler) RefreshTokens(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	tokens, err := h.service.RefreshAuth(req.RefreshToken)
+	if writeAccountStatusError(w, err) {
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Please authenticate")
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.Success(w, http.StatusOK, tokens)
+}
+
+// SwitchOrganization exchanges the refresh token for tokens acting in another organization
+func (h *AuthHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	var req services.SwitchOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	tokens, err := h.service.SwitchOrganization(req.RefreshToken, req.OrganizationID)
+	if writeAccountStatusError(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrNotOrganizationMember) {
+		response.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Please authenticate")
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.Success(w, http.StatusOK, tokens)
+}
+
 func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email" validate:"required,email"`
@@ -110,7 +237,11 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.ResetPassword(token, req.Password); err != nil {
+	err := h.service.ResetPassword(token, req.Password)
+	if writePasswordPolicyError(w, err) {
+		return
+	}
+	if err != nil {
 		response.Error(w, http.StatusUnauthorized, "Password reset failed")
 		return
 	}
@@ -119,28 +250,49 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AuthHandler) SendVerificationEmail(w http.ResponseWriter, r *http.Request) {
-	userIDStr, ok := r.Context().Value(middleware.UserIDKey).(string)
+	id, ok := currentUserID(r)
 	if !ok {
 		response.Error(w, http.StatusUnauthorized, "User not found in context")
 		return
 	}
 
-	id, err := uuid.Parse(userIDStr)
+	err := h.service.SendVerificationEmail(id)
+	if writeRateLimited(w, err) {
+		return
+	}
 	if err != nil {
-		response.Error(w, http.StatusUnauthorized, "Invalid user ID")
+		response.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Create a partial user object to pass to service
-	// In a real app, you might want to fetch the full user from DB if Email is needed and not in context
-	user := &models.User{ID: id}
+	response.Success(w, http.StatusNoContent, nil)
+}
 
-	if err := h.service.SendVerificationEmail(user); err != nil {
+// ResendVerificationEmail is for users who cannot sign in yet; like RequestMagicLink it gives the
+// same answer for a valid email whether or not an unverified account uses it
+func (h *AuthHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	err := h.service.ResendVerificationEmail(req.Email)
+	if writeRateLimited(w, err) {
+		return
+	}
+	if err != nil {
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	response.Success(w, http.StatusNoContent, nil)
+	response.Success(w, http.StatusOK, map[string]string{"message": "If an unverified account uses this email, a verification link has been sent to it"})
 }
 
 func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
@@ -156,4 +308,159 @@ func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if err := h.service.UnlockAccount(token); err != nil {
+		response.Error(w, http.StatusUnauthorized, "Account unlock failed")
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfaToken" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	user, tokens, err := h.service.VerifyMFA(req.MFAToken, req.Code)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"user":   user,
+		"tokens": tokens,
+	})
+}
+
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "manage MFA") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	enrollment, err := h.service.EnrollMFA(userID)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "manage MFA") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.service.ConfirmMFA(userID, code)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Recovery codes are only ever shown here
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "manage MFA") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DisableMFA(userID, code); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "manage MFA") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.service.RegenerateRecoveryCodes(userID, code)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+// decodeMFACode reads {"code": "..."} from the body, writing the error response itself on failure
+func decodeMFACode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return "", false
+	}
+	return req.Code, true
 }
\ No newline at end of file
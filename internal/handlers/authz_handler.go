@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+type AuthzHandler struct {
+	policy services.Policy
+}
+
+func NewAuthzHandler(policy services.Policy) *AuthzHandler {
+	return &AuthzHandler{policy: policy}
+}
+
+// Check evaluates the given attributes against the access policy, to debug its rules
+func (h *AuthzHandler) Check(w http.ResponseWriter, r *http.Request) {
+	var req services.AuthzRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+	response.Success(w, http.StatusOK, h.policy.Evaluate(req))
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"starter-kit-restapi-gonethttp/internal/middleware"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/pkg/response"
+
+	"github.com/google/uuid"
+)
+
+// currentUserID returns the authenticated user's ID placed in the context by middleware.Auth
+func currentUserID(r *http.Request) (uuid.UUID, bool) {
+	userIDStr, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// currentSessionID returns the session of the access token, empty when the request has none (API keys)
+func currentSessionID(r *http.Request) string {
+	sessionID, _ := r.Context().Value(middleware.SessionIDKey).(string)
+	return sessionID
+}
+
+// authenticatedWithAPIKey reports whether an API key, rather than an access token, authenticated the request
+func authenticatedWithAPIKey(r *http.Request) bool {
+	_, ok := r.Context().Value(middleware.APIKeyIDKey).(uint)
+	return ok
+}
+
+// writeAPIKeyRefused answers 403 when an API key authenticated the request. Routes adding a way to
+// sign in or changing MFA use it, so a scoped or expiring key cannot become a lasting credential.
+func writeAPIKeyRefused(w http.ResponseWriter, r *http.Request, action string) bool {
+	if !authenticatedWithAPIKey(r) {
+		return false
+	}
+	response.Error(w, http.StatusForbidden, "API keys cannot "+action+", sign in to do it")
+	return true
+}
+
+// currentOrganizationID returns the active organization of the access token, empty outside organizations
+func currentOrganizationID(r *http.Request) string {
+	organizationID, _ := r.Context().Value(middleware.OrganizationIDKey).(string)
+	return organizationID
+}
+
+// hasPermission reports whether the roles of the authenticated user grant the permission
+func hasPermission(r *http.Request, permission string) bool {
+	permissions, _ := r.Context().Value(middleware.PermissionsKey).([]string)
+	return slices.Contains(permissions, permission)
+}
+
+// writeRoleNotAssignable answers 403 unless the authenticated user may give a new account the role.
+// Any role other than the default needs users:roles, so creating or inviting users does not hand out admin.
+func writeRoleNotAssignable(w http.ResponseWriter, r *http.Request, role string) bool {
+	if role == models.RoleUser || hasPermission(r, models.PermissionUserRolesManage) {
+		return false
+	}
+	response.Error(w, http.StatusForbidden, "Assigning the role "+role+" requires the "+models.PermissionUserRolesManage+" permission")
+	return true
+}
+
+// writePermissionsNotGrantable answers 403 unless the user may grant the permissions through a role
+// or a group. Holders of roles:manage grant any, others only those they hold, so users:roles or
+// groups:manage alone cannot be used to gain more.
+func writePermissionsNotGrantable(w http.ResponseWriter, r *http.Request, permissions []string) bool {
+	if hasPermission(r, models.PermissionRolesManage) {
+		return false
+	}
+	for _, permission := range permissions {
+		if !hasPermission(r, permission) {
+			response.Error(w, http.StatusForbidden, "Granting "+permission+" requires holding it or the "+models.PermissionRolesManage+" permission")
+			return true
+		}
+	}
+	return false
+}
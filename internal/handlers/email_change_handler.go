@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+// RequestEmailChange starts changing the signed-in user's email. Nothing changes until the
+// link sent to the new address is followed.
+func (h *AuthHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	var req struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	err := h.service.RequestEmailChange(userID, req.Email, req.Password)
+	if errors.Is(err, services.ErrIncorrectPassword) {
+		response.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusAccepted, map[string]interface{}{"pendingEmail": req.Email})
+}
+
+func (h *AuthHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if err := h.service.ConfirmEmailChange(token); err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) CancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if err := h.service.CancelEmailChange(token); err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type GroupHandler struct {
+	service services.GroupService
+}
+
+func NewGroupHandler(service services.GroupService) *GroupHandler {
+	return &GroupHandler{service: service}
+}
+
+func (h *GroupHandler) GetGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.service.GetGroups()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, groups)
+}
+
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	group, err := h.service.GetGroup(id)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, group)
+}
+
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	if writePermissionsNotGrantable(w, r, req.Permissions) {
+		return
+	}
+
+	group, err := h.service.CreateGroup(req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, group)
+}
+
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+	var req services.UpdateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	if writePermissionsNotGrantable(w, r, req.Permissions) {
+		return
+	}
+
+	group, err := h.service.UpdateGroup(id, req)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, group)
+}
+
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteGroup(id); err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *GroupHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	users, err := h.service.GetMembers(id)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, users)
+}
+
+// AddMembers puts users in the group and returns all its members
+func (h *GroupHandler) AddMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+	var req services.AddGroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	// Joining grants the group's permissions, so the same limit applies as for setting them
+	group, err := h.service.GetGroup(id)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	if writePermissionsNotGrantable(w, r, models.PermissionNames(nil, []models.Group{*group})) {
+		return
+	}
+
+	users, err := h.service.AddMembers(id, req)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, users)
+}
+
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid group ID")
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	if err := h.service.RemoveMember(id, userID); err != nil {
+		writeGroupError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *GroupHandler) GetUserGroups(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	groups, err := h.service.GetUserGroups(userID)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, groups)
+}
+
+func writeGroupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrGroupNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Error(w, http.StatusBadRequest, err.Error())
+}
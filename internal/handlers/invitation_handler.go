@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+func (h *AuthHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req services.CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	if writeRoleNotAssignable(w, r, req.Role) {
+		return
+	}
+
+	invitation, err := h.invitations.CreateInvitation(adminID, req)
+	if writeRegistrationClosed(w, err) {
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, invitation)
+}
+
+// GetInvitations lists the invitations that were neither accepted nor expired
+func (h *AuthHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.invitations.ListPending()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, invitations)
+}
+
+func (h *AuthHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.invitations.RevokeInvitation(uint(id)); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+// AcceptInvitation creates the invited account and signs it in, like Register
+func (h *AuthHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	var req services.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	user, tokens, err := h.invitations.AcceptInvitation(token, req)
+	if writeRegistrationClosed(w, err) || writePasswordPolicyError(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrInvalidInvitation) {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.JSON(w, http.StatusCreated, map[string]interface{}{
+		"user":   user,
+		"tokens": tokens,
+	})
+}
+
+// writeRegistrationClosed answers 403 when the registration mode refuses new accounts. It reports whether it did.
+func writeRegistrationClosed(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, services.ErrRegistrationInviteOnly) && !errors.Is(err, services.ErrRegistrationClosed) {
+		return false
+	}
+	response.Error(w, http.StatusForbidden, err.Error())
+	return true
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+)
+
+type KeyHandler struct {
+	service *services.KeyService
+}
+
+func NewKeyHandler(service *services.KeyService) *KeyHandler {
+	return &KeyHandler{service: service}
+}
+
+// JWKS publishes the public keys downstream services use to verify our tokens
+func (h *KeyHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.Success(w, http.StatusOK, h.service.JWKS())
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+// RequestMagicLink always gives the same answer for a valid email, whether or not an account uses it
+func (h *AuthHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	err := h.service.RequestMagicLink(req.Email)
+	if writeRateLimited(w, err) {
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]string{"message": "If an account uses this email, a login link has been sent to it"})
+}
+
+func (h *AuthHandler) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	user, tokens, err := h.service.MagicLinkLogin(token)
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		writeMFARequired(w, mfaErr)
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"user":   user,
+		"tokens": tokens,
+	})
+}
+
+// writeRateLimited answers 429 with Retry-After. It reports whether err was a RateLimitedError.
+func writeRateLimited(w http.ResponseWriter, err error) bool {
+	var limited *services.RateLimitedError
+	if !errors.As(err, &limited) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	response.Error(w, http.StatusTooManyRequests, err.Error())
+	return true
+}
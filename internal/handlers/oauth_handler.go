@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+type OAuthHandler struct {
+	service services.OAuthService
+}
+
+func NewOAuthHandler(service services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{service: service}
+}
+
+// Token is the RFC 6749 token endpoint. It takes form parameters and answers in the OAuth2 format.
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &services.OAuthError{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.service.Token(client, services.OAuthTokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Scope:        r.PostForm.Get("scope"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+	})
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, res)
+}
+
+// Introspect is the RFC 7662 introspection endpoint for resource servers and gateways
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		writeOAuthError(w, &services.OAuthError{Code: "invalid_request", Description: "token is required"})
+		return
+	}
+
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.service.Introspect(client, r.PostForm.Get("token"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, res)
+}
+
+// Revoke is the RFC 7009 revocation endpoint. It answers 200 whether or not the token was valid.
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		writeOAuthError(w, &services.OAuthError{Code: "invalid_request", Description: "token is required"})
+		return
+	}
+
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(client, r.PostForm.Get("token")); err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AuthorizationRequest validates the request and returns what the consent screen should show
+func (h *OAuthHandler) AuthorizationRequest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := services.OAuthAuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	client, scope, err := h.service.ValidateAuthorization(req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"client": map[string]interface{}{
+			"id":   client.ID,
+			"name": client.Name,
+		},
+		"scopes":      strings.Fields(scope),
+		"redirectUri": req.RedirectURI,
+		"state":       req.State,
+	})
+}
+
+// Authorize records the signed-in user's consent and returns the redirect back to the client
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	var req struct {
+		services.OAuthAuthorizeRequest
+		Approved bool `json:"approved"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	redirectURI, err := h.service.Authorize(userID, req.OAuthAuthorizeRequest, req.Approved)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"redirectUri": redirectURI,
+	})
+}
+
+func (h *OAuthHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	var req services.OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	client, secret, err := h.service.CreateClient(req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The secret is only ever shown here
+	response.Success(w, http.StatusCreated, clientWithSecret(client, secret))
+}
+
+func (h *OAuthHandler) GetClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.service.GetClients()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, clients)
+}
+
+func (h *OAuthHandler) GetClient(w http.ResponseWriter, r *http.Request) {
+	client, err := h.service.GetClient(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, client)
+}
+
+func (h *OAuthHandler) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	var req services.UpdateOAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	client, err := h.service.UpdateClient(r.PathValue("id"), req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, client)
+}
+
+func (h *OAuthHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteClient(r.PathValue("id")); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *OAuthHandler) RotateClientSecret(w http.ResponseWriter, r *http.Request) {
+	client, secret, err := h.service.RotateClientSecret(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, clientWithSecret(client, secret))
+}
+
+// authenticateClient reads client credentials from HTTP Basic auth or the form body (RFC 6749 section 2.3.1)
+func (h *OAuthHandler) authenticateClient(w http.ResponseWriter, r *http.Request) (*models.OAuthClient, bool) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		// Basic credentials are form-encoded before being base64 encoded
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := h.service.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, err)
+		return nil, false
+	}
+	return client, true
+}
+
+func clientWithSecret(client *models.OAuthClient, secret string) map[string]interface{} {
+	return map[string]interface{}{
+		"client":       client,
+		"clientSecret": secret,
+	}
+}
+
+// writeOAuthError answers with an RFC 6749 section 5.2 error body
+func writeOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		logger.Log.Error("OAuth request failed", "error", err)
+		response.JSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+	}
+	response.JSON(w, status, map[string]string{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+)
+
+func (h *AuthHandler) ListOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	response.Success(w, http.StatusOK, h.oidc.Providers())
+}
+
+// OIDCAuthorize returns the provider URL the client should send the browser to
+func (h *AuthHandler) OIDCAuthorize(w http.ResponseWriter, r *http.Request) {
+	url, err := h.oidc.AuthorizationURL(r.PathValue("provider"), nil)
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"authorizationUrl": url,
+	})
+}
+
+// OIDCLink starts the same flow for a signed-in user; the callback then links instead of signing in
+func (h *AuthHandler) OIDCLink(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "link identities") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	url, err := h.oidc.AuthorizationURL(r.PathValue("provider"), &userID)
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"authorizationUrl": url,
+	})
+}
+
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		response.Error(w, http.StatusUnauthorized, "Identity provider returned an error: "+providerErr)
+		return
+	}
+	if query.Get("code") == "" || query.Get("state") == "" {
+		response.Error(w, http.StatusBadRequest, "Missing code or state")
+		return
+	}
+
+	result, err := h.oidc.Callback(r.PathValue("provider"), query.Get("state"), query.Get("code"))
+	var mfaErr *services.MFARequiredError
+	if errors.As(err, &mfaErr) {
+		writeMFARequired(w, mfaErr)
+		return
+	}
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	if result.Linked {
+		response.Success(w, http.StatusOK, map[string]interface{}{
+			"linked":   true,
+			"identity": result.Identity,
+		})
+		return
+	}
+	h.trackSession(r, result.Tokens)
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"user":   result.User,
+		"tokens": result.Tokens,
+	})
+}
+
+func (h *AuthHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	identities, err := h.oidc.ListIdentities(userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, identities)
+}
+
+func (h *AuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid identity ID")
+		return
+	}
+
+	if err := h.oidc.Unlink(userID, uint(id)); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func writeOIDCError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrUnknownOIDCProvider) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if writeRegistrationClosed(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrOIDCProviderUnavailable) {
+		response.Error(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	response.Error(w, http.StatusUnauthorized, err.Error())
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationHandler struct {
+	service services.OrganizationService
+}
+
+func NewOrganizationHandler(service services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{service: service}
+}
+
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req services.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	organization, err := h.service.CreateOrganization(userID, req)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, organization)
+}
+
+// GetOrganizations lists the memberships of the signed-in user, each with its organization.
+// Super-admins list every organization with ?all=true.
+func (h *OrganizationHandler) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if r.URL.Query().Get("all") == "true" {
+		if !actor.SuperAdmin {
+			response.Error(w, http.StatusForbidden, "Forbidden: missing permission "+models.PermissionOrgsManage)
+			return
+		}
+		organizations, err := h.service.ListAll()
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response.Success(w, http.StatusOK, organizations)
+		return
+	}
+
+	memberships, err := h.service.ListMemberships(actor.UserID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, memberships)
+}
+
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	organization, err := h.service.GetOrganization(actor, r.PathValue("id"))
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, organization)
+}
+
+func (h *OrganizationHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req services.UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	organization, err := h.service.UpdateOrganization(actor, r.PathValue("id"), req)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, organization)
+}
+
+func (h *OrganizationHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.service.DeleteOrganization(actor, r.PathValue("id")); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *OrganizationHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	members, err := h.service.ListMembers(actor, r.PathValue("id"))
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, members)
+}
+
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	var req services.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	membership, err := h.service.UpdateMemberRole(actor, r.PathValue("id"), userID, req)
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, membership)
+}
+
+// RemoveMember takes a member out of the organization; members remove themselves to leave
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	if err := h.service.RemoveMember(actor, r.PathValue("id"), userID); err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *OrganizationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req services.CreateOrganizationInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	invitation, err := h.service.CreateInvitation(actor, r.PathValue("id"), req)
+	if writeRegistrationClosed(w, err) {
+		return
+	}
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusCreated, invitation)
+}
+
+// GetInvitations lists the invitations into the organization that were neither accepted nor expired
+func (h *OrganizationHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	invitations, err := h.service.ListInvitations(actor, r.PathValue("id"))
+	if err != nil {
+		writeOrganizationError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, invitations)
+}
+
+func (h *OrganizationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	actor, ok := organizationActor(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	invitationID, err := strconv.ParseUint(r.PathValue("invitationId"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.service.RevokeInvitation(actor, r.PathValue("id"), uint(invitationID)); err != nil {
+		if errors.Is(err, services.ErrOrganizationNotFound) || errors.Is(err, services.ErrOrganizationForbidden) {
+			writeOrganizationError(w, err)
+			return
+		}
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+// AcceptInvitation makes the signed-in user a member of the organization it was invited to.
+// Invitees without an account accept through POST /v1/auth/invitations/accept instead.
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Error(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	membership, err := h.service.AcceptInvitation(userID, token)
+	if errors.Is(err, services.ErrInvalidInvitation) {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, membership)
+}
+
+// organizationActor describes the signed-in user to the organization service
+func organizationActor(r *http.Request) (services.OrganizationActor, bool) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		return services.OrganizationActor{}, false
+	}
+	return services.OrganizationActor{
+		UserID:             userID,
+		SuperAdmin:         hasPermission(r, models.PermissionOrgsManage),
+		ManagesInvitations: hasPermission(r, models.PermissionInvitationsManage),
+	}, true
+}
+
+func writeOrganizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound), errors.Is(err, services.ErrNotOrganizationMember):
+		response.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrOrganizationForbidden):
+		response.Error(w, http.StatusForbidden, err.Error())
+	default:
+		response.Error(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+// passkeyFinishRequest carries the PublicKeyCredential produced by navigator.credentials
+type passkeyFinishRequest struct {
+	SessionID  string          `json:"sessionId" validate:"required"`
+	Name       string          `json:"name" validate:"omitempty,max=64"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (h *AuthHandler) PasskeyRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "register passkeys") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	options, sessionID, err := h.passkeys.BeginRegistration(userID)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"sessionId": sessionID,
+		"options":   options,
+	})
+}
+
+func (h *AuthHandler) PasskeyRegister(w http.ResponseWriter, r *http.Request) {
+	if writeAPIKeyRefused(w, r, "register passkeys") {
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	req, ok := decodePasskeyFinish(w, r)
+	if !ok {
+		return
+	}
+
+	credential, err := h.passkeys.FinishRegistration(userID, req.SessionID, req.Name, req.Credential)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusCreated, credential)
+}
+
+func (h *AuthHandler) PasskeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	// Email is optional: without it the browser offers any discoverable passkey
+	var req struct {
+		Email string `json:"email" validate:"omitempty,email"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	options, sessionID, err := h.passkeys.BeginLogin(req.Email)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"sessionId": sessionID,
+		"options":   options,
+	})
+}
+
+func (h *AuthHandler) PasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePasskeyFinish(w, r)
+	if !ok {
+		return
+	}
+
+	user, tokens, err := h.passkeys.FinishLogin(req.SessionID, req.Credential)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	h.trackSession(r, tokens)
+
+	response.Success(w, http.StatusOK, map[string]interface{}{
+		"user":   user,
+		"tokens": tokens,
+	})
+}
+
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	credentials, err := h.passkeys.ListCredentials(userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, credentials)
+}
+
+func (h *AuthHandler) RenamePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" validate:"required,max=64"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	credential, err := h.passkeys.RenameCredential(userID, uint(id), req.Name)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusOK, credential)
+}
+
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid passkey ID")
+		return
+	}
+
+	if err := h.passkeys.DeleteCredential(userID, uint(id)); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+// decodePasskeyFinish reads and validates a ceremony result, writing the error response itself on failure
+func decodePasskeyFinish(w http.ResponseWriter, r *http.Request) (*passkeyFinishRequest, bool) {
+	var req passkeyFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return nil, false
+	}
+	return &req, true
+}
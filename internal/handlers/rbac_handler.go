@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type RBACHandler struct {
+	service services.RBACService
+}
+
+func NewRBACHandler(service services.RBACService) *RBACHandler {
+	return &RBACHandler{service: service}
+}
+
+func (h *RBACHandler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.service.GetPermissions()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, permissions)
+}
+
+func (h *RBACHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	var req services.CreatePermissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	permission, err := h.service.CreatePermission(req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, permission)
+}
+
+func (h *RBACHandler) UpdatePermission(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid permission ID")
+	if !ok {
+		return
+	}
+	var req services.UpdatePermissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	permission, err := h.service.UpdatePermission(id, req)
+	if err != nil {
+		writeRBACError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, permission)
+}
+
+func (h *RBACHandler) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid permission ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeletePermission(id); err != nil {
+		writeRBACError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *RBACHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.GetRoles()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, roles)
+}
+
+func (h *RBACHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid role ID")
+	if !ok {
+		return
+	}
+
+	role, err := h.service.GetRole(id)
+	if err != nil {
+		writeRBACError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, role)
+}
+
+func (h *RBACHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	role, err := h.service.CreateRole(req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, role)
+}
+
+func (h *RBACHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid role ID")
+	if !ok {
+		return
+	}
+	var req services.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	role, err := h.service.UpdateRole(id, req)
+	if err != nil {
+		writeRBACError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, role)
+}
+
+func (h *RBACHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseRBACID(w, r, "Invalid role ID")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteRole(id); err != nil {
+		writeRBACError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *RBACHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	roles, err := h.service.GetUserRoles(userID)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, roles)
+}
+
+// SetUserRoles replaces all roles of a user
+func (h *RBACHandler) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	var req services.SetUserRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	// The roles must not grant more than the caller holds, or users:roles would lead to admin
+	all, err := h.service.GetRoles()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	assigned := slices.DeleteFunc(all, func(role models.Role) bool { return !slices.Contains(req.Roles, role.Name) })
+	if writePermissionsNotGrantable(w, r, models.PermissionNames(assigned, nil)) {
+		return
+	}
+
+	roles, err := h.service.SetUserRoles(userID, req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, roles)
+}
+
+func parseRBACID(w http.ResponseWriter, r *http.Request, message string) (uint, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, message)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeRBACError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrRoleNotFound) || errors.Is(err, services.ErrPermissionNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Error(w, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/response"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+type ServiceAccountHandler struct {
+	service services.ServiceAccountService
+}
+
+func NewServiceAccountHandler(service services.ServiceAccountService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{service: service}
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	account, err := h.service.CreateServiceAccount(req)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusCreated, account)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = 10
+	}
+
+	result, err := h.service.GetServiceAccounts(page, limit)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, result)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccount(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServiceAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.service.GetServiceAccount(id)
+	if err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, account)
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServiceAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteServiceAccount(id); err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *ServiceAccountHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// Same rule as for personal keys: a key cannot mint another one
+	if authenticatedWithAPIKey(r) {
+		response.Error(w, http.StatusForbidden, "API keys cannot create API keys, sign in to create one")
+		return
+	}
+	id, ok := parseServiceAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	key, secret, err := h.service.CreateKey(id, req)
+	if err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+
+	// The key is only ever shown here
+	response.Success(w, http.StatusCreated, map[string]interface{}{
+		"apiKey": key,
+		"key":    secret,
+	})
+}
+
+func (h *ServiceAccountHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServiceAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListKeys(id)
+	if err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+	response.Success(w, http.StatusOK, keys)
+}
+
+func (h *ServiceAccountHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseServiceAccountID(w, r)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.ParseUint(r.PathValue("keyId"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.service.RevokeKey(id, uint(keyID)); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func parseServiceAccountID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid service account ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeServiceAccountError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrServiceAccountNotFound) {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Error(w, http.StatusBadRequest, err.Error())
+}
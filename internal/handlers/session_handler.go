@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+
+	"starter-kit-restapi-gonethttp/internal/middleware"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/response"
+
+	"github.com/google/uuid"
+)
+
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	h.writeSessions(w, userID)
+}
+
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	if err := h.service.RevokeSession(userID, r.PathValue("id")); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+// LogoutAll signs the user out on every device, including this one
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		response.Error(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+
+	if err := h.service.LogoutAll(userID); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	h.writeSessions(w, userID)
+}
+
+func (h *AuthHandler) RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	if err := h.service.RevokeSession(userID, r.PathValue("sessionId")); err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+// LogoutUser force-logs out a (possibly compromised) account everywhere
+func (h *AuthHandler) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	if err := h.service.LogoutAll(userID); err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *AuthHandler) writeSessions(w http.ResponseWriter, userID uuid.UUID) {
+	sessions, err := h.service.ListSessions(userID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, sessions)
+}
+
+// trackSession stores the device behind a newly issued token pair. Failing to do so does not fail the sign-in.
+func (h *AuthHandler) trackSession(r *http.Request, tokens map[string]interface{}) {
+	refresh, _ := tokens["refresh"].(map[string]interface{})
+	refreshToken, _ := refresh["token"].(string)
+	if refreshToken == "" {
+		return
+	}
+	if err := h.service.TrackSession(refreshToken, r.UserAgent(), middleware.ClientIP(r)); err != nil {
+		logger.Log.Error("Failed to record session device", "error", err)
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/services"
 	"starter-kit-restapi-gonethttp/pkg/response"
 	"starter-kit-restapi-gonethttp/pkg/utils"
@@ -30,7 +32,13 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
 		return
 	}
+	if writeRoleNotAssignable(w, r, req.Role) {
+		return
+	}
 	user, err := h.service.CreateUser(req)
+	if writePasswordPolicyError(w, err) {
+		return
+	}
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, err.Error())
 		return
@@ -59,11 +67,25 @@ func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	search := query.Get("search")
 	scope := query.Get("scope")
 	role := query.Get("role")
+	status := query.Get("status")
+	group := query.Get("group")
 
 	filters := map[string]interface{}{
-		"search": search,
-		"scope":  scope,
-		"role":   role,
+		"search":         search,
+		"scope":          scope,
+		"role":           role,
+		"status":         status,
+		"group":          group,
+		"includeDeleted": query.Get("includeDeleted") == "true",
+	}
+	// Without the global permission, the list is limited to the members of the active organization
+	if !hasPermission(r, models.PermissionUsersRead) {
+		organizationID := currentOrganizationID(r)
+		if organizationID == "" {
+			response.Error(w, http.StatusForbidden, "Forbidden: switch to an organization to list its members")
+			return
+		}
+		filters["organizationId"] = organizationID
 	}
 
 	result, err := h.service.GetUsers(filters, page, limit, sortBy)
@@ -110,6 +132,9 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := h.service.UpdateUser(id, req)
+	if writePasswordPolicyError(w, err) {
+		return
+	}
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, err.Error())
 		return
@@ -130,4 +155,122 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response.Success(w, http.StatusNoContent, nil)
-}
\ No newline at end of file
+}
+
+// RestoreUser undoes the deletion of a user that has not been purged yet
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	user, err := h.service.RestoreUser(id)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, "Deleted user not found")
+		return
+	}
+	response.Success(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	if err := h.service.ResetMFA(id); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			response.Error(w, http.StatusNotFound, "User not found")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+	if err := h.service.UnlockUser(id); err != nil {
+		response.Error(w, http.StatusNotFound, "User not found")
+		return
+	}
+	response.Success(w, http.StatusNoContent, nil)
+}
+
+func (h *UserHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	var req services.SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	user, err := h.service.SuspendUser(id, req)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		response.JSON(w, http.StatusBadRequest, map[string]interface{}{"code": 400, "message": "Validation error", "errors": errs})
+		return
+	}
+
+	user, err := h.service.BanUser(id, req.Reason)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid User ID")
+		return
+	}
+
+	user, err := h.service.ReactivateUser(id)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	response.Success(w, http.StatusOK, user)
+}
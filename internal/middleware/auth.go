@@ -2,49 +2,182 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
-	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/services"
 	"starter-kit-restapi-gonethttp/pkg/response"
 	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey    contextKey = "userID"
+	SessionIDKey contextKey = "sessionID" // Refresh token family of the access token, absent for API keys
+	APIKeyIDKey  contextKey = "apiKeyID"  // ID of the API key that authenticated the request, absent for access tokens
+	// Names of the roles and groups of the user and of the permissions they grant, see RequirePermission
+	RolesKey       contextKey = "roles"
+	GroupsKey      contextKey = "groups"
+	PermissionsKey contextKey = "permissions"
+	// Active organization of the session and the user's role in it, absent outside organizations
+	OrganizationIDKey   contextKey = "organizationID"
+	OrganizationRoleKey contextKey = "organizationRole"
+)
 
-func Auth(cfg *config.Config) func(http.Handler) http.Handler {
+// Auth authenticates the request with a bearer access token or a personal API key. Access tokens bring
+// the user's permissions and are refused once the permissions version changed, which deleting the user
+// or changing their status also does. API keys have the user and their permissions looked up.
+func Auth(keys utils.KeyProvider, denylist services.TokenDenylist, apiKeys services.APIKeyService, users services.UserService, rbac services.RBACService, groups services.GroupService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+				authenticateAPIKey(w, r, next, apiKeys, users, rbac, groups, apiKey)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
 				response.Error(w, http.StatusUnauthorized, "Missing Authorization header")
 				return
 			}
 
-			// Format: "Bearer <token>"
+			// Format: "Bearer <token>" or "ApiKey <key>"
 			parts := strings.Split(authHeader, " ")
+			if len(parts) == 2 && parts[0] == "ApiKey" {
+				authenticateAPIKey(w, r, next, apiKeys, users, rbac, groups, parts[1])
+				return
+			}
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				response.Error(w, http.StatusUnauthorized, "Invalid Authorization header format")
 				return
 			}
 
 			tokenString := parts[1]
-			claims, err := utils.ValidateToken(tokenString, cfg.JWT.Secret)
+			claims, err := utils.ValidateToken(tokenString, keys)
 			if err != nil {
 				response.Error(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
 			}
 
-			if claims.Type != "access" {
+			// Tokens issued to OAuth clients are meant for the services in their audience, not this API
+			if claims.Type != "access" || claims.ClientID != "" {
 				response.Error(w, http.StatusUnauthorized, "Invalid token type")
 				return
 			}
 
-			// Add UserID to context
+			if denylist.IsRevoked(claims) {
+				response.Error(w, http.StatusUnauthorized, "Token has been revoked")
+				return
+			}
+			if !requireCurrentPermissions(w, rbac, claims) {
+				return
+			}
+
+			// Add UserID and permissions to context
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.Sub)
+			ctx = context.WithValue(ctx, RolesKey, claims.Roles)
+			ctx = context.WithValue(ctx, GroupsKey, claims.Groups)
+			ctx = context.WithValue(ctx, PermissionsKey, claims.Permissions)
+			if claims.SessionID != "" {
+				ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
+			}
+			if claims.OrganizationID != "" {
+				ctx = context.WithValue(ctx, OrganizationIDKey, claims.OrganizationID)
+				ctx = context.WithValue(ctx, OrganizationRoleKey, claims.OrganizationRole)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
-}
\ No newline at end of file
+}
+
+// authenticateAPIKey lets a personal API key act as its owner. Keys limited to the
+// read scope may only make safe requests.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeys services.APIKeyService, users services.UserService, rbac services.RBACService, groups services.GroupService, secret string) {
+	key, err := apiKeys.Authenticate(secret)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or expired API key")
+		return
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+	if !safe && !key.Allows(models.APIKeyScopeWrite) {
+		response.Error(w, http.StatusForbidden, "API key does not allow write access")
+		return
+	}
+	if !requireActiveUser(w, users, key.UserID) {
+		return
+	}
+
+	id, _ := uuid.Parse(key.UserID)
+	roles, err := rbac.GetUserRoles(id)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to load permissions")
+		return
+	}
+	userGroups, err := groups.GetUserGroups(id)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to load permissions")
+		return
+	}
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+	groupNames := make([]string, 0, len(userGroups))
+	for _, group := range userGroups {
+		groupNames = append(groupNames, group.Name)
+	}
+
+	ctx := context.WithValue(r.Context(), UserIDKey, key.UserID)
+	ctx = context.WithValue(ctx, APIKeyIDKey, key.ID)
+	ctx = context.WithValue(ctx, RolesKey, roleNames)
+	ctx = context.WithValue(ctx, GroupsKey, groupNames)
+	ctx = context.WithValue(ctx, PermissionsKey, models.PermissionNames(roles, userGroups))
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// requireActiveUser refuses requests of deleted users and of accounts that are not active. Only API keys
+// need it, access tokens are refused through the permissions version.
+func requireActiveUser(w http.ResponseWriter, users services.UserService, userID string) bool {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid User ID")
+		return false
+	}
+	user, err := users.GetUserByID(id)
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "User not found")
+		return false
+	}
+	if !user.IsActive(time.Now()) {
+		response.Error(w, http.StatusForbidden, "Account is "+user.Status)
+		return false
+	}
+	return true
+}
+
+// requireCurrentPermissions refuses access tokens issued before the user's permissions last changed,
+// and those of deleted users. Refreshing gets a token with the current ones.
+func requireCurrentPermissions(w http.ResponseWriter, rbac services.RBACService, claims *utils.TokenPayload) bool {
+	id, _ := uuid.Parse(claims.Sub)
+	version, err := rbac.PermissionsVersion(id)
+	if errors.Is(err, services.ErrUserNotFound) {
+		response.Error(w, http.StatusUnauthorized, "User not found")
+		return false
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to check permissions")
+		return false
+	}
+	if claims.PermissionsVersion != version {
+		response.Error(w, http.StatusUnauthorized, "Permissions have changed, refresh the token")
+		return false
+	}
+	return true
+}
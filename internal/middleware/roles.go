@@ -1,41 +1,35 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
 
 	"starter-kit-restapi-gonethttp/internal/services"
+	"starter-kit-restapi-gonethttp/pkg/logger"
 	"starter-kit-restapi-gonethttp/pkg/response"
 
 	"github.com/google/uuid"
 )
 
-// RequireAdmin ensures the authenticated user has the 'admin' role.
-func RequireAdmin(service services.UserService) func(http.Handler) http.Handler {
+// RequirePermission lets through only users whose roles grant the permission, e.g.
+// requirePermission("users:delete"). The permissions come from the access token (see Auth).
+func RequirePermission(permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// 1. Get UserID from context (set by Auth middleware)
-			userIDStr, ok := r.Context().Value(UserIDKey).(string)
-			if !ok {
+			if _, ok := r.Context().Value(UserIDKey).(string); !ok {
 				response.Error(w, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
-			// 2. Fetch User from DB
-			id, err := uuid.Parse(userIDStr)
-			if err != nil {
-				response.Error(w, http.StatusUnauthorized, "Invalid User ID")
-				return
-			}
-
-			user, err := service.GetUserByID(id)
-			if err != nil {
-				response.Error(w, http.StatusUnauthorized, "User not found")
-				return
-			}
-
-			// 3. Check Role
-			if user.Role != "admin" {
-				response.Error(w, http.StatusForbidden, "Forbidden: Admins only")
+			// 2. Check the permission against the ones granted to the user
+			if !hasPermission(w, r, permission) {
 				return
 			}
 
@@ -44,33 +38,126 @@ func RequireAdmin(service services.UserService) func(http.Handler) http.Handler
 	}
 }
 
-// RequireAdminOrSelf ensures the user is admin OR accessing their own resource (for Get One).
-func RequireAdminOrSelf(service services.UserService) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userIDStr, ok := r.Context().Value(UserIDKey).(string)
-			if !ok {
-				response.Error(w, http.StatusUnauthorized, "Unauthorized")
-				return
-			}
+// Authorize returns a factory of middlewares that ask the access policy whether the user may perform
+// the action on the user whose ID is the {id} path value, e.g. authorize("users:update"). The policy
+// sees the user's roles, groups, permissions and active organization, the target's roles, status and
+// organizations, and the fields of the body. Without {id} there is no resource.
+func Authorize(policy services.Policy, users services.UserService) func(action string) func(http.Handler) http.Handler {
+	return func(action string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userIDStr, ok := r.Context().Value(UserIDKey).(string)
+				if !ok {
+					response.Error(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				fields, err := bodyFields(w, r)
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					response.Error(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				if err != nil {
+					response.Error(w, http.StatusBadRequest, "Invalid request body")
+					return
+				}
 
-			targetID := r.PathValue("id")
+				decision := policy.Evaluate(services.AuthzRequest{
+					Subject: map[string]interface{}{
+						"id":          userIDStr,
+						"roles":       r.Context().Value(RolesKey),
+						"groups":      r.Context().Value(GroupsKey),
+						"permissions": r.Context().Value(PermissionsKey),
+						// Unset outside organizations
+						"organizationId":   r.Context().Value(OrganizationIDKey),
+						"organizationRole": r.Context().Value(OrganizationRoleKey),
+					},
+					Action:   action,
+					Resource: userResource(users, r.PathValue("id")),
+					Request: map[string]interface{}{
+						"method": r.Method,
+						"path":   r.URL.Path,
+						"ip":     ClientIP(r),
+						"fields": fields,
+					},
+				})
+				if !decision.Allowed {
+					// The reason names the policy's rules, which are for the logs only
+					logger.Log.Info("Request denied by the access policy", "userId", userIDStr, "action", action, "path", r.URL.Path, "reason", decision.Reason)
+					response.Error(w, http.StatusForbidden, "Forbidden")
+					return
+				}
 
-			// Check if Self
-			if targetID == userIDStr {
 				next.ServeHTTP(w, r)
-				return
-			}
+			})
+		}
+	}
+}
 
-			// If not self, Check if Admin
-			id, _ := uuid.Parse(userIDStr)
-			user, err := service.GetUserByID(id)
-			if err != nil || user.Role != "admin" {
-				response.Error(w, http.StatusForbidden, "Forbidden: Access denied")
-				return
-			}
+// hasPermission answers 403 unless the user holds the permission, and reports whether it does
+func hasPermission(w http.ResponseWriter, r *http.Request, permission string) bool {
+	permissions, _ := r.Context().Value(PermissionsKey).([]string)
+	if !slices.Contains(permissions, permission) {
+		response.Error(w, http.StatusForbidden, "Forbidden: missing permission "+permission)
+		return false
+	}
+	return true
+}
 
-			next.ServeHTTP(w, r)
-		})
+// userResource describes the user a request acts on. Only the ID is known when it does not exist.
+func userResource(users services.UserService, id string) map[string]interface{} {
+	if id == "" {
+		return nil
+	}
+	resource := map[string]interface{}{"type": "user", "id": id}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return resource
+	}
+	if attributes, err := users.GetUserAttributes(userID); err == nil {
+		resource["role"] = attributes.Role
+		resource["roles"] = attributes.Roles
+		resource["status"] = attributes.Status
+		resource["isEmailVerified"] = attributes.IsEmailVerified
+		resource["organizationIds"] = attributes.OrganizationIDs
+	}
+	return resource
+}
+
+// maxPolicyBodyBytes bounds the bodies read for the policy. Larger ones are refused rather than
+// evaluated on a part of their fields.
+const maxPolicyBodyBytes = 1 << 20
+
+// bodyFields lists the top-level keys of a JSON body in lowercase, the way they are matched to
+// request fields, and leaves the body for the handler
+func bodyFields(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	fields := []string{}
+	if r.Body == nil || r.Body == http.NoBody {
+		return fields, nil
 	}
-}
\ No newline at end of file
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolicyBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var object map[string]json.RawMessage
+	if json.Unmarshal(body, &object) != nil {
+		// Not an object, the handler rejects it
+		return fields, nil
+	}
+	for key := range object {
+		fields = append(fields, strings.ToLower(key))
+	}
+	slices.Sort(fields)
+	return fields, nil
+}
+
+// ClientIP returns the address of the peer that sent the request
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKey is a long-lived credential for scripts and CI jobs acting as a user. Only the hash is stored.
+type APIKey struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	UserID     string     `gorm:"type:uuid;index;not null" json:"userId"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // First characters of the key, to tell keys apart
+	KeyHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     string     `json:"scopes"` // Space separated, empty means full access
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// Allows reports whether the key grants the scope. Keys without scopes grant everything.
+func (k *APIKey) Allows(scope string) bool {
+	return strings.TrimSpace(k.Scopes) == "" || containsField(k.Scopes, scope)
+}
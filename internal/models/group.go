@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Group gathers users, e.g. a team such as "billing" or "support". Its permissions are granted to
+// every member, on top of the permissions of their roles.
+type Group struct {
+	ID          uint         `gorm:"primary_key" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"many2many:group_permissions;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
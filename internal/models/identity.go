@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Identity links an account at an external OpenID Connect provider to a user
+type Identity struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	UserID      string     `gorm:"type:uuid;index;not null" json:"userId"`
+	User        User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Provider    string     `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"provider"`
+	Subject     string     `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"subject"` // "sub" claim at the provider
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// OIDCAuthRequest keeps the PKCE verifier and nonce of an authorization request until the callback
+type OIDCAuthRequest struct {
+	State        string    `gorm:"primary_key" json:"state"`
+	Provider     string    `gorm:"not null" json:"provider"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	UserID       string    `gorm:"index" json:"userId"` // Set when a signed-in user is linking an identity
+	Expires      time.Time `gorm:"not null" json:"expires"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
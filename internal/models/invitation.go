@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation lets an admin bring in a user with a chosen role. The token is mailed to Email
+// and accepting it creates the account. Only its hash is stored (utils.HashSecret).
+type Invitation struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	Email      string     `gorm:"index;not null" json:"email"`
+	Role       string     `gorm:"not null" json:"role"`
+	Token      string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedBy  string     `gorm:"type:uuid" json:"invitedBy"` // Admin who sent it
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	// Set on invitations into an organization. Existing users accept them too, and only join.
+	OrganizationID   *string `gorm:"type:uuid;index" json:"organizationId,omitempty"`
+	OrganizationRole string  `json:"organizationRole,omitempty"`
+}
+
+// IsPending reports whether the invitation can still be accepted
+func (i *Invitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.ExpiresAt.After(now)
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// MFARecoveryCode is a single-use fallback for a lost authenticator. Only the hash is stored.
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	UserID    string     `gorm:"type:uuid;index;not null" json:"userId"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	CodeHash  string     `gorm:"index;not null" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// OAuthClient is an application allowed to request tokens from this service
+type OAuthClient struct {
+	ID           string    `gorm:"primary_key" json:"id"` // client_id
+	Name         string    `gorm:"not null" json:"name"`
+	SecretHash   string    `json:"-"`                             // Empty for public clients
+	Public       bool      `gorm:"default:false" json:"public"`   // Browser or mobile app that cannot keep a secret
+	RedirectURIs string    `gorm:"type:text" json:"redirectUris"` // Space separated, matched exactly
+	Scopes       string    `gorm:"type:text" json:"scopes"`       // Space separated scopes the client may request
+	GrantTypes   string    `gorm:"not null" json:"grantTypes"`    // Space separated
+	Audiences    string    `gorm:"type:text" json:"audiences"`    // Space separated "aud" values of issued tokens
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (OAuthClient) TableName() string {
+	return "clients"
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return containsField(c.GrantTypes, grantType)
+}
+
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsField(c.RedirectURIs, uri)
+}
+
+// OAuthAuthorizationCode is issued on consent and exchanged once at the token endpoint. Only the hash is stored.
+type OAuthAuthorizationCode struct {
+	CodeHash      string      `gorm:"primary_key" json:"-"`
+	ClientID      string      `gorm:"index;not null" json:"clientId"`
+	Client        OAuthClient `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID        string      `gorm:"type:uuid;index;not null" json:"userId"`
+	User          User        `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	RedirectURI   string      `gorm:"not null" json:"redirectUri"`
+	Scope         string      `json:"scope"`
+	CodeChallenge string      `gorm:"not null" json:"-"` // PKCE, S256 only
+	Expires       time.Time   `gorm:"not null" json:"expires"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
+
+func containsField(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Roles of a member within an organization, unrelated to the global roles of Role
+const (
+	OrgRoleOwner  = "owner"  // Everything, including deleting the organization and naming owners
+	OrgRoleAdmin  = "admin"  // Manages members and invitations, sees the members in /v1/users
+	OrgRoleMember = "member" // Sees the organization and its members
+)
+
+// Organization is a tenant. Users belong to it through memberships.
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BeforeCreate is a GORM hook that generates a UUID before saving
+func (o *Organization) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return
+}
+
+// Membership puts a user in an organization with an organization role (see OrgRoleOwner)
+type Membership struct {
+	ID             uint          `gorm:"primary_key" json:"id"`
+	OrganizationID string        `gorm:"type:uuid;not null;uniqueIndex:idx_memberships_organization_user" json:"organizationId"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"organization,omitempty"`
+	UserID         string        `gorm:"type:uuid;not null;uniqueIndex:idx_memberships_organization_user;index" json:"userId"`
+	User           *User         `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
+	Role           string        `gorm:"not null" json:"role"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
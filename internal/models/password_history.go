@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory keeps the hash of a password a user has replaced, so it cannot be set again
+type PasswordHistory struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	UserID    string    `gorm:"type:uuid;index;not null" json:"userId"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Hash      string    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Built-in permissions, required by the routes. Admins can add their own.
+const (
+	PermissionUsersCreate        = "users:create"
+	PermissionUsersRead          = "users:read"
+	PermissionUsersUpdate        = "users:update" // Also resets MFA and lifts lockouts
+	PermissionUsersDelete        = "users:delete" // Also restores deleted users
+	PermissionUsersStatus        = "users:status" // Suspend, ban and reactivate
+	PermissionUserRolesManage    = "users:roles"
+	PermissionSessionsManage     = "sessions:manage" // Sessions of other users
+	PermissionAPIKeysManage      = "api-keys:manage" // API keys of other users
+	PermissionInvitationsManage  = "invitations:manage"
+	PermissionOAuthClientsManage = "oauth-clients:manage"
+	PermissionRolesManage        = "roles:manage" // Roles and permissions themselves
+	PermissionAuthzCheck         = "authz:check"  // Ask the access policy for decisions
+	PermissionOrgsManage         = "organizations:manage"
+	PermissionGroupsManage       = "groups:manage"
+	PermissionServiceAccounts    = "service-accounts:manage" // Also their API keys
+)
+
+// Built-in roles. New users get RoleUser unless another role is given.
+const (
+	RoleAdmin = "admin" // Holds every built-in permission
+	RoleUser  = "user"
+)
+
+// DefaultPermissions are created at startup and granted to RoleAdmin
+var DefaultPermissions = []Permission{
+	{Name: PermissionUsersCreate, Description: "Create users"},
+	{Name: PermissionUsersRead, Description: "List and view users"},
+	{Name: PermissionUsersUpdate, Description: "Update users, reset their MFA and unlock them"},
+	{Name: PermissionUsersDelete, Description: "Delete and restore users"},
+	{Name: PermissionUsersStatus, Description: "Suspend, ban and reactivate users"},
+	{Name: PermissionUserRolesManage, Description: "Assign roles to users"},
+	{Name: PermissionSessionsManage, Description: "List and end the sessions of any user"},
+	{Name: PermissionAPIKeysManage, Description: "Manage the API keys of any user"},
+	{Name: PermissionInvitationsManage, Description: "Invite, list and revoke invitations"},
+	{Name: PermissionOAuthClientsManage, Description: "Manage OAuth2 clients"},
+	{Name: PermissionRolesManage, Description: "Manage roles and permissions"},
+	{Name: PermissionAuthzCheck, Description: "Evaluate access requests against the policy"},
+	{Name: PermissionOrgsManage, Description: "See and manage every organization, as its owner"},
+	{Name: PermissionGroupsManage, Description: "Manage groups, their members and permissions"},
+	{Name: PermissionServiceAccounts, Description: "Create and delete service accounts and manage their API keys"},
+}
+
+// Permission is the right to do one thing, named "resource:action"
+type Permission struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
+	Description string    `json:"description"`
+	BuiltIn     bool      `gorm:"default:false" json:"builtIn"` // Required by the API, cannot be deleted
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Role is a named set of permissions. Users can have several roles.
+type Role struct {
+	ID          uint         `gorm:"primary_key" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `json:"description"`
+	BuiltIn     bool         `gorm:"default:false" json:"builtIn"` // RoleAdmin and RoleUser, cannot be deleted
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
+
+// PermissionNames lists, sorted and once each, the names of the permissions the roles and groups
+// grant. They must have their Permissions loaded.
+func PermissionNames(roles []Role, groups []Group) []string {
+	var names []string
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			names = append(names, permission.Name)
+		}
+	}
+	for _, group := range groups {
+		for _, permission := range group.Permissions {
+			names = append(names, permission.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey stores an asymmetric JWT key pair. The newest key without RetiredAt signs
+// new tokens; retired keys keep verifying tokens until their grace window ends.
+type SigningKey struct {
+	ID         string     `gorm:"primary_key" json:"kid"`
+	Algorithm  string     `gorm:"not null" json:"alg"`
+	PrivateKey string     `gorm:"type:text;not null" json:"-"`
+	RetiredAt  *time.Time `json:"retiredAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
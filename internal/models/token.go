@@ -8,16 +8,38 @@ const (
 	TokenTypeRefresh       = "refresh"
 	TokenTypeResetPassword = "resetPassword"
 	TokenTypeVerifyEmail   = "verifyEmail"
+	TokenTypeMFAPending    = "mfaPending"    // Issued after the password step when MFA is enabled
+	TokenTypeOAuthRefresh  = "oauthRefresh"  // Refresh token of an OAuth2 client acting for a user
+	TokenTypeUnlockAccount = "unlockAccount" // Emailed when an account gets locked after failed logins
+	TokenTypeMagicLink     = "magicLink"     // Single-use passwordless login link
+	TokenTypeEmailChange   = "emailChange"   // Sent to the new address to confirm an email change
+	TokenTypeCancelEmail   = "cancelEmail"   // Sent to the old address to cancel an email change
+
+	// Denylist entries (always Blacklisted). TokenTypeAccess stores the jti of a
+	// single revoked access token; TokenTypeRevokeAll revokes every access token
+	// of UserID issued before CreatedAt; TokenTypeRevokeSession revokes every
+	// access token of the session (refresh token family) in Family.
+	TokenTypeAccess        = "access"
+	TokenTypeRevokeAll     = "revokeAll"
+	TokenTypeRevokeSession = "revokeSession"
 )
 
 type Token struct {
-	ID          uint      `gorm:"primary_key" json:"id"`
-	Token       string    `gorm:"index;not null" json:"token"`
-	UserID      string    `gorm:"type:uuid;not null" json:"userId"`
-	User        User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	Type        string    `gorm:"not null" json:"type"`
-	Expires     time.Time `gorm:"not null" json:"expires"`
-	Blacklisted bool      `gorm:"default:false" json:"blacklisted"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-}
\ No newline at end of file
+	ID          uint       `gorm:"primary_key" json:"id"`
+	Token       string     `gorm:"index;not null" json:"token"`
+	UserID      string     `gorm:"type:uuid;default:null" json:"userId"` // Null for revoked client_credentials tokens
+	User        User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Type        string     `gorm:"not null" json:"type"`
+	Family      string     `gorm:"index" json:"family,omitempty"`   // Refresh tokens issued from the same login share a family
+	Rotated     bool       `gorm:"default:false" json:"rotated"`    // Set once a refresh token has been exchanged for a new pair
+	ClientID    string     `gorm:"index" json:"clientId,omitempty"` // OAuth2 client the token was issued to
+	Expires     time.Time  `gorm:"not null" json:"expires"`
+	Blacklisted bool       `gorm:"default:false" json:"blacklisted"`
+	UserAgent   string     `json:"userAgent,omitempty"` // Device of the session a refresh token belongs to
+	IPAddress   string     `json:"ipAddress,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"` // A rotated refresh token keeps the start of its session
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	// Organization the session acts in, put in its access tokens (see the organization switcher)
+	OrganizationID string `gorm:"type:uuid;default:null" json:"organizationId,omitempty"`
+}
@@ -1,13 +1,23 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// Account statuses. Only active users can sign in or use their tokens.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended" // Temporarily, until SuspendedUntil if set
+	UserStatusBanned    = "banned"
+	UserStatusPending   = "pending" // Registered while email verification is required, active once verified
+)
+
 type User struct {
 	ID              uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
 	Name            string    `gorm:"not null" json:"name"`
@@ -15,8 +25,31 @@ type User struct {
 	Password        string    `gorm:"not null" json:"-"` // json:"-" prevents password from being returned in API
 	Role            string    `gorm:"default:'user'" json:"role"`
 	IsEmailVerified bool      `gorm:"default:false" json:"isEmailVerified"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	PendingEmail    string    `json:"pendingEmail,omitempty"` // Requested new address, swapped in once confirmed
+	MFAEnabled      bool      `gorm:"default:false" json:"mfaEnabled"`
+	MFASecret       string    `json:"-"`                  // Base32 TOTP secret, set at enrollment
+	MFALastUsedStep int64     `gorm:"default:0" json:"-"` // Last accepted TOTP step, blocks code replays
+	// Non-human account for scripts and other services: it cannot sign in and acts only through its API keys
+	ServiceAccount bool `gorm:"default:false;index" json:"serviceAccount"`
+	// Login lockout (see config.LockoutConfig)
+	FailedLoginAttempts int        `gorm:"default:0" json:"-"`
+	LastFailedLoginAt   *time.Time `json:"-"`
+	LockedUntil         *time.Time `json:"lockedUntil,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+	// Account status, set by admins
+	Status         string     `gorm:"default:'active';index" json:"status"`
+	StatusReason   string     `json:"statusReason,omitempty"` // Why the account was suspended or banned
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	// Soft delete: GORM leaves deleted users out of queries until they are restored or purged
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+	// Roles grant the permissions. Role above is the main one, always among them.
+	Roles []Role `gorm:"many2many:user_roles;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"roles,omitempty"`
+	// Goes up whenever the permissions granted by Roles or Groups change. Access tokens carry the version they were issued at.
+	// Only written on creation, then only bumped in SQL by the repositories.
+	PermissionsVersion int `gorm:"<-:create;not null;default:1" json:"-"`
+	// Groups grant permissions as well
+	Groups []Group `gorm:"many2many:user_groups;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"groups,omitempty"`
 }
 
 // BeforeCreate is a GORM hook that generates a UUID before saving
@@ -27,20 +60,40 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
-// BeforeSave is a GORM hook that hashes the password if it has been modified
+// AfterCreate is a GORM hook that grants the role named by Role (RoleUser when empty), unless
+// Roles were given. It fails, and the user is not created, if there is no such role.
+func (u *User) AfterCreate(tx *gorm.DB) (err error) {
+	if len(u.Roles) > 0 {
+		return
+	}
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+	var role Role
+	if err := tx.Where("name = ?", u.Role).First(&role).Error; err != nil {
+		return fmt.Errorf("unknown role %q", u.Role)
+	}
+	return tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", u.ID, role.ID).Error
+}
+
+// IsActive reports whether the account may be used at the given time. A suspension with an
+// end date is over once that date has passed.
+func (u *User) IsActive(now time.Time) bool {
+	switch u.Status {
+	case UserStatusActive, "":
+		return true
+	case UserStatusSuspended:
+		return u.SuspendedUntil != nil && !u.SuspendedUntil.After(now)
+	default:
+		return false
+	}
+}
+
+// BeforeSave is a GORM hook that refuses to store a password that was not hashed.
+// Services hash passwords with utils.HashPassword before saving the user.
 func (u *User) BeforeSave(tx *gorm.DB) (err error) {
-	if u.Password != "" && len(u.Password) < 60 { 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), 8)
-		if err != nil {
-			return err
-		}
-		u.Password = string(hashedPassword)
+	if u.Password != "" && !strings.HasPrefix(u.Password, "$") {
+		return errors.New("password must be hashed before saving")
 	}
 	return
 }
-
-// ComparePassword checks if the provided password matches the hashed password
-func (u *User) ComparePassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
-}
\ No newline at end of file
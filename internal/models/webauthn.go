@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	WebAuthnSessionRegistration = "registration"
+	WebAuthnSessionLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey registered by a user
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primary_key" json:"id"`
+	UserID          string     `gorm:"type:uuid;index;not null" json:"userId"`
+	User            User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name            string     `gorm:"not null" json:"name"`
+	CredentialID    string     `gorm:"uniqueIndex;not null" json:"credentialId"` // base64url
+	PublicKey       []byte     `gorm:"not null" json:"-"`                        // COSE encoded
+	AttestationType string     `json:"-"`
+	Transports      string     `json:"transports"` // comma separated
+	AAGUID          []byte     `json:"-"`
+	BackupEligible  bool       `gorm:"default:false" json:"backupEligible"` // Synced passkey (e.g. iCloud Keychain)
+	BackupState     bool       `gorm:"default:false" json:"backupState"`
+	SignCount       uint32     `json:"signCount"`
+	CloneWarning    bool       `gorm:"default:false" json:"cloneWarning"`
+	LastUsedAt      *time.Time `json:"lastUsedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// WebAuthnSession holds the challenge between the options and finish steps of a ceremony
+type WebAuthnSession struct {
+	ID        string    `gorm:"primary_key" json:"id"`
+	UserID    string    `gorm:"index" json:"userId"` // Empty for discoverable logins
+	Type      string    `gorm:"not null" json:"type"`
+	Data      string    `gorm:"type:text;not null" json:"-"` // JSON encoded webauthn.SessionData
+	Expires   time.Time `gorm:"not null" json:"expires"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepository) FindByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByUserID(userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at asc").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) FindByID(userID string, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+func (r *apiKeyRepository) Delete(key *models.APIKey) error {
+	return r.db.Delete(key).Error
+}
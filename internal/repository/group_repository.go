@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type groupRepository struct {
+	db *gorm.DB
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	return &groupRepository{db}
+}
+
+// Create inserts the group and grants it the permissions in group.Permissions, in one transaction
+func (r *groupRepository) Create(group *models.Group) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		permissions := group.Permissions
+		group.Permissions = nil
+		if err := tx.Omit(clause.Associations).Create(group).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		return tx.Model(group).Association("Permissions").Append(permissions)
+	})
+}
+
+func (r *groupRepository) FindAll() ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.Preload("Permissions").Order("name asc").Find(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) FindByID(id uint) (*models.Group, error) {
+	var group models.Group
+	err := r.db.Preload("Permissions").First(&group, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *groupRepository) FindByName(name string) (*models.Group, error) {
+	var group models.Group
+	err := r.db.Where("name = ?", name).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *groupRepository) Update(group *models.Group) error {
+	return r.db.Omit(clause.Associations).Save(group).Error
+}
+
+// Delete removes the group with its memberships and grants. Its members get a new permissions version.
+func (r *groupRepository) Delete(group *models.Group) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, groupMembers(tx, group.ID)); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_groups WHERE group_id = ?", group.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM group_permissions WHERE group_id = ?", group.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
+
+// ReplacePermissions sets exactly the permissions of the group. Its members get a new permissions version.
+func (r *groupRepository) ReplacePermissions(group *models.Group, permissions []models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, groupMembers(tx, group.ID)); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM group_permissions WHERE group_id = ?", group.ID).Error; err != nil {
+			return err
+		}
+		group.Permissions = nil
+		if len(permissions) == 0 {
+			return nil
+		}
+		return tx.Model(group).Association("Permissions").Append(permissions)
+	})
+}
+
+// FindMembers lists the users of the group. Deleted users are left out.
+func (r *groupRepository) FindMembers(groupID uint) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("id IN (?)", groupMembers(r.db, groupID)).Order("name asc").Find(&users).Error
+	return users, err
+}
+
+// AddMembers puts the users in the group, skipping those already in it. They get a new permissions version.
+func (r *groupRepository) AddMembers(group *models.Group, userIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			if err := tx.Exec("INSERT INTO user_groups (user_id, group_id) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM user_groups WHERE user_id = ? AND group_id = ?)",
+				userID, group.ID, userID, group.ID).Error; err != nil {
+				return err
+			}
+		}
+		return bumpPermissionsVersions(tx, userIDs)
+	})
+}
+
+// RemoveMember takes the user out of the group. It reports false if the user was not in it.
+func (r *groupRepository) RemoveMember(group *models.Group, userID string) (bool, error) {
+	removed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM user_groups WHERE user_id = ? AND group_id = ?", userID, group.ID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return bumpPermissionsVersions(tx, []string{userID})
+	})
+	return removed, err
+}
+
+// FindUserGroups lists the groups of the user with their permissions
+func (r *groupRepository) FindUserGroups(userID string) ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.Preload("Permissions").
+		Joins("JOIN user_groups ON user_groups.group_id = groups.id").
+		Where("user_groups.user_id = ?", userID).
+		Order("groups.name asc").
+		Find(&groups).Error
+	return groups, err
+}
+
+// groupMembers selects the IDs of the users in the group
+func groupMembers(tx *gorm.DB, groupID uint) *gorm.DB {
+	return tx.Table("user_groups").Select("user_id").Where("group_id = ?", groupID)
+}
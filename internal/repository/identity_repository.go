@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db}
+}
+
+func (r *identityRepository) Create(identity *models.Identity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *identityRepository) FindByProviderSubject(provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) FindByUserID(userID string) ([]models.Identity, error) {
+	var identities []models.Identity
+	err := r.db.Where("user_id = ?", userID).Order("created_at asc").Find(&identities).Error
+	return identities, err
+}
+
+func (r *identityRepository) FindByID(userID string, id uint) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) Update(identity *models.Identity) error {
+	return r.db.Save(identity).Error
+}
+
+func (r *identityRepository) Delete(identity *models.Identity) error {
+	return r.db.Delete(identity).Error
+}
+
+func (r *identityRepository) CreateAuthRequest(request *models.OIDCAuthRequest) error {
+	return r.db.Create(request).Error
+}
+
+// ConsumeAuthRequest returns an unexpired request and deletes it so each state is used once
+func (r *identityRepository) ConsumeAuthRequest(state, provider string) (*models.OIDCAuthRequest, error) {
+	var request models.OIDCAuthRequest
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ? AND provider = ? AND expires > ?", state, provider, time.Now()).First(&request).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *identityRepository) DeleteExpiredAuthRequests(before time.Time) error {
+	return r.db.Where("expires <= ?", before).Delete(&models.OIDCAuthRequest{}).Error
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db}
+}
+
+func (r *invitationRepository) Create(invitation *models.Invitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *invitationRepository) FindByID(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.First(&invitation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) FindByTokenHash(hash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Where("token = ?", hash).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindPending lists the invitations into the organization, or the account invitations when it is nil,
+// that can still be accepted
+func (r *invitationRepository) FindPending(now time.Time, organizationID *string) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.Scopes(invitationOrganization(organizationID)).
+		Where("accepted_at IS NULL AND expires_at > ?", now).Order("created_at desc").Find(&invitations).Error
+	return invitations, err
+}
+
+// Accept marks the invitation used and creates the invited user and the membership, if given, in the
+// same transaction. The membership of a new user gets its ID. It reports false, creating nothing, if
+// the invitation was accepted in the meantime.
+func (r *invitationRepository) Accept(id uint, at time.Time, user *models.User, membership *models.Membership) (bool, error) {
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).Where("id = ? AND accepted_at IS NULL", id).UpdateColumn("accepted_at", at)
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		accepted = true
+		if user != nil {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+		if membership == nil {
+			return nil
+		}
+		if user != nil {
+			membership.UserID = user.ID.String()
+		}
+		return tx.Create(membership).Error
+	})
+	return accepted && err == nil, err
+}
+
+// DeletePendingByEmail drops invitations to email that were not accepted yet, into the organization
+// or the account invitations when it is nil
+func (r *invitationRepository) DeletePendingByEmail(email string, organizationID *string) error {
+	return r.db.Scopes(invitationOrganization(organizationID)).
+		Where("lower(email) = lower(?) AND accepted_at IS NULL", email).Delete(&models.Invitation{}).Error
+}
+
+func (r *invitationRepository) Delete(invitation *models.Invitation) error {
+	return r.db.Delete(invitation).Error
+}
+
+func invitationOrganization(organizationID *string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if organizationID == nil {
+			return db.Where("organization_id IS NULL")
+		}
+		return db.Where("organization_id = ?", *organizationID)
+	}
+}
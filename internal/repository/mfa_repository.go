@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type mfaRecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARecoveryCodeRepository(db *gorm.DB) MFARecoveryCodeRepository {
+	return &mfaRecoveryCodeRepository{db}
+}
+
+// ReplaceForUser discards the user's previous codes and stores the new set
+func (r *mfaRecoveryCodeRepository) ReplaceForUser(userID string, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.MFARecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = models.MFARecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Consume marks an unused code as used. It returns false if no such code exists.
+func (r *mfaRecoveryCodeRepository) Consume(userID string, codeHash string) (bool, error) {
+	result := r.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *mfaRecoveryCodeRepository) DeleteByUserID(userID string) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+}
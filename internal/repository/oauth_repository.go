@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{db}
+}
+
+func (r *oauthRepository) CreateClient(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthRepository) FindClientByID(id string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Where("id = ?", id).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) FindClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Order("created_at asc").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthRepository) UpdateClient(client *models.OAuthClient) error {
+	return r.db.Save(client).Error
+}
+
+func (r *oauthRepository) DeleteClient(client *models.OAuthClient) error {
+	return r.db.Delete(client).Error
+}
+
+func (r *oauthRepository) CreateAuthorizationCode(code *models.OAuthAuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+// ConsumeAuthorizationCode returns an unexpired code and deletes it so it can be exchanged only once
+func (r *oauthRepository) ConsumeAuthorizationCode(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("code_hash = ? AND expires > ?", codeHash, time.Now()).First(&code).Error; err != nil {
+			return err
+		}
+		// A concurrent exchange may have deleted it in between
+		result := tx.Delete(&code)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *oauthRepository) DeleteExpiredAuthorizationCodes(before time.Time) error {
+	return r.db.Where("expires <= ?", before).Delete(&models.OAuthAuthorizationCode{}).Error
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db}
+}
+
+// CreateWithOwner creates the organization and makes owner its first member
+func (r *organizationRepository) CreateWithOwner(organization *models.Organization, owner *models.Membership) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		owner.OrganizationID = organization.ID.String()
+		return tx.Create(owner).Error
+	})
+}
+
+func (r *organizationRepository) FindAll() ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.db.Order("name asc").Find(&organizations).Error
+	return organizations, err
+}
+
+func (r *organizationRepository) FindByID(id string) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.db.Where("id = ?", id).First(&organization).Error
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *organizationRepository) Update(organization *models.Organization) error {
+	return r.db.Save(organization).Error
+}
+
+// Delete removes the organization with its memberships and pending invitations. Its members get
+// a new permissions version, tokens acting in the organization become stale.
+func (r *organizationRepository) Delete(organization *models.Organization) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, organizationMembers(tx, organization.ID.String())); err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ? AND accepted_at IS NULL", organization.ID).Delete(&models.Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Token{}).Where("organization_id = ?", organization.ID).UpdateColumn("organization_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(organization).Error
+	})
+}
+
+func (r *organizationRepository) FindMembership(organizationID, userID string) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// FindMembershipsByUser lists the organizations of the user, each with the user's role in it
+func (r *organizationRepository) FindMembershipsByUser(userID string) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.Preload("Organization").Where("user_id = ?", userID).Order("created_at asc").Find(&memberships).Error
+	return memberships, err
+}
+
+// FindMembers lists the memberships of the organization with their users. Deleted users are left out.
+func (r *organizationRepository) FindMembers(organizationID string) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.InnerJoins("User").Where("memberships.organization_id = ?", organizationID).Order("memberships.created_at asc").Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *organizationRepository) FindOrganizationIDsByUser(userID string) ([]string, error) {
+	var ids []string
+	err := r.db.Model(&models.Membership{}).Where("user_id = ?", userID).Pluck("organization_id", &ids).Error
+	return ids, err
+}
+
+func (r *organizationRepository) CountOwners(organizationID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Membership{}).Where("organization_id = ? AND role = ?", organizationID, models.OrgRoleOwner).Count(&count).Error
+	return count, err
+}
+
+// UpdateMemberRole saves the new role of the member, whose tokens become stale
+func (r *organizationRepository) UpdateMemberRole(membership *models.Membership) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(membership).UpdateColumn("role", membership.Role).Error; err != nil {
+			return err
+		}
+		return bumpPermissionsVersions(tx, []string{membership.UserID})
+	})
+}
+
+// RemoveMember ends the membership. The user's tokens become stale, and its sessions stop acting
+// in the organization.
+func (r *organizationRepository) RemoveMember(membership *models.Membership) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, []string{membership.UserID}); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Token{}).Where("user_id = ? AND organization_id = ?", membership.UserID, membership.OrganizationID).UpdateColumn("organization_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(membership).Error
+	})
+}
+
+// organizationMembers selects the IDs of the users that belong to the organization
+func organizationMembers(tx *gorm.DB, organizationID string) *gorm.DB {
+	return tx.Model(&models.Membership{}).Select("user_id").Where("organization_id = ?", organizationID)
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db}
+}
+
+func (r *passwordHistoryRepository) Create(entry *models.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// FindRecent returns the user's most recently replaced passwords, newest first
+func (r *passwordHistoryRepository) FindRecent(userID string, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// Prune deletes all but the user's keep most recent entries
+func (r *passwordHistoryRepository) Prune(userID string, keep int) error {
+	recent := r.db.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(keep)
+	return r.db.Where("user_id = ? AND id NOT IN (?)", userID, recent).Delete(&models.PasswordHistory{}).Error
+}
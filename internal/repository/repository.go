@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/pkg/utils"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UserRepository interface {
@@ -14,12 +18,161 @@ type UserRepository interface {
 	FindAll(filters map[string]interface{}, pagination *utils.PaginationScope) ([]models.User, int64, error)
 	ExistsByEmail(email string) (bool, error)
 	Update(user *models.User) error
+	UpdateStatus(user *models.User) error
 	Delete(id uuid.UUID) error
+	Restore(id uuid.UUID) (bool, error)
+	PurgeDeleted(before time.Time) (int64, error)
+	RecordLoginFailure(id uuid.UUID, at time.Time, windowStart time.Time) (int, error)
+	Lock(id uuid.UUID, until time.Time) error
+	ResetLoginFailures(id uuid.UUID) error
+	UpdatePassword(id uuid.UUID, hash string) error
+	MarkEmailVerified(id uuid.UUID) error
 }
 
 type TokenRepository interface {
 	Create(token *models.Token) error
 	FindByToken(token string, tokenType string) (*models.Token, error)
+	FindRotatedRefreshToken(token string, tokenType string) (*models.Token, error)
+	MarkRotated(token *models.Token) (bool, error)
+	Consume(token *models.Token) (bool, error)
+	FindSessions(userID string, now time.Time) ([]models.Token, error)
+	FindSession(userID string, family string) (*models.Token, error)
+	UpdateDevice(token string, userAgent, ipAddress string, at time.Time) error
 	DeleteByUserIDAndType(userID string, tokenType string) error
+	DeleteByFamily(family string) error
+	DeleteSessionsExcept(userID string, family string) error
+	DeleteByClientID(clientID string) error
+	FindBlacklisted(after time.Time) ([]models.Token, error)
+	DeleteExpiredBlacklisted(before time.Time) error
 	Delete(token *models.Token) error
-}
\ No newline at end of file
+}
+type SigningKeyRepository interface {
+	Create(key *models.SigningKey) error
+	FindUsable(algorithm string, retiredAfter time.Time) ([]models.SigningKey, error)
+	Retire(keepID string, at time.Time) error
+	DeleteRetiredBefore(before time.Time) error
+}
+
+type MFARecoveryCodeRepository interface {
+	ReplaceForUser(userID string, codeHashes []string) error
+	Consume(userID string, codeHash string) (bool, error)
+	DeleteByUserID(userID string) error
+}
+
+type WebAuthnRepository interface {
+	CreateCredential(credential *models.WebAuthnCredential) error
+	FindCredentialsByUserID(userID string) ([]models.WebAuthnCredential, error)
+	FindCredential(userID string, id uint) (*models.WebAuthnCredential, error)
+	FindCredentialByCredentialID(credentialID string) (*models.WebAuthnCredential, error)
+	UpdateCredential(credential *models.WebAuthnCredential) error
+	DeleteCredential(credential *models.WebAuthnCredential) error
+	CreateSession(session *models.WebAuthnSession) error
+	ConsumeSession(id string, sessionType string) (*models.WebAuthnSession, error)
+	DeleteExpiredSessions(before time.Time) error
+}
+
+type OAuthRepository interface {
+	CreateClient(client *models.OAuthClient) error
+	FindClientByID(id string) (*models.OAuthClient, error)
+	FindClients() ([]models.OAuthClient, error)
+	UpdateClient(client *models.OAuthClient) error
+	DeleteClient(client *models.OAuthClient) error
+	CreateAuthorizationCode(code *models.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(codeHash string) (*models.OAuthAuthorizationCode, error)
+	DeleteExpiredAuthorizationCodes(before time.Time) error
+}
+
+type IdentityRepository interface {
+	Create(identity *models.Identity) error
+	FindByProviderSubject(provider, subject string) (*models.Identity, error)
+	FindByUserID(userID string) ([]models.Identity, error)
+	FindByID(userID string, id uint) (*models.Identity, error)
+	Update(identity *models.Identity) error
+	Delete(identity *models.Identity) error
+	CreateAuthRequest(request *models.OIDCAuthRequest) error
+	ConsumeAuthRequest(state, provider string) (*models.OIDCAuthRequest, error)
+	DeleteExpiredAuthRequests(before time.Time) error
+}
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	FindByHash(keyHash string) (*models.APIKey, error)
+	FindByUserID(userID string) ([]models.APIKey, error)
+	FindByID(userID string, id uint) (*models.APIKey, error)
+	UpdateLastUsed(id uint, at time.Time) error
+	Delete(key *models.APIKey) error
+}
+
+type PasswordHistoryRepository interface {
+	Create(entry *models.PasswordHistory) error
+	FindRecent(userID string, limit int) ([]models.PasswordHistory, error)
+	Prune(userID string, keep int) error
+}
+
+type InvitationRepository interface {
+	Create(invitation *models.Invitation) error
+	FindByID(id uint) (*models.Invitation, error)
+	FindByTokenHash(hash string) (*models.Invitation, error)
+	FindPending(now time.Time, organizationID *string) ([]models.Invitation, error)
+	Accept(id uint, at time.Time, user *models.User, membership *models.Membership) (bool, error)
+	DeletePendingByEmail(email string, organizationID *string) error
+	Delete(invitation *models.Invitation) error
+}
+
+type RoleRepository interface {
+	EnsurePermission(permission *models.Permission) error
+	CreatePermission(permission *models.Permission) error
+	FindPermissions() ([]models.Permission, error)
+	FindPermissionByID(id uint) (*models.Permission, error)
+	FindPermissionsByName(names []string) ([]models.Permission, error)
+	UpdatePermission(permission *models.Permission) error
+	DeletePermission(permission *models.Permission) error
+	EnsureRole(role *models.Role) error
+	CreateRole(role *models.Role) error
+	FindRoles() ([]models.Role, error)
+	FindRoleByID(id uint) (*models.Role, error)
+	FindRolesByName(names []string) ([]models.Role, error)
+	UpdateRole(role *models.Role) error
+	AddRolePermissions(role *models.Role, permissions []models.Permission) error
+	ReplaceRolePermissions(role *models.Role, permissions []models.Permission) error
+	DeleteRole(role *models.Role) error
+	CountUsersWithRole(roleID uint) (int64, error)
+	FindUserRoles(userID string) ([]models.Role, error)
+	ReplaceUserRoles(user *models.User, roles []models.Role) error
+	AssignMissingUserRoles() (int64, error)
+	FindPermissionsVersion(userID string) (int, error)
+}
+
+type GroupRepository interface {
+	Create(group *models.Group) error
+	FindAll() ([]models.Group, error)
+	FindByID(id uint) (*models.Group, error)
+	FindByName(name string) (*models.Group, error)
+	Update(group *models.Group) error
+	Delete(group *models.Group) error
+	ReplacePermissions(group *models.Group, permissions []models.Permission) error
+	FindMembers(groupID uint) ([]models.User, error)
+	AddMembers(group *models.Group, userIDs []string) error
+	RemoveMember(group *models.Group, userID string) (bool, error)
+	FindUserGroups(userID string) ([]models.Group, error)
+}
+
+type OrganizationRepository interface {
+	CreateWithOwner(organization *models.Organization, owner *models.Membership) error
+	FindAll() ([]models.Organization, error)
+	FindByID(id string) (*models.Organization, error)
+	Update(organization *models.Organization) error
+	Delete(organization *models.Organization) error
+	FindMembership(organizationID, userID string) (*models.Membership, error)
+	FindMembershipsByUser(userID string) ([]models.Membership, error)
+	FindMembers(organizationID string) ([]models.Membership, error)
+	FindOrganizationIDsByUser(userID string) ([]string, error)
+	CountOwners(organizationID string) (int64, error)
+	UpdateMemberRole(membership *models.Membership) error
+	RemoveMember(membership *models.Membership) error
+}
+
+// IsNotFound reports whether a Find* error means the record does not exist, as opposed to a database failure
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db}
+}
+
+// EnsurePermission creates the permission unless one with its name exists, and loads the stored one
+func (r *roleRepository) EnsurePermission(permission *models.Permission) error {
+	return r.db.Where(models.Permission{Name: permission.Name}).Attrs(*permission).FirstOrCreate(permission).Error
+}
+
+func (r *roleRepository) CreatePermission(permission *models.Permission) error {
+	return r.db.Create(permission).Error
+}
+
+func (r *roleRepository) FindPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("name asc").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) FindPermissionByID(id uint) (*models.Permission, error) {
+	var permission models.Permission
+	err := r.db.First(&permission, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r *roleRepository) FindPermissionsByName(names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Where("name IN ?", names).Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) UpdatePermission(permission *models.Permission) error {
+	return r.db.Save(permission).Error
+}
+
+// DeletePermission removes the permission from every role and group. Users of those roles and groups
+// get a new permissions version.
+func (r *roleRepository) DeletePermission(permission *models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		holders := tx.Table("user_roles").Select("user_roles.user_id").
+			Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+			Where("role_permissions.permission_id = ?", permission.ID)
+		if err := bumpPermissionsVersions(tx, holders); err != nil {
+			return err
+		}
+		members := tx.Table("user_groups").Select("user_groups.user_id").
+			Joins("JOIN group_permissions ON group_permissions.group_id = user_groups.group_id").
+			Where("group_permissions.permission_id = ?", permission.ID)
+		if err := bumpPermissionsVersions(tx, members); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", permission.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM group_permissions WHERE permission_id = ?", permission.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(permission).Error
+	})
+}
+
+// EnsureRole creates the role unless one with its name exists, and loads the stored one
+func (r *roleRepository) EnsureRole(role *models.Role) error {
+	return r.db.Omit(clause.Associations).Where(models.Role{Name: role.Name}).Attrs(models.Role{Description: role.Description, BuiltIn: role.BuiltIn}).FirstOrCreate(role).Error
+}
+
+func (r *roleRepository) CreateRole(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) FindRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").Order("name asc").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) FindRoleByID(id uint) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").First(&role, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindRolesByName(names []string) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Where("name IN ?", names).Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) UpdateRole(role *models.Role) error {
+	return r.db.Omit(clause.Associations).Save(role).Error
+}
+
+// AddRolePermissions grants permissions to the role, keeping the ones it has
+func (r *roleRepository) AddRolePermissions(role *models.Role, permissions []models.Permission) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, roleHolders(tx, role.ID)); err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Append(permissions)
+	})
+}
+
+// ReplaceRolePermissions sets exactly the permissions of the role
+func (r *roleRepository) ReplaceRolePermissions(role *models.Role, permissions []models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, roleHolders(tx, role.ID)); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		role.Permissions = nil
+		if len(permissions) == 0 {
+			return nil
+		}
+		return tx.Model(role).Association("Permissions").Append(permissions)
+	})
+}
+
+func (r *roleRepository) DeleteRole(role *models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+// CountUsersWithRole counts the users, deleted ones included, that have the role
+func (r *roleRepository) CountUsersWithRole(roleID uint) (int64, error) {
+	var count int64
+	err := r.db.Table("user_roles").Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) FindUserRoles(userID string) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name asc").
+		Find(&roles).Error
+	return roles, err
+}
+
+// ReplaceUserRoles sets exactly the roles of the user, and its main role with them. The user gets a new
+// permissions version.
+func (r *roleRepository) ReplaceUserRoles(user *models.User, roles []models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", user.ID, role.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("role", user.Role).Error; err != nil {
+			return err
+		}
+		return bumpPermissionsVersions(tx, []string{user.ID.String()})
+	})
+}
+
+// AssignMissingUserRoles grants users without any role the one named by their role column,
+// for accounts created before roles were stored
+func (r *roleRepository) AssignMissingUserRoles() (int64, error) {
+	result := r.db.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT users.id, roles.id FROM users JOIN roles ON roles.name = users.role
+		WHERE NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)`)
+	return result.RowsAffected, result.Error
+}
+
+func (r *roleRepository) FindPermissionsVersion(userID string) (int, error) {
+	var user models.User
+	err := r.db.Select("permissions_version").Where("id = ?", userID).First(&user).Error
+	return user.PermissionsVersion, err
+}
+
+// roleHolders selects the IDs of the users that have the role
+func roleHolders(tx *gorm.DB, roleID uint) *gorm.DB {
+	return tx.Table("user_roles").Select("user_id").Where("role_id = ?", roleID)
+}
+
+// bumpPermissionsVersions gives the users a new permissions version, which makes their access
+// tokens stale. userIDs is a list of IDs or a query selecting them. The column is read-only to
+// GORM updates, so it is bumped in plain SQL.
+func bumpPermissionsVersions(tx *gorm.DB, userIDs interface{}) error {
+	return tx.Exec("UPDATE users SET permissions_version = permissions_version + 1 WHERE id IN (?)", userIDs).Error
+}
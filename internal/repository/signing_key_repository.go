@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db}
+}
+
+func (r *signingKeyRepository) Create(key *models.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// FindUsable returns keys that are active or retired after the given time, newest first
+func (r *signingKeyRepository) FindUsable(algorithm string, retiredAfter time.Time) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := r.db.Where("algorithm = ? AND (retired_at IS NULL OR retired_at > ?)", algorithm, retiredAfter).
+		Order("created_at desc").
+		Find(&keys).Error
+	return keys, err
+}
+
+// Retire marks every active key except keepID as retired
+func (r *signingKeyRepository) Retire(keepID string, at time.Time) error {
+	return r.db.Model(&models.SigningKey{}).
+		Where("id <> ? AND retired_at IS NULL", keepID).
+		Update("retired_at", at).Error
+}
+
+func (r *signingKeyRepository) DeleteRetiredBefore(before time.Time) error {
+	return r.db.Where("retired_at IS NOT NULL AND retired_at <= ?", before).Delete(&models.SigningKey{}).Error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"starter-kit-restapi-gonethttp/internal/models"
 
 	"gorm.io/gorm"
@@ -20,17 +22,98 @@ func (r *tokenRepository) Create(token *models.Token) error {
 
 func (r *tokenRepository) FindByToken(tokenStr string, tokenType string) (*models.Token, error) {
 	var token models.Token
-	err := r.db.Where("token = ? AND type = ? AND blacklisted = ?", tokenStr, tokenType, false).First(&token).Error
+	err := r.db.Where("token = ? AND type = ? AND blacklisted = ? AND rotated = ?", tokenStr, tokenType, false, false).First(&token).Error
 	if err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
 
+// FindRotatedRefreshToken looks up a refresh token that has already been exchanged.
+// A hit here means the token is being replayed.
+func (r *tokenRepository) FindRotatedRefreshToken(tokenStr string, tokenType string) (*models.Token, error) {
+	var token models.Token
+	err := r.db.Where("token = ? AND type = ? AND rotated = ?", tokenStr, tokenType, true).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRotated flags the token as used. It returns false if another request rotated it first.
+func (r *tokenRepository) MarkRotated(token *models.Token) (bool, error) {
+	result := r.db.Model(&models.Token{}).
+		Where("id = ? AND rotated = ?", token.ID, false).
+		Update("rotated", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Consume deletes a single-use token. It returns false if another request consumed it first.
+func (r *tokenRepository) Consume(token *models.Token) (bool, error) {
+	result := r.db.Where("id = ?", token.ID).Delete(&models.Token{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindSessions returns the live refresh token of each of the user's sessions
+func (r *tokenRepository) FindSessions(userID string, now time.Time) ([]models.Token, error) {
+	var tokens []models.Token
+	err := r.db.Where("user_id = ? AND type = ? AND rotated = ? AND blacklisted = ? AND expires > ?", userID, models.TokenTypeRefresh, false, false, now).
+		Order("created_at desc").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *tokenRepository) FindSession(userID string, family string) (*models.Token, error) {
+	var token models.Token
+	err := r.db.Where("user_id = ? AND family = ? AND type = ? AND rotated = ?", userID, family, models.TokenTypeRefresh, false).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UpdateDevice records the device a refresh token was just issued to
+func (r *tokenRepository) UpdateDevice(tokenStr string, userAgent, ipAddress string, at time.Time) error {
+	return r.db.Model(&models.Token{}).
+		Where("token = ? AND type = ?", tokenStr, models.TokenTypeRefresh).
+		Updates(map[string]interface{}{"user_agent": userAgent, "ip_address": ipAddress, "last_used_at": at}).Error
+}
+
 func (r *tokenRepository) DeleteByUserIDAndType(userID string, tokenType string) error {
 	return r.db.Where("user_id = ? AND type = ?", userID, tokenType).Delete(&models.Token{}).Error
 }
 
+func (r *tokenRepository) DeleteByFamily(family string) error {
+	return r.db.Where("family = ?", family).Delete(&models.Token{}).Error
+}
+
+// DeleteSessionsExcept ends every session of the user except the one with the given refresh token family
+func (r *tokenRepository) DeleteSessionsExcept(userID string, family string) error {
+	return r.db.Where("user_id = ? AND type = ? AND (family IS NULL OR family <> ?)", userID, models.TokenTypeRefresh, family).Delete(&models.Token{}).Error
+}
+
+// DeleteByClientID removes the live tokens of a client, its denylist entries stay until they expire
+func (r *tokenRepository) DeleteByClientID(clientID string) error {
+	return r.db.Where("client_id = ? AND blacklisted = ?", clientID, false).Delete(&models.Token{}).Error
+}
+
+// FindBlacklisted returns denylist entries that are still relevant after the given time
+func (r *tokenRepository) FindBlacklisted(after time.Time) ([]models.Token, error) {
+	var tokens []models.Token
+	err := r.db.Where("blacklisted = ? AND expires > ?", true, after).Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *tokenRepository) DeleteExpiredBlacklisted(before time.Time) error {
+	return r.db.Where("blacklisted = ? AND expires <= ?", true, before).Delete(&models.Token{}).Error
+}
+
 func (r *tokenRepository) Delete(token *models.Token) error {
 	return r.db.Delete(token).Error
 }
\ No newline at end of file
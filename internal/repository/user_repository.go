@@ -3,12 +3,14 @@ package repository
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/pkg/utils"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
@@ -46,6 +48,9 @@ func (r *userRepository) FindAll(filters map[string]interface{}, pagination *uti
 	var totalRows int64
 
 	query := r.db.Model(&models.User{})
+	if includeDeleted, _ := filters["includeDeleted"].(bool); includeDeleted {
+		query = query.Unscoped()
+	}
 
 	// --- 1. SEARCH LOGIC ---
 	if search, ok := filters["search"].(string); ok && search != "" {
@@ -80,8 +85,25 @@ func (r *userRepository) FindAll(filters map[string]interface{}, pagination *uti
 	}
 
 	// --- 2. FILTER LOGIC ---
+	// Holders of the role, by name, whether or not it is their main one
 	if role, ok := filters["role"].(string); ok && role != "" {
-		query = query.Where("role = ?", role)
+		query = query.Where("id IN (?)", r.db.Table("user_roles").Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").Where("roles.name = ?", role))
+	}
+	if status, ok := filters["status"].(string); ok && status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if serviceAccount, ok := filters["serviceAccount"].(bool); ok {
+		query = query.Where("service_account = ?", serviceAccount)
+	}
+	// Members of the group, by name
+	if group, ok := filters["group"].(string); ok && group != "" {
+		query = query.Where("id IN (?)", r.db.Table("user_groups").Select("user_groups.user_id").
+			Joins("JOIN groups ON groups.id = user_groups.group_id").Where("groups.name = ?", group))
+	}
+	// Tenant scope: only the members of the organization
+	if organizationID, ok := filters["organizationId"].(string); ok && organizationID != "" {
+		query = query.Where("id IN (?)", r.db.Model(&models.Membership{}).Select("user_id").Where("organization_id = ?", organizationID))
 	}
 
 	// --- 3. COUNT TOTAL ---
@@ -106,6 +128,7 @@ func (r *userRepository) FindAll(filters map[string]interface{}, pagination *uti
 			"name":       true,
 			"email":      true,
 			"role":       true,
+			"status":     true,
 			"created_at": true,
 		}
 
@@ -126,16 +149,106 @@ func (r *userRepository) FindAll(filters map[string]interface{}, pagination *uti
 	return users, totalRows, err
 }
 
+// ExistsByEmail also counts deleted users, whose email stays reserved until they are purged
 func (r *userRepository) ExistsByEmail(email string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	err := r.db.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
 	return count > 0, err
 }
 
+// Update saves the user's profile, email, password and MFA columns. The role, status, lockout
+// and permissions version columns are left out: they have their own methods, which update them
+// in SQL, and saving the values read at the start of the request would undo concurrent changes.
 func (r *userRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	return r.db.Omit(
+		clause.Associations, "role", "permissions_version", "status", "status_reason", "suspended_until",
+		"failed_login_attempts", "last_failed_login_at", "locked_until", "created_at", "deleted_at",
+	).Save(user).Error
 }
 
+// RecordLoginFailure counts a failed password login and returns the failures since windowStart.
+// The counter is updated in SQL so concurrent attempts cannot overwrite each other.
+func (r *userRepository) RecordLoginFailure(id uuid.UUID, at time.Time, windowStart time.Time) (int, error) {
+	err := r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": gorm.Expr("CASE WHEN last_failed_login_at > ? THEN failed_login_attempts + 1 ELSE 1 END", windowStart),
+		"last_failed_login_at":  at,
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var user models.User
+	if err := r.db.Select("failed_login_attempts").Where("id = ?", id).First(&user).Error; err != nil {
+		return 0, err
+	}
+	return user.FailedLoginAttempts, nil
+}
+
+// Lock locks the account until the given time and starts a new failure count for afterwards
+func (r *userRepository) Lock(id uuid.UUID, until time.Time) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"locked_until":          until,
+		"failed_login_attempts": 0,
+	}).Error
+}
+
+func (r *userRepository) ResetLoginFailures(id uuid.UUID) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+}
+
+// UpdatePassword replaces only the password hash, leaving the rest of the row and UpdatedAt alone
+func (r *userRepository) UpdatePassword(id uuid.UUID, hash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumn("password", hash).Error
+}
+
+// MarkEmailVerified records that the user proved their email, which activates a pending account.
+// Pending accounts never had tokens, so the permissions version stays.
+func (r *userRepository) MarkEmailVerified(id uuid.UUID) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"is_email_verified": true,
+		"status":            gorm.Expr("CASE WHEN status = ? THEN ? ELSE status END", models.UserStatusPending, models.UserStatusActive),
+	}).Error
+}
+
+// UpdateStatus saves the user's account status and bumps their permissions version, so their
+// access tokens are refused without looking the user up on every request
+func (r *userRepository) UpdateStatus(user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("status", "status_reason", "suspended_until").Updates(user).Error; err != nil {
+			return err
+		}
+		return bumpPermissionsVersions(tx, []string{user.ID.String()})
+	})
+}
+
+// Delete soft deletes the user, see Restore and PurgeDeleted. Like a status change, it bumps the
+// permissions version.
 func (r *userRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.User{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPermissionsVersions(tx, []string{id.String()}); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, id).Error
+	})
+}
+
+// Restore undeletes a soft deleted user. It returns false if there was no such deleted user.
+func (r *userRepository) Restore(id uuid.UUID) (bool, error) {
+	result := r.db.Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// PurgeDeleted permanently removes users deleted before the given time
+func (r *userRepository) PurgeDeleted(before time.Time) (int64, error) {
+	result := r.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at <= ?", before).Delete(&models.User{})
+	return result.RowsAffected, result.Error
 }
\ No newline at end of file
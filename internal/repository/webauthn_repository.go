@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnRepository(db *gorm.DB) WebAuthnRepository {
+	return &webAuthnRepository{db}
+}
+
+func (r *webAuthnRepository) CreateCredential(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+func (r *webAuthnRepository) FindCredentialsByUserID(userID string) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at asc").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *webAuthnRepository) FindCredential(userID string, id uint) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) FindCredentialByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) UpdateCredential(credential *models.WebAuthnCredential) error {
+	return r.db.Save(credential).Error
+}
+
+func (r *webAuthnRepository) DeleteCredential(credential *models.WebAuthnCredential) error {
+	return r.db.Delete(credential).Error
+}
+
+func (r *webAuthnRepository) CreateSession(session *models.WebAuthnSession) error {
+	return r.db.Create(session).Error
+}
+
+// ConsumeSession returns an unexpired session and deletes it so each challenge is answered once
+func (r *webAuthnRepository) ConsumeSession(id string, sessionType string) (*models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND type = ? AND expires > ?", id, sessionType, time.Now()).First(&session).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&session).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *webAuthnRepository) DeleteExpiredSessions(before time.Time) error {
+	return r.db.Where("expires <= ?", before).Delete(&models.WebAuthnSession{}).Error
+}
@@ -6,48 +6,210 @@ import (
 	"starter-kit-restapi-gonethttp/config"
 	"starter-kit-restapi-gonethttp/internal/handlers"
 	"starter-kit-restapi-gonethttp/internal/middleware"
+	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/services"
 )
 
-func RegisterRoutes(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, userService services.UserService) http.Handler {
+func RegisterRoutes(cfg *config.Config, authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, oauthHandler *handlers.OAuthHandler, userService services.UserService, apiKeyService services.APIKeyService, serviceAccountService services.ServiceAccountService, rbacService services.RBACService, groupService services.GroupService, policyService services.PolicyService, organizationService services.OrganizationService, keyService *services.KeyService, denylist services.TokenDenylist) http.Handler {
 	mux := http.NewServeMux()
 	healthHandler := handlers.NewHealthHandler()
-	authMiddleware := middleware.Auth(cfg)
+	keyHandler := handlers.NewKeyHandler(keyService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountService)
+	rbacHandler := handlers.NewRBACHandler(rbacService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	authzHandler := handlers.NewAuthzHandler(policyService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService)
+	authMiddleware := middleware.Auth(keyService, denylist, apiKeyService, userService, rbacService, groupService)
 	rateLimit := middleware.RateLimit
 	
-	// Role Middleware
-	requireAdmin := middleware.RequireAdmin(userService)
-	requireAdminOrSelf := middleware.RequireAdminOrSelf(userService)
+	// Permission Middleware (the access token carries the permissions of the user's roles)
+	requirePermission := middleware.RequirePermission
+	// Policy Middleware (attribute-based rules, e.g. users reaching their own account)
+	authorize := middleware.Authorize(policyService, userService)
 
 	// Health
 	mux.HandleFunc("GET /v1/health", healthHandler.HealthCheck)
 
+	// Public signing keys (JWKS)
+	mux.HandleFunc("GET /.well-known/jwks.json", keyHandler.JWKS)
+
 	// Auth
 	mux.HandleFunc("POST /v1/auth/register", authHandler.Register)
 	mux.HandleFunc("POST /v1/auth/login", authHandler.Login)
 	mux.HandleFunc("POST /v1/auth/logout", authHandler.Logout)
+	mux.HandleFunc("POST /v1/auth/refresh-tokens", authHandler.RefreshTokens)
+	mux.HandleFunc("POST /v1/auth/switch-organization", authHandler.SwitchOrganization)
 	mux.HandleFunc("POST /v1/auth/forgot-password", authHandler.ForgotPassword)
 	mux.HandleFunc("POST /v1/auth/reset-password", authHandler.ResetPassword)
 	mux.HandleFunc("POST /v1/auth/verify-email", authHandler.VerifyEmail)
+	mux.HandleFunc("POST /v1/auth/unlock-account", authHandler.UnlockAccount)
 	mux.Handle("POST /v1/auth/send-verification-email", authMiddleware(http.HandlerFunc(authHandler.SendVerificationEmail)))
+	mux.HandleFunc("POST /v1/auth/resend-verification-email", authHandler.ResendVerificationEmail)
+
+	// Accept an admin's invitation; the mailed link carries the token
+	mux.HandleFunc("POST /v1/auth/invitations/accept", authHandler.AcceptInvitation)
+
+	// Passwordless login by email link
+	mux.HandleFunc("POST /v1/auth/magic-link", authHandler.RequestMagicLink)
+	mux.HandleFunc("POST /v1/auth/magic-link/verify", authHandler.MagicLinkLogin)
+
+	// Sessions
+	mux.Handle("GET /v1/auth/sessions", authMiddleware(http.HandlerFunc(authHandler.ListSessions)))
+	mux.Handle("DELETE /v1/auth/sessions/{id}", authMiddleware(http.HandlerFunc(authHandler.RevokeSession)))
+	mux.Handle("POST /v1/auth/logout-all", authMiddleware(http.HandlerFunc(authHandler.LogoutAll)))
+
+	// Multi-factor Authentication
+	mux.HandleFunc("POST /v1/auth/mfa/verify", authHandler.VerifyMFA)
+	mux.Handle("POST /v1/auth/mfa/enroll", authMiddleware(http.HandlerFunc(authHandler.EnrollMFA)))
+	mux.Handle("POST /v1/auth/mfa/confirm", authMiddleware(http.HandlerFunc(authHandler.ConfirmMFA)))
+	mux.Handle("POST /v1/auth/mfa/disable", authMiddleware(http.HandlerFunc(authHandler.DisableMFA)))
+	mux.Handle("POST /v1/auth/mfa/recovery-codes", authMiddleware(http.HandlerFunc(authHandler.RegenerateRecoveryCodes)))
+
+	// Passkeys (WebAuthn)
+	mux.HandleFunc("POST /v1/auth/passkeys/login/options", authHandler.PasskeyLoginOptions)
+	mux.HandleFunc("POST /v1/auth/passkeys/login", authHandler.PasskeyLogin)
+	mux.Handle("POST /v1/auth/passkeys/register/options", authMiddleware(http.HandlerFunc(authHandler.PasskeyRegistrationOptions)))
+	mux.Handle("POST /v1/auth/passkeys/register", authMiddleware(http.HandlerFunc(authHandler.PasskeyRegister)))
+	mux.Handle("GET /v1/auth/passkeys", authMiddleware(http.HandlerFunc(authHandler.ListPasskeys)))
+	mux.Handle("PATCH /v1/auth/passkeys/{id}", authMiddleware(http.HandlerFunc(authHandler.RenamePasskey)))
+	mux.Handle("DELETE /v1/auth/passkeys/{id}", authMiddleware(http.HandlerFunc(authHandler.DeletePasskey)))
+
+	// External identity providers (OpenID Connect)
+	mux.HandleFunc("GET /v1/auth/oidc/providers", authHandler.ListOIDCProviders)
+	mux.HandleFunc("GET /v1/auth/oidc/{provider}/authorize", authHandler.OIDCAuthorize)
+	mux.HandleFunc("GET /v1/auth/oidc/{provider}/callback", authHandler.OIDCCallback)
+	mux.Handle("POST /v1/auth/oidc/{provider}/link", authMiddleware(http.HandlerFunc(authHandler.OIDCLink)))
+	mux.Handle("GET /v1/auth/identities", authMiddleware(http.HandlerFunc(authHandler.ListIdentities)))
+	mux.Handle("DELETE /v1/auth/identities/{id}", authMiddleware(http.HandlerFunc(authHandler.UnlinkIdentity)))
+
+	// OAuth2 authorization server
+	mux.HandleFunc("POST /v1/oauth/token", oauthHandler.Token)
+	mux.HandleFunc("POST /v1/oauth/introspect", oauthHandler.Introspect)
+	mux.HandleFunc("POST /v1/oauth/revoke", oauthHandler.Revoke)
+	mux.Handle("GET /v1/oauth/authorize", authMiddleware(http.HandlerFunc(oauthHandler.AuthorizationRequest)))
+	mux.Handle("POST /v1/oauth/authorize", authMiddleware(http.HandlerFunc(oauthHandler.Authorize)))
+
+	// OAuth2 clients
+	mux.Handle("POST /v1/oauth/clients", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.CreateClient))))
+	mux.Handle("GET /v1/oauth/clients", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.GetClients))))
+	mux.Handle("GET /v1/oauth/clients/{id}", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.GetClient))))
+	mux.Handle("PATCH /v1/oauth/clients/{id}", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.UpdateClient))))
+	mux.Handle("DELETE /v1/oauth/clients/{id}", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.DeleteClient))))
+	mux.Handle("POST /v1/oauth/clients/{id}/secret", authMiddleware(requirePermission(models.PermissionOAuthClientsManage)(http.HandlerFunc(oauthHandler.RotateClientSecret))))
+
+	// Invitations to register
+	mux.Handle("POST /v1/invitations", authMiddleware(requirePermission(models.PermissionInvitationsManage)(http.HandlerFunc(authHandler.CreateInvitation))))
+	mux.Handle("GET /v1/invitations", authMiddleware(requirePermission(models.PermissionInvitationsManage)(http.HandlerFunc(authHandler.GetInvitations))))
+	mux.Handle("DELETE /v1/invitations/{id}", authMiddleware(requirePermission(models.PermissionInvitationsManage)(http.HandlerFunc(authHandler.RevokeInvitation))))
+
+	// Signed-in user (self-service)
+	mux.Handle("GET /v1/users/me", authMiddleware(http.HandlerFunc(userHandler.GetMe)))
+	mux.Handle("PATCH /v1/users/me", authMiddleware(http.HandlerFunc(userHandler.UpdateMe)))
+	mux.Handle("DELETE /v1/users/me", authMiddleware(http.HandlerFunc(userHandler.DeleteMe)))
+	mux.Handle("POST /v1/users/me/password", authMiddleware(http.HandlerFunc(userHandler.ChangeMyPassword)))
+
+	// Email change of the signed-in user; the links mailed to both addresses carry the token
+	mux.Handle("POST /v1/users/me/email-change", authMiddleware(http.HandlerFunc(authHandler.RequestEmailChange)))
+	mux.HandleFunc("POST /v1/users/me/email-change/confirm", authHandler.ConfirmEmailChange)
+	mux.HandleFunc("POST /v1/users/me/email-change/cancel", authHandler.CancelEmailChange)
 
 	// Users (Protected with RBAC)
 	
-	// Create User: Admin Only
-	mux.Handle("POST /v1/users", authMiddleware(requireAdmin(http.HandlerFunc(userHandler.CreateUser))))
+	// Create User
+	mux.Handle("POST /v1/users", authMiddleware(requirePermission(models.PermissionUsersCreate)(http.HandlerFunc(userHandler.CreateUser))))
 	
-	// Get List: Admin Only
-	mux.Handle("GET /v1/users", authMiddleware(requireAdmin(http.HandlerFunc(userHandler.GetUsers))))
+	// Get List: decided by the access policy (permission, or the members of the active organization for its owners and admins)
+	mux.Handle("GET /v1/users", authMiddleware(authorize(models.PermissionUsersRead)(http.HandlerFunc(userHandler.GetUsers))))
 	
-	// Get One: Admin OR Self
-	mux.Handle("GET /v1/users/{id}", authMiddleware(requireAdminOrSelf(http.HandlerFunc(userHandler.GetUser))))
+	// Get One: decided by the access policy (permission or self by default)
+	mux.Handle("GET /v1/users/{id}", authMiddleware(authorize(models.PermissionUsersRead)(http.HandlerFunc(userHandler.GetUser))))
 	
-	// Update: Admin Only (Strict CRUD)
-	// If you want users to update themselves, use requireAdminOrSelf here.
-	mux.Handle("PATCH /v1/users/{id}", authMiddleware(requireAdmin(http.HandlerFunc(userHandler.UpdateUser))))
+	// Update: decided by the access policy (permission, or self for the name only by default)
+	mux.Handle("PATCH /v1/users/{id}", authMiddleware(authorize(models.PermissionUsersUpdate)(http.HandlerFunc(userHandler.UpdateUser))))
 	
-	// Delete: Admin Only
-	mux.Handle("DELETE /v1/users/{id}", authMiddleware(requireAdmin(http.HandlerFunc(userHandler.DeleteUser))))
+	// Delete
+	mux.Handle("DELETE /v1/users/{id}", authMiddleware(requirePermission(models.PermissionUsersDelete)(http.HandlerFunc(userHandler.DeleteUser))))
+
+	// Restore a deleted user before it is purged
+	mux.Handle("POST /v1/users/{id}/restore", authMiddleware(requirePermission(models.PermissionUsersDelete)(http.HandlerFunc(userHandler.RestoreUser))))
+
+	// Reset MFA (lost authenticator)
+	mux.Handle("DELETE /v1/users/{id}/mfa", authMiddleware(requirePermission(models.PermissionUsersUpdate)(http.HandlerFunc(userHandler.ResetMFA))))
+
+	// Lift a login lockout
+	mux.Handle("POST /v1/users/{id}/unlock", authMiddleware(requirePermission(models.PermissionUsersUpdate)(http.HandlerFunc(userHandler.UnlockUser))))
+
+	// Account status
+	mux.Handle("POST /v1/users/{id}/suspend", authMiddleware(requirePermission(models.PermissionUsersStatus)(http.HandlerFunc(userHandler.SuspendUser))))
+	mux.Handle("POST /v1/users/{id}/ban", authMiddleware(requirePermission(models.PermissionUsersStatus)(http.HandlerFunc(userHandler.BanUser))))
+	mux.Handle("POST /v1/users/{id}/reactivate", authMiddleware(requirePermission(models.PermissionUsersStatus)(http.HandlerFunc(userHandler.ReactivateUser))))
+
+	// Sessions of any user
+	mux.Handle("GET /v1/users/{id}/sessions", authMiddleware(requirePermission(models.PermissionSessionsManage)(http.HandlerFunc(authHandler.ListUserSessions))))
+	mux.Handle("DELETE /v1/users/{id}/sessions", authMiddleware(requirePermission(models.PermissionSessionsManage)(http.HandlerFunc(authHandler.LogoutUser))))
+	mux.Handle("DELETE /v1/users/{id}/sessions/{sessionId}", authMiddleware(requirePermission(models.PermissionSessionsManage)(http.HandlerFunc(authHandler.RevokeUserSession))))
+
+	// API keys: decided by the access policy (permission or self by default)
+	mux.Handle("POST /v1/users/{id}/api-keys", authMiddleware(authorize(models.PermissionAPIKeysManage)(http.HandlerFunc(apiKeyHandler.CreateAPIKey))))
+	mux.Handle("GET /v1/users/{id}/api-keys", authMiddleware(authorize(models.PermissionAPIKeysManage)(http.HandlerFunc(apiKeyHandler.GetAPIKeys))))
+	mux.Handle("DELETE /v1/users/{id}/api-keys/{keyId}", authMiddleware(authorize(models.PermissionAPIKeysManage)(http.HandlerFunc(apiKeyHandler.RevokeAPIKey))))
+
+	// Service accounts and their API keys; their roles are set like any user's
+	mux.Handle("POST /v1/service-accounts", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.CreateServiceAccount))))
+	mux.Handle("GET /v1/service-accounts", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.GetServiceAccounts))))
+	mux.Handle("GET /v1/service-accounts/{id}", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.GetServiceAccount))))
+	mux.Handle("DELETE /v1/service-accounts/{id}", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.DeleteServiceAccount))))
+	mux.Handle("POST /v1/service-accounts/{id}/api-keys", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.CreateAPIKey))))
+	mux.Handle("GET /v1/service-accounts/{id}/api-keys", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.GetAPIKeys))))
+	mux.Handle("DELETE /v1/service-accounts/{id}/api-keys/{keyId}", authMiddleware(requirePermission(models.PermissionServiceAccounts)(http.HandlerFunc(serviceAccountHandler.RevokeAPIKey))))
+
+	// Roles of a user: the access policy decides who reads them
+	mux.Handle("GET /v1/users/{id}/roles", authMiddleware(authorize(models.PermissionUsersRead)(http.HandlerFunc(rbacHandler.GetUserRoles))))
+	mux.Handle("PUT /v1/users/{id}/roles", authMiddleware(requirePermission(models.PermissionUserRolesManage)(http.HandlerFunc(rbacHandler.SetUserRoles))))
+
+	// Groups of a user: the access policy decides who reads them
+	mux.Handle("GET /v1/users/{id}/groups", authMiddleware(authorize(models.PermissionUsersRead)(http.HandlerFunc(groupHandler.GetUserGroups))))
+
+	// Roles and permissions
+	mux.Handle("GET /v1/roles", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.GetRoles))))
+	mux.Handle("POST /v1/roles", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.CreateRole))))
+	mux.Handle("GET /v1/roles/{id}", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.GetRole))))
+	mux.Handle("PATCH /v1/roles/{id}", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.UpdateRole))))
+	mux.Handle("DELETE /v1/roles/{id}", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.DeleteRole))))
+	mux.Handle("GET /v1/permissions", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.GetPermissions))))
+	mux.Handle("POST /v1/permissions", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.CreatePermission))))
+	mux.Handle("PATCH /v1/permissions/{id}", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.UpdatePermission))))
+	mux.Handle("DELETE /v1/permissions/{id}", authMiddleware(requirePermission(models.PermissionRolesManage)(http.HandlerFunc(rbacHandler.DeletePermission))))
+
+	// Groups of users, their members and the permissions granted to them
+	mux.Handle("GET /v1/groups", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.GetGroups))))
+	mux.Handle("POST /v1/groups", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.CreateGroup))))
+	mux.Handle("GET /v1/groups/{id}", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.GetGroup))))
+	mux.Handle("PATCH /v1/groups/{id}", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.UpdateGroup))))
+	mux.Handle("DELETE /v1/groups/{id}", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.DeleteGroup))))
+	mux.Handle("GET /v1/groups/{id}/members", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.GetMembers))))
+	mux.Handle("POST /v1/groups/{id}/members", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.AddMembers))))
+	mux.Handle("DELETE /v1/groups/{id}/members/{userId}", authMiddleware(requirePermission(models.PermissionGroupsManage)(http.HandlerFunc(groupHandler.RemoveMember))))
+
+	// Organizations (tenants): the member's role in the organization decides, organizations:manage overrides it
+	mux.Handle("POST /v1/organizations", authMiddleware(http.HandlerFunc(organizationHandler.CreateOrganization)))
+	mux.Handle("GET /v1/organizations", authMiddleware(http.HandlerFunc(organizationHandler.GetOrganizations)))
+	mux.Handle("GET /v1/organizations/{id}", authMiddleware(http.HandlerFunc(organizationHandler.GetOrganization)))
+	mux.Handle("PATCH /v1/organizations/{id}", authMiddleware(http.HandlerFunc(organizationHandler.UpdateOrganization)))
+	mux.Handle("DELETE /v1/organizations/{id}", authMiddleware(http.HandlerFunc(organizationHandler.DeleteOrganization)))
+	mux.Handle("GET /v1/organizations/{id}/members", authMiddleware(http.HandlerFunc(organizationHandler.GetMembers)))
+	mux.Handle("PATCH /v1/organizations/{id}/members/{userId}", authMiddleware(http.HandlerFunc(organizationHandler.UpdateMember)))
+	mux.Handle("DELETE /v1/organizations/{id}/members/{userId}", authMiddleware(http.HandlerFunc(organizationHandler.RemoveMember)))
+	mux.Handle("POST /v1/organizations/{id}/invitations", authMiddleware(http.HandlerFunc(organizationHandler.CreateInvitation)))
+	mux.Handle("GET /v1/organizations/{id}/invitations", authMiddleware(http.HandlerFunc(organizationHandler.GetInvitations)))
+	mux.Handle("DELETE /v1/organizations/{id}/invitations/{invitationId}", authMiddleware(http.HandlerFunc(organizationHandler.RevokeInvitation)))
+
+	// Join an organization as an existing user; the mailed link carries the token
+	mux.Handle("POST /v1/organizations/invitations/accept", authMiddleware(http.HandlerFunc(organizationHandler.AcceptInvitation)))
+
+	// Access policy decisions, for debugging rules
+	mux.Handle("POST /v1/authz/check", authMiddleware(requirePermission(models.PermissionAuthzCheck)(http.HandlerFunc(authzHandler.Check))))
 
 	handler := middleware.Logger(mux)
 	if cfg.Env == "production" {
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// AccountStatusError is returned when a user who is not active tries to sign in or use a token
+type AccountStatusError struct {
+	Status string
+	Until  *time.Time // End of a suspension, if it has one
+}
+
+func (e *AccountStatusError) Error() string {
+	switch e.Status {
+	case models.UserStatusSuspended:
+		return "account is suspended"
+	case models.UserStatusBanned:
+		return "account is banned"
+	case models.UserStatusPending:
+		return "account is pending, its email is not verified"
+	}
+	return "account is disabled"
+}
+
+// checkAccountStatus returns an AccountStatusError unless the user is active right now
+func checkAccountStatus(user *models.User) error {
+	if user.IsActive(time.Now()) {
+		return nil
+	}
+	return &AccountStatusError{Status: user.Status, Until: user.SuspendedUntil}
+}
+
+// SuspendUser disables the account until req.Until, or until it is reactivated, and ends its sessions
+func (s *userService) SuspendUser(id uuid.UUID, req SuspendUserRequest) (*models.User, error) {
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		return nil, errors.New("suspension end must be in the future")
+	}
+	return s.setStatus(id, models.UserStatusSuspended, req.Reason, req.Until)
+}
+
+// BanUser disables the account for good (until reactivated) and ends its sessions
+func (s *userService) BanUser(id uuid.UUID, reason string) (*models.User, error) {
+	return s.setStatus(id, models.UserStatusBanned, reason, nil)
+}
+
+func (s *userService) ReactivateUser(id uuid.UUID) (*models.User, error) {
+	return s.setStatus(id, models.UserStatusActive, "", nil)
+}
+
+func (s *userService) setStatus(id uuid.UUID, status, reason string, until *time.Time) (*models.User, error) {
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	user.Status = status
+	user.StatusReason = reason
+	user.SuspendedUntil = until
+	// Bumps the permissions version, which ends the user's access tokens here and on other instances
+	if err := s.repo.UpdateStatus(user); err != nil {
+		return nil, err
+	}
+	s.rbac.ForgetPermissionsVersions(user.ID)
+	logger.Log.Info("User status changed", "userId", user.ID, "status", status, "reason", reason, "until", until)
+
+	if status != models.UserStatusActive {
+		if err := revokeSessions(s.denylist, s.tokenRepo, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
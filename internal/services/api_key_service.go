@@ -0,0 +1,92 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix       = "sk_"
+	apiKeyVisibleChars = 12
+	// LastUsedAt is a hint for users, so it is written at most this often instead of on every request
+	apiKeyLastUsedPrecision = time.Minute
+)
+
+var ErrInvalidAPIKey = errors.New("invalid or expired api key")
+
+type apiKeyService struct {
+	repo     repository.APIKeyRepository
+	userRepo repository.UserRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &apiKeyService{repo: repo, userRepo: userRepo}
+}
+
+func (s *apiKeyService) CreateKey(userID uuid.UUID, req CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, "", errors.New("user not found")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", errors.New("expiresAt must be in the future")
+	}
+
+	secret := apiKeyPrefix + rand.Text() + rand.Text()
+	key := &models.APIKey{
+		UserID:    userID.String(),
+		Name:      req.Name,
+		Prefix:    secret[:apiKeyVisibleChars],
+		KeyHash:   utils.HashSecret(secret),
+		Scopes:    strings.Join(req.Scopes, " "),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.Create(key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *apiKeyService) ListKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	return s.repo.FindByUserID(userID.String())
+}
+
+func (s *apiKeyService) RevokeKey(userID uuid.UUID, id uint) error {
+	key, err := s.repo.FindByID(userID.String(), id)
+	if err != nil {
+		return errors.New("api key not found")
+	}
+	return s.repo.Delete(key)
+}
+
+// Authenticate resolves a presented key to its record and records the use
+func (s *apiKeyService) Authenticate(secret string) (*models.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.FindByHash(utils.HashSecret(secret))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedPrecision {
+		if err := s.repo.UpdateLastUsed(key.ID, now); err != nil {
+			logger.Log.Error("Failed to record api key use", "error", err, "keyId", key.ID)
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
@@ -2,40 +2,271 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"starter-kit-restapi-gonethttp/config"
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
 	"starter-kit-restapi-gonethttp/pkg/utils"
 
 	"github.com/google/uuid"
 )
 
+// ErrIncorrectPassword is returned when a signed-in user fails to re-confirm their password
+var ErrIncorrectPassword = errors.New("incorrect password")
+
+// ErrEmailNotVerified is returned on sign-in while config.EmailVerificationConfig.Required is set
+var ErrEmailNotVerified = errors.New("email is not verified")
+
 type authService struct {
 	userRepo     repository.UserRepository
 	tokenRepo    repository.TokenRepository
+	mfaRepo      repository.MFARecoveryCodeRepository
+	orgRepo      repository.OrganizationRepository
 	tokenService *TokenService
 	emailService EmailService
+	denylist     TokenDenylist
 	cfg          *config.Config
+
+	mfaMu       sync.Mutex
+	mfaAttempts map[uint]mfaAttempts // pending MFA token ID -> wrong codes so far
+
+	throttle     *loginThrottle
+	passwords    *PasswordPolicy
+	magicLinks   *requestLimiter // Link requests per email address
+	verifyEmails *requestLimiter // Verification emails per user
 }
 
-func NewAuthService(uRepo repository.UserRepository, tRepo repository.TokenRepository, tService *TokenService, eService EmailService, cfg *config.Config) AuthService {
+func NewAuthService(uRepo repository.UserRepository, tRepo repository.TokenRepository, mRepo repository.MFARecoveryCodeRepository, oRepo repository.OrganizationRepository, tService *TokenService, eService EmailService, denylist TokenDenylist, passwords *PasswordPolicy, cfg *config.Config) AuthService {
 	return &authService{
 		userRepo:     uRepo,
 		tokenRepo:    tRepo,
+		mfaRepo:      mRepo,
+		orgRepo:      oRepo,
 		tokenService: tService,
 		emailService: eService,
+		denylist:     denylist,
 		cfg:          cfg,
+		mfaAttempts:  make(map[uint]mfaAttempts),
+		throttle:     newLoginThrottle(&cfg.Lockout),
+		passwords:    passwords,
+		magicLinks:   newRequestLimiter(cfg.MagicLink.MaxRequests, time.Duration(cfg.MagicLink.WindowMinutes)*time.Minute),
+		verifyEmails: newRequestLimiter(1, time.Duration(cfg.EmailVerification.ResendCooldownSeconds)*time.Second),
 	}
 }
 
 
-func (s *authService) Login(email, password string) (*models.User, map[string]interface{}, error) {
+func (s *authService) Login(email, password, ipAddress string) (*models.User, map[string]interface{}, error) {
+	now := time.Now()
+	if throttled := s.throttle.checkIP(ipAddress, now); throttled != nil {
+		return nil, nil, throttled
+	}
+
 	user, err := s.userRepo.FindByEmail(email)
-	if err != nil || !user.ComparePassword(password) {
+	if err == nil {
+		// Refused before the password is checked, so a locked account gives nothing away
+		if throttled := s.throttle.checkAccount(user, now); throttled != nil {
+			return nil, nil, throttled
+		}
+	}
+	if err != nil || !utils.CheckPassword(password, user.Password) {
+		if err != nil {
+			user = nil
+		}
+		s.recordLoginFailure(user, ipAddress, now)
 		return nil, nil, errors.New("incorrect email or password")
 	}
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := s.userRepo.ResetLoginFailures(user.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Only told to whoever knows the password
+	if err := checkAccountStatus(user); err != nil {
+		if user.Status == models.UserStatusPending {
+			s.sendVerificationOnLogin(user)
+		}
+		return nil, nil, err
+	}
+	if s.cfg.EmailVerification.Required && !user.IsEmailVerified {
+		s.sendVerificationOnLogin(user)
+		return nil, nil, ErrEmailNotVerified
+	}
+	if utils.PasswordNeedsRehash(user.Password) {
+		s.rehashPassword(user, password)
+	}
+	if user.MFAEnabled {
+		mfaToken, expires, err := s.tokenService.GenerateMFAToken(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, &MFARequiredError{Token: mfaToken, Expires: expires}
+	}
+	tokens, err := s.tokenService.GenerateAuthTokens(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// sendVerificationOnLogin mails a new link to a user refused for their unverified email, who
+// cannot sign in to ask for one
+func (s *authService) sendVerificationOnLogin(user *models.User) {
+	if err := s.SendVerificationEmail(user.ID); err != nil {
+		var limited *RateLimitedError
+		if !errors.As(err, &limited) {
+			logger.Log.Error("Failed to send verification email", "userId", user.ID, "error", err)
+		}
+	}
+}
+
+// rehashPassword upgrades a stored hash made with an outdated algorithm or parameters.
+// A failure only means the upgrade is retried at the next login.
+func (s *authService) rehashPassword(user *models.User, password string) {
+	hash, err := utils.HashPassword(password)
+	if err == nil {
+		err = s.userRepo.UpdatePassword(user.ID, hash)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to rehash password", "userId", user.ID, "error", err)
+		return
+	}
+	user.Password = hash
+}
+
+// recordLoginFailure counts a wrong password against the source IP and, if the email exists, the account
+func (s *authService) recordLoginFailure(user *models.User, ipAddress string, now time.Time) {
+	if s.throttle.failIP(ipAddress, now) {
+		logger.Log.Warn("Login locked out for IP after repeated failures", "ip", ipAddress, "minutes", s.cfg.Lockout.DurationMinutes)
+	}
+	if user == nil {
+		return
+	}
+
+	failures, err := s.userRepo.RecordLoginFailure(user.ID, now, now.Add(-s.throttle.window()))
+	if err != nil {
+		logger.Log.Error("Failed to record failed login", "userId", user.ID, "error", err)
+		return
+	}
+	if !s.throttle.accountLocked(failures) {
+		return
+	}
+
+	until := now.Add(s.throttle.window())
+	if err := s.userRepo.Lock(user.ID, until); err != nil {
+		logger.Log.Error("Failed to lock account", "userId", user.ID, "error", err)
+		return
+	}
+	logger.Log.Warn("Account locked after repeated failed logins", "userId", user.ID, "ip", ipAddress, "failures", failures, "lockedUntil", until)
+
+	unlockToken, _, err := s.tokenService.GenerateToken(user.ID, until.Sub(now), models.TokenTypeUnlockAccount)
+	if err == nil {
+		err = s.tokenService.SaveToken(unlockToken, user.ID.String(), until, models.TokenTypeUnlockAccount)
+	}
+	if err == nil {
+		err = s.emailService.SendUnlockAccountEmail(user.Email, unlockToken)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to send account unlock email", "userId", user.ID, "error", err)
+	}
+}
+
+// UnlockAccount lifts a lockout through the link emailed to the account owner
+func (s *authService) UnlockAccount(tokenStr string) error {
+	tokenDoc, err := s.tokenService.VerifyToken(tokenStr, models.TokenTypeUnlockAccount)
+	if err != nil {
+		return errors.New("account unlock failed")
+	}
+
+	userUUID, err := uuid.Parse(tokenDoc.UserID)
+	if err != nil {
+		return errors.New("invalid user data")
+	}
+	if err := s.userRepo.ResetLoginFailures(userUUID); err != nil {
+		return err
+	}
+	logger.Log.Info("Account unlocked", "userId", userUUID, "by", "email")
+
+	return s.tokenRepo.DeleteByUserIDAndType(tokenDoc.UserID, models.TokenTypeUnlockAccount)
+}
+
+// RequestMagicLink emails a single-use login link. Unknown emails are limited the same way, and the
+// lookup and sending happen in the background, so neither the answer nor its timing tells whether
+// an account uses the email. Failures are only logged.
+func (s *authService) RequestMagicLink(email string) error {
+	if limited := s.magicLinks.allow(strings.ToLower(email), time.Now()); limited != nil {
+		return limited
+	}
+
+	go func() {
+		if err := s.sendMagicLink(email); err != nil {
+			logger.Log.Error("Failed to send magic link", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *authService) sendMagicLink(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if repository.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	expires := time.Duration(s.cfg.MagicLink.ExpirationMinutes) * time.Minute
+	linkToken, expiresAt, err := s.tokenService.GenerateToken(user.ID, expires, models.TokenTypeMagicLink)
+	if err != nil {
+		return err
+	}
+	if err := s.tokenService.SaveToken(linkToken, user.ID.String(), expiresAt, models.TokenTypeMagicLink); err != nil {
+		return err
+	}
+
+	return s.emailService.SendMagicLinkEmail(user.Email, linkToken)
+}
+
+// MagicLinkLogin exchanges a login link for a token pair. The link replaces the password only,
+// so an account with MFA still has to pass its second factor.
+func (s *authService) MagicLinkLogin(tokenStr string) (*models.User, map[string]interface{}, error) {
+	tokenDoc, err := s.tokenService.VerifyToken(tokenStr, models.TokenTypeMagicLink)
+	if err != nil || tokenDoc.Expires.Before(time.Now()) {
+		return nil, nil, errors.New("invalid or expired login link")
+	}
+	consumed, err := s.tokenRepo.Consume(tokenDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, errors.New("invalid or expired login link")
+	}
+
+	userUUID, err := uuid.Parse(tokenDoc.UserID)
+	if err != nil {
+		return nil, nil, errors.New("invalid user data")
+	}
+	user, err := s.userRepo.FindByID(userUUID)
+	if err != nil {
+		return nil, nil, errors.New("invalid or expired login link")
+	}
+
+	// Following the link proves the address belongs to the user
+	if !user.IsEmailVerified {
+		if err := s.markEmailVerified(user); err != nil {
+			return nil, nil, err
+		}
+	}
+	if user.MFAEnabled {
+		mfaToken, expires, err := s.tokenService.GenerateMFAToken(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, &MFARequiredError{Token: mfaToken, Expires: expires}
+	}
 	tokens, err := s.tokenService.GenerateAuthTokens(user)
 	if err != nil {
 		return nil, nil, err
@@ -44,18 +275,40 @@ func (s *authService) Login(email, password string) (*models.User, map[string]in
 }
 
 func (s *authService) Register(req RegisterRequest) (*models.User, map[string]interface{}, error) {
+	if err := selfRegistrationError(s.cfg.Registration.Mode); err != nil {
+		return nil, nil, err
+	}
+	if err := s.passwords.Validate(req.Password, req.Email, req.Name); err != nil {
+		return nil, nil, err
+	}
 	if exists, _ := s.userRepo.ExistsByEmail(req.Email); exists {
 		return nil, nil, errors.New("email already taken")
 	}
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, nil, err
+	}
 	user := &models.User{
 		Name:     req.Name,
 		Email:    req.Email,
-		Password: req.Password,
+		Password: hash,
 		Role:     "user",
 	}
+	// Verifying the email activates the account
+	if s.cfg.EmailVerification.Required {
+		user.Status = models.UserStatusPending
+	}
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, nil, err
 	}
+	// No tokens until the link in the verification email is followed. The account exists either
+	// way, so a failed email is logged and the user asks for another with ResendVerificationEmail.
+	if s.cfg.EmailVerification.Required {
+		if err := s.SendVerificationEmail(user.ID); err != nil {
+			logger.Log.Error("Failed to send verification email", "userId", user.ID, "error", err)
+		}
+		return user, nil, nil
+	}
 	tokens, err := s.tokenService.GenerateAuthTokens(user)
 	if err != nil {
 		return nil, nil, err
@@ -63,30 +316,153 @@ func (s *authService) Register(req RegisterRequest) (*models.User, map[string]in
 	return user, tokens, nil
 }
 
-func (s *authService) Logout(refreshToken string) error {
+func (s *authService) Logout(refreshToken, accessToken string) error {
 	tokenDoc, err := s.tokenService.VerifyToken(refreshToken, models.TokenTypeRefresh)
 	if err != nil {
 		return errors.New("not found")
 	}
+
+	// Revoke the access token of the same session if the client sent it along
+	if accessToken != "" {
+		payload, err := s.tokenService.ParseToken(accessToken)
+		if err == nil && payload.Type == models.TokenTypeAccess && payload.Sub == tokenDoc.UserID {
+			if err := s.denylist.RevokeAccessToken(payload); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Logging out ends the whole session, including already rotated tokens
+	if tokenDoc.Family != "" {
+		return s.tokenRepo.DeleteByFamily(tokenDoc.Family)
+	}
 	return s.tokenRepo.Delete(tokenDoc)
 }
 
 func (s *authService) RefreshAuth(refreshToken string) (map[string]interface{}, error) {
+	return s.rotateRefreshToken(refreshToken, nil)
+}
+
+// SwitchOrganization refreshes the tokens like RefreshAuth and moves the session into the organization,
+// whose id and the user's role in it the access token then carries. An empty id leaves the organization.
+func (s *authService) SwitchOrganization(refreshToken, organizationID string) (map[string]interface{}, error) {
+	return s.rotateRefreshToken(refreshToken, &organizationID)
+}
+
+// rotateRefreshToken exchanges the refresh token for a new pair, in another organization when organizationID is set
+func (s *authService) rotateRefreshToken(refreshToken string, organizationID *string) (map[string]interface{}, error) {
+	payload, err := s.tokenService.ParseToken(refreshToken)
+	if err != nil || payload.Type != models.TokenTypeRefresh {
+		return nil, errors.New("please authenticate")
+	}
+
 	tokenDoc, err := s.tokenService.VerifyToken(refreshToken, models.TokenTypeRefresh)
 	if err != nil {
+		// A token that was already exchanged is being replayed: assume it leaked
+		if usedDoc, findErr := s.tokenRepo.FindRotatedRefreshToken(refreshToken, models.TokenTypeRefresh); findErr == nil {
+			s.revokeTokenFamily(usedDoc)
+		}
 		return nil, errors.New("please authenticate")
 	}
-	payload, err := utils.ValidateToken(refreshToken, s.cfg.JWT.Secret)
+	if organizationID != nil && *organizationID != "" {
+		if _, err := s.orgRepo.FindMembership(*organizationID, tokenDoc.UserID); err != nil {
+			return nil, ErrNotOrganizationMember
+		}
+	}
+
+	rotated, err := s.tokenRepo.MarkRotated(tokenDoc)
 	if err != nil {
-		return nil, errors.New("invalid token")
+		return nil, err
+	}
+	if !rotated {
+		// Lost a race against another request presenting the same token
+		s.revokeTokenFamily(tokenDoc)
+		return nil, errors.New("please authenticate")
 	}
-	userUUID, _ := uuid.Parse(payload.Sub)
+
+	userUUID, _ := uuid.Parse(tokenDoc.UserID)
 	user, err := s.userRepo.FindByID(userUUID)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
-	s.tokenRepo.Delete(tokenDoc)
-	return s.tokenService.GenerateAuthTokens(user)
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
+	}
+	if organizationID != nil {
+		tokenDoc.OrganizationID = *organizationID
+	}
+
+	return s.tokenService.RotateAuthTokens(user, tokenDoc)
+}
+
+// revokeTokenFamily deletes every refresh token descending from the same login as token.
+func (s *authService) revokeTokenFamily(token *models.Token) {
+	logger.Log.Warn("Refresh token reuse detected, revoking token family",
+		"userId", token.UserID,
+		"family", token.Family,
+		"tokenId", token.ID,
+	)
+
+	var err error
+	if token.Family == "" {
+		err = s.tokenRepo.DeleteByUserIDAndType(token.UserID, models.TokenTypeRefresh)
+	} else {
+		err = s.tokenRepo.DeleteByFamily(token.Family)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to revoke token family", "family", token.Family, "error", err)
+	}
+}
+
+// TrackSession records the device a freshly issued refresh token belongs to
+func (s *authService) TrackSession(refreshToken, userAgent, ipAddress string) error {
+	return s.tokenRepo.UpdateDevice(refreshToken, userAgent, ipAddress, time.Now())
+}
+
+func (s *authService) ListSessions(userID uuid.UUID) ([]Session, error) {
+	tokens, err := s.tokenRepo.FindSessions(userID.String(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Family == "" {
+			// Issued before sessions were tracked, only ended by logging out everywhere
+			continue
+		}
+		sessions = append(sessions, Session{
+			ID:         token.Family,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			Expires:    token.Expires,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one session and revokes the access tokens it was issued
+func (s *authService) RevokeSession(userID uuid.UUID, sessionID string) error {
+	if sessionID == "" {
+		return errors.New("session not found")
+	}
+	if _, err := s.tokenRepo.FindSession(userID.String(), sessionID); err != nil {
+		return errors.New("session not found")
+	}
+	if err := s.denylist.RevokeSessionAccessTokens(userID.String(), []string{sessionID}); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByFamily(sessionID)
+}
+
+// LogoutAll ends every session of the user and revokes the access tokens already issued
+func (s *authService) LogoutAll(userID uuid.UUID) error {
+	if err := s.denylist.RevokeAllAccessTokens(userID.String()); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByUserIDAndType(userID.String(), models.TokenTypeRefresh)
 }
 
 func (s *authService) ForgotPassword(email string) error {
@@ -97,7 +473,7 @@ func (s *authService) ForgotPassword(email string) error {
 	}
 
 	expires := time.Duration(s.cfg.JWT.ResetPasswordExpirationMinutes) * time.Minute
-	resetToken, _, err := utils.GenerateToken(user.ID, expires, models.TokenTypeResetPassword, s.cfg.JWT.Secret)
+	resetToken, _, err := s.tokenService.GenerateToken(user.ID, expires, models.TokenTypeResetPassword)
 	if err != nil {
 		return err
 	}
@@ -127,18 +503,48 @@ func (s *authService) ResetPassword(tokenStr, newPassword string) error {
 		return errors.New("password reset failed")
 	}
 
-	user.Password = newPassword
+	if err := s.passwords.Validate(newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	if err := s.passwords.CheckReuse(user, newPassword); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	previousHash := user.Password
+	user.Password = hash
 	if err := s.userRepo.Update(user); err != nil {
 		return err
 	}
+	s.passwords.Remember(user.ID.String(), previousHash)
+
+	// Whoever knew the old password must not keep a valid session, nor an OAuth client acting for them
+	if err := revokeSessions(s.denylist, s.tokenRepo, user.ID); err != nil {
+		return err
+	}
 
 	// Consume token (delete all reset tokens for this user)
 	return s.tokenRepo.DeleteByUserIDAndType(user.ID.String(), models.TokenTypeResetPassword)
 }
 
-func (s *authService) SendVerificationEmail(user *models.User) error {
+// SendVerificationEmail mails a new verification link, at most once per resend cooldown
+func (s *authService) SendVerificationEmail(userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.IsEmailVerified {
+		return errors.New("email is already verified")
+	}
+	if limited := s.verifyEmails.allow(user.ID.String(), time.Now()); limited != nil {
+		return limited
+	}
+
 	expires := time.Duration(s.cfg.JWT.VerifyEmailExpirationMinutes) * time.Minute
-	verifyToken, _, err := utils.GenerateToken(user.ID, expires, models.TokenTypeVerifyEmail, s.cfg.JWT.Secret)
+	verifyToken, _, err := s.tokenService.GenerateToken(user.ID, expires, models.TokenTypeVerifyEmail)
 	if err != nil {
 		return err
 	}
@@ -151,6 +557,35 @@ func (s *authService) SendVerificationEmail(user *models.User) error {
 	return s.emailService.SendVerificationEmail(user.Email, verifyToken)
 }
 
+// ResendVerificationEmail mails a new link to an unverified account by its email, for users who
+// cannot sign in to ask for one. Like magic links, the answer is the same whether the account exists:
+// requests are limited per address and the lookup happens in the background, where the per-user
+// cooldown of SendVerificationEmail still applies.
+func (s *authService) ResendVerificationEmail(email string) error {
+	if limited := s.verifyEmails.allow("email:"+strings.ToLower(email), time.Now()); limited != nil {
+		return limited
+	}
+
+	go func() {
+		user, err := s.userRepo.FindByEmail(email)
+		if repository.IsNotFound(err) {
+			return
+		}
+		if err != nil {
+			logger.Log.Error("Failed to resend verification email", "error", err)
+			return
+		}
+		if user.IsEmailVerified {
+			return
+		}
+		var limited *RateLimitedError
+		if err := s.SendVerificationEmail(user.ID); err != nil && !errors.As(err, &limited) {
+			logger.Log.Error("Failed to resend verification email", "userId", user.ID, "error", err)
+		}
+	}()
+	return nil
+}
+
 func (s *authService) VerifyEmail(tokenStr string) error {
 	tokenDoc, err := s.tokenService.VerifyToken(tokenStr, models.TokenTypeVerifyEmail)
 	if err != nil {
@@ -167,10 +602,141 @@ func (s *authService) VerifyEmail(tokenStr string) error {
 		return errors.New("email verification failed")
 	}
 
+	if err := s.markEmailVerified(user); err != nil {
+		return err
+	}
+
+	return s.tokenRepo.DeleteByUserIDAndType(user.ID.String(), models.TokenTypeVerifyEmail)
+}
+
+// markEmailVerified records that the user proved their email, and activates them if they were pending
+func (s *authService) markEmailVerified(user *models.User) error {
+	if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+		return err
+	}
 	user.IsEmailVerified = true
+	if user.Status == models.UserStatusPending {
+		user.Status = models.UserStatusActive
+	}
+	return nil
+}
+
+// RequestEmailChange stores newEmail as pending and mails a confirmation link to it, and a
+// notice with a cancel link to the current address. A new request replaces a pending one.
+func (s *authService) RequestEmailChange(userID uuid.UUID, newEmail, password string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !utils.CheckPassword(password, user.Password) {
+		return ErrIncorrectPassword
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return errors.New("new email is the current email")
+	}
+	if exists, _ := s.userRepo.ExistsByEmail(newEmail); exists {
+		return errors.New("email already taken")
+	}
+
+	if err := s.deleteTokens(user.ID, models.TokenTypeEmailChange, models.TokenTypeCancelEmail); err != nil {
+		return err
+	}
+	expires := time.Duration(s.cfg.JWT.EmailChangeExpirationMinutes) * time.Minute
+	confirmToken, err := s.issueEmailToken(user, expires, models.TokenTypeEmailChange)
+	if err != nil {
+		return err
+	}
+	cancelToken, err := s.issueEmailToken(user, expires, models.TokenTypeCancelEmail)
+	if err != nil {
+		return err
+	}
+
+	user.PendingEmail = newEmail
 	if err := s.userRepo.Update(user); err != nil {
 		return err
 	}
 
-	return s.tokenRepo.DeleteByUserIDAndType(user.ID.String(), models.TokenTypeVerifyEmail)
-}
\ No newline at end of file
+	if err := s.emailService.SendEmailChangeConfirmation(newEmail, confirmToken); err != nil {
+		return err
+	}
+	return s.emailService.SendEmailChangeNotice(user.Email, newEmail, cancelToken)
+}
+
+// ConfirmEmailChange swaps in the pending email. Links already mailed to the old address stop
+// working and every session has to sign in again.
+func (s *authService) ConfirmEmailChange(tokenStr string) error {
+	user, err := s.emailTokenUser(tokenStr, models.TokenTypeEmailChange)
+	if err != nil || user.PendingEmail == "" {
+		return errors.New("email change failed")
+	}
+	if exists, _ := s.userRepo.ExistsByEmail(user.PendingEmail); exists {
+		return errors.New("email already taken")
+	}
+
+	previousEmail := user.Email
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	// The new address starts unverified, like any email not yet verified through its own link
+	user.IsEmailVerified = false
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	logger.Log.Info("Email changed", "userId", user.ID, "from", previousEmail, "to", user.Email)
+
+	err = s.deleteTokens(user.ID, models.TokenTypeEmailChange, models.TokenTypeCancelEmail,
+		models.TokenTypeVerifyEmail, models.TokenTypeResetPassword, models.TokenTypeMagicLink)
+	if err != nil {
+		return err
+	}
+	return s.LogoutAll(user.ID)
+}
+
+// CancelEmailChange drops the pending email through the link mailed to the current address
+func (s *authService) CancelEmailChange(tokenStr string) error {
+	user, err := s.emailTokenUser(tokenStr, models.TokenTypeCancelEmail)
+	if err != nil || user.PendingEmail == "" {
+		return errors.New("no pending email change")
+	}
+
+	user.PendingEmail = ""
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	logger.Log.Info("Email change cancelled", "userId", user.ID)
+
+	return s.deleteTokens(user.ID, models.TokenTypeEmailChange, models.TokenTypeCancelEmail)
+}
+
+// issueEmailToken saves a token of the given type to be mailed to the user
+func (s *authService) issueEmailToken(user *models.User, expires time.Duration, tokenType string) (string, error) {
+	token, expiresAt, err := s.tokenService.GenerateToken(user.ID, expires, tokenType)
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokenService.SaveToken(token, user.ID.String(), expiresAt, tokenType); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// emailTokenUser returns the user a mailed token of the given type was issued to
+func (s *authService) emailTokenUser(tokenStr, tokenType string) (*models.User, error) {
+	tokenDoc, err := s.tokenService.VerifyToken(tokenStr, tokenType)
+	if err != nil || tokenDoc.Expires.Before(time.Now()) {
+		return nil, errors.New("invalid or expired token")
+	}
+	userUUID, err := uuid.Parse(tokenDoc.UserID)
+	if err != nil {
+		return nil, errors.New("invalid user data")
+	}
+	return s.userRepo.FindByID(userUUID)
+}
+
+func (s *authService) deleteTokens(userID uuid.UUID, tokenTypes ...string) error {
+	for _, tokenType := range tokenTypes {
+		if err := s.tokenRepo.DeleteByUserIDAndType(userID.String(), tokenType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"starter-kit-restapi-gonethttp/pkg/logger"
+)
+
+const (
+	// breachedPrefixLength splits hashes like the Pwned Passwords range API (k-anonymity)
+	breachedPrefixLength = 5
+	// Range files kept in memory, each holds a few hundred suffixes
+	breachedCacheSize = 256
+)
+
+// breachedRanges looks passwords up in a directory of range files, one per SHA-1 prefix as written by
+// the Pwned Passwords downloader: "21BD1.txt" holds the lines "SUFFIX:COUNT" of the hashes starting
+// with 21BD1. A file is only read when a password falls in its range, and the last ones read are cached.
+type breachedRanges struct {
+	dir     string
+	mu      sync.Mutex
+	order   *list.List // Most recently used prefix first
+	entries map[string]*list.Element
+}
+
+type breachedRange struct {
+	prefix   string
+	suffixes map[string]struct{}
+}
+
+func newBreachedRanges(dir string) (*breachedRanges, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory of range files", dir)
+	}
+	return &breachedRanges{dir: dir, order: list.New(), entries: make(map[string]*list.Element)}, nil
+}
+
+// contains reports whether the password's hash is in its range file. A range that cannot be read is
+// logged and treated as empty, so a damaged list does not block every password change.
+func (b *breachedRanges) contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := b.suffixes(hash[:breachedPrefixLength])
+	if err != nil {
+		logger.Log.Error("Failed to read breached password range", "prefix", hash[:breachedPrefixLength], "error", err)
+		return false
+	}
+	_, found := suffixes[hash[breachedPrefixLength:]]
+	return found
+}
+
+func (b *breachedRanges) suffixes(prefix string) (map[string]struct{}, error) {
+	b.mu.Lock()
+	if element, ok := b.entries[prefix]; ok {
+		b.order.MoveToFront(element)
+		b.mu.Unlock()
+		return element.Value.(*breachedRange).suffixes, nil
+	}
+	b.mu.Unlock()
+
+	// Read without the lock; two requests for the same new range may both read it
+	suffixes, err := b.load(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if element, ok := b.entries[prefix]; ok {
+		b.order.MoveToFront(element)
+		return suffixes, nil
+	}
+	b.entries[prefix] = b.order.PushFront(&breachedRange{prefix: prefix, suffixes: suffixes})
+	if b.order.Len() > breachedCacheSize {
+		oldest := b.order.Remove(b.order.Back()).(*breachedRange)
+		delete(b.entries, oldest.prefix)
+	}
+	return suffixes, nil
+}
+
+// load reads a range file, upper or lower case suffixes optionally followed by ":count". A missing
+// file is an empty range, so a partial download works.
+func (b *breachedRanges) load(prefix string) (map[string]struct{}, error) {
+	path := filepath.Join(b.dir, prefix+".txt")
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]struct{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	suffixes := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if suffix == "" {
+			continue
+		}
+		// 35 hex digits, an odd count hex.DecodeString would refuse
+		if len(suffix) != 2*sha1.Size-breachedPrefixLength || strings.Trim(suffix, "0123456789abcdefABCDEF") != "" {
+			return nil, fmt.Errorf("%s:%d: not a SHA-1 hash suffix", path, line)
+		}
+		suffixes[strings.ToUpper(suffix)] = struct{}{}
+	}
+	return suffixes, scanner.Err()
+}
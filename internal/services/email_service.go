@@ -1,8 +1,10 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"net/smtp"
+	"strings"
 
 	"starter-kit-restapi-gonethttp/config"
 )
@@ -11,6 +13,12 @@ type EmailService interface {
 	SendEmail(to, subject, body string) error
 	SendResetPasswordEmail(to, token string) error
 	SendVerificationEmail(to, token string) error
+	SendUnlockAccountEmail(to, token string) error
+	SendMagicLinkEmail(to, token string) error
+	SendEmailChangeConfirmation(to, token string) error
+	SendEmailChangeNotice(to, newEmail, token string) error
+	SendInvitationEmail(to, token string) error
+	SendOrganizationInvitationEmail(to, organization, token string) error
 }
 
 type emailService struct {
@@ -22,6 +30,11 @@ func NewEmailService(cfg *config.Config) EmailService {
 }
 
 func (s *emailService) SendEmail(to, subject, body string) error {
+	// Line breaks in a header would let its value add headers or start the body
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("email recipient and subject must be a single line")
+	}
+
 	auth := smtp.PlainAuth("", s.cfg.SMTP.Username, s.cfg.SMTP.Password, s.cfg.SMTP.Host)
 	
 	msg := []byte(fmt.Sprintf("To: %s\r\n"+
@@ -54,4 +67,52 @@ func (s *emailService) SendVerificationEmail(to, token string) error {
 	verifyURL := fmt.Sprintf("http://localhost:3000/verify-email?token=%s", token)
 	text := fmt.Sprintf("Dear user,\n\nTo verify your email, click on this link: %s\n\nIf you did not create an account, then ignore this email.", verifyURL)
 	return s.SendEmail(to, subject, text)
-}
\ No newline at end of file
+}
+
+func (s *emailService) SendUnlockAccountEmail(to, token string) error {
+	subject := "Account Locked"
+	// Replace with your frontend URL
+	unlockURL := fmt.Sprintf("http://localhost:3000/unlock-account?token=%s", token)
+	text := fmt.Sprintf("Dear user,\n\nYour account was temporarily locked after several failed login attempts. To unlock it now, click on this link: %s\n\nIf these attempts were not yours, consider changing your password.", unlockURL)
+	return s.SendEmail(to, subject, text)
+}
+
+func (s *emailService) SendMagicLinkEmail(to, token string) error {
+	subject := "Your Sign-in Link"
+	// Replace with your frontend URL
+	loginURL := fmt.Sprintf("http://localhost:3000/magic-link?token=%s", token)
+	text := fmt.Sprintf("Dear user,\n\nTo sign in, click on this link: %s\n\nThe link can only be used once and expires in %d minutes. If you did not request it, then ignore this email.", loginURL, s.cfg.MagicLink.ExpirationMinutes)
+	return s.SendEmail(to, subject, text)
+}
+
+func (s *emailService) SendEmailChangeConfirmation(to, token string) error {
+	subject := "Confirm Your New Email"
+	// Replace with your frontend URL
+	confirmURL := fmt.Sprintf("http://localhost:3000/confirm-email-change?token=%s", token)
+	text := fmt.Sprintf("Dear user,\n\nTo use this address for your account, click on this link: %s\n\nIf you did not ask to change your email, then ignore this email.", confirmURL)
+	return s.SendEmail(to, subject, text)
+}
+
+func (s *emailService) SendEmailChangeNotice(to, newEmail, token string) error {
+	subject := "Email Change Requested"
+	// Replace with your frontend URL
+	cancelURL := fmt.Sprintf("http://localhost:3000/cancel-email-change?token=%s", token)
+	text := fmt.Sprintf("Dear user,\n\nA change of your account's email to %s was requested. It takes effect once confirmed from the new address.\n\nIf this was not you, cancel it with this link and change your password: %s", newEmail, cancelURL)
+	return s.SendEmail(to, subject, text)
+}
+
+func (s *emailService) SendInvitationEmail(to, token string) error {
+	subject := "You Are Invited"
+	// Replace with your frontend URL
+	acceptURL := fmt.Sprintf("http://localhost:3000/accept-invitation?token=%s", token)
+	text := fmt.Sprintf("Hello,\n\nYou have been invited to create an account. To choose your name and password, click on this link: %s\n\nThe invitation expires in %d hours. If you were not expecting it, then ignore this email.", acceptURL, s.cfg.Registration.InvitationExpirationHours)
+	return s.SendEmail(to, subject, text)
+}
+
+func (s *emailService) SendOrganizationInvitationEmail(to, organization, token string) error {
+	subject := "You Are Invited to " + organization
+	// Replace with your frontend URL
+	acceptURL := fmt.Sprintf("http://localhost:3000/accept-organization-invitation?token=%s", token)
+	text := fmt.Sprintf("Hello,\n\nYou have been invited to join %s. To accept, click on this link: %s\n\nThe invitation expires in %d hours. If you were not expecting it, then ignore this email.", organization, acceptURL, s.cfg.Registration.InvitationExpirationHours)
+	return s.SendEmail(to, subject, text)
+}
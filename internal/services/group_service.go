@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	groupNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+	ErrGroupNotFound = errors.New("group not found")
+)
+
+type groupService struct {
+	repo     repository.GroupRepository
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+	rbac     RBACService
+}
+
+func NewGroupService(repo repository.GroupRepository, roleRepo repository.RoleRepository, userRepo repository.UserRepository, rbac RBACService) GroupService {
+	return &groupService{repo: repo, roleRepo: roleRepo, userRepo: userRepo, rbac: rbac}
+}
+
+func (s *groupService) GetGroups() ([]models.Group, error) {
+	return s.repo.FindAll()
+}
+
+func (s *groupService) GetGroup(id uint) (*models.Group, error) {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+func (s *groupService) CreateGroup(req CreateGroupRequest) (*models.Group, error) {
+	if !groupNamePattern.MatchString(req.Name) {
+		return nil, errors.New("group name may only contain lowercase letters, digits and dashes")
+	}
+	if _, err := s.repo.FindByName(req.Name); err == nil {
+		return nil, errors.New("group already exists")
+	}
+	permissions, err := findPermissions(s.roleRepo, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{Name: req.Name, Description: req.Description, Permissions: permissions}
+	if err := s.repo.Create(group); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Group created", "group", group.Name, "permissions", req.Permissions)
+	return s.repo.FindByID(group.ID)
+}
+
+// UpdateGroup changes the description and, when given, replaces the permissions. Names are fixed
+// because users are filtered by group name.
+func (s *groupService) UpdateGroup(id uint, req UpdateGroupRequest) (*models.Group, error) {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrGroupNotFound
+	}
+
+	if req.Description != nil {
+		group.Description = *req.Description
+		if err := s.repo.Update(group); err != nil {
+			return nil, err
+		}
+	}
+	if req.Permissions != nil {
+		permissions, err := findPermissions(s.roleRepo, req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.ReplacePermissions(group, permissions); err != nil {
+			return nil, err
+		}
+		s.rbac.ForgetPermissionsVersions()
+		logger.Log.Info("Group permissions changed", "group", group.Name, "permissions", req.Permissions)
+	}
+	return s.repo.FindByID(group.ID)
+}
+
+// DeleteGroup removes the group. Its members lose the permissions it granted.
+func (s *groupService) DeleteGroup(id uint) error {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return ErrGroupNotFound
+	}
+	if err := s.repo.Delete(group); err != nil {
+		return err
+	}
+	s.rbac.ForgetPermissionsVersions()
+	logger.Log.Info("Group deleted", "group", group.Name)
+	return nil
+}
+
+func (s *groupService) GetMembers(id uint) ([]models.User, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, ErrGroupNotFound
+	}
+	return s.repo.FindMembers(id)
+}
+
+// AddMembers puts the users in the group and returns its members. Users already in it are left as they are.
+func (s *groupService) AddMembers(id uint, req AddGroupMembersRequest) ([]models.User, error) {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, ErrGroupNotFound
+	}
+	userIDs := make([]uuid.UUID, 0, len(req.UserIDs))
+	for _, value := range req.UserIDs {
+		userID, _ := uuid.Parse(value)
+		if _, err := s.userRepo.FindByID(userID); err != nil {
+			return nil, errors.New("user not found: " + value)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := s.repo.AddMembers(group, req.UserIDs); err != nil {
+		return nil, err
+	}
+	s.rbac.ForgetPermissionsVersions(userIDs...)
+	logger.Log.Info("Group members added", "group", group.Name, "users", req.UserIDs)
+	return s.repo.FindMembers(group.ID)
+}
+
+func (s *groupService) RemoveMember(id uint, userID uuid.UUID) error {
+	group, err := s.repo.FindByID(id)
+	if err != nil {
+		return ErrGroupNotFound
+	}
+	removed, err := s.repo.RemoveMember(group, userID.String())
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errors.New("user is not in the group")
+	}
+	s.rbac.ForgetPermissionsVersions(userID)
+	logger.Log.Info("Group member removed", "group", group.Name, "userId", userID)
+	return nil
+}
+
+func (s *groupService) GetUserGroups(userID uuid.UUID) ([]models.Group, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+	return s.repo.FindUserGroups(userID.String())
+}
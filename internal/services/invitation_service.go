@@ -0,0 +1,156 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRegistrationInviteOnly = errors.New("registration is by invitation only")
+	ErrRegistrationClosed     = errors.New("registration is closed")
+	ErrInvalidInvitation      = errors.New("invalid or expired invitation")
+)
+
+// selfRegistrationError tells why nobody can sign up on their own under mode, nil when anyone can
+func selfRegistrationError(mode string) error {
+	switch mode {
+	case config.RegistrationModeOpen:
+		return nil
+	case config.RegistrationModeInviteOnly:
+		return ErrRegistrationInviteOnly
+	default:
+		return ErrRegistrationClosed
+	}
+}
+
+type invitationService struct {
+	repo         repository.InvitationRepository
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	tokenService *TokenService
+	emailService EmailService
+	passwords    *PasswordPolicy
+	cfg          *config.Config
+}
+
+func NewInvitationService(repo repository.InvitationRepository, userRepo repository.UserRepository, roleRepo repository.RoleRepository, tokenService *TokenService, emailService EmailService, passwords *PasswordPolicy, cfg *config.Config) (InvitationService, error) {
+	switch cfg.Registration.Mode {
+	case config.RegistrationModeOpen, config.RegistrationModeInviteOnly, config.RegistrationModeClosed:
+	default:
+		return nil, fmt.Errorf("unsupported registration mode %q", cfg.Registration.Mode)
+	}
+
+	return &invitationService{
+		repo:         repo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		tokenService: tokenService,
+		emailService: emailService,
+		passwords:    passwords,
+		cfg:          cfg,
+	}, nil
+}
+
+// CreateInvitation mails an invitation to register. It replaces any pending invitation to the same address.
+func (s *invitationService) CreateInvitation(invitedBy uuid.UUID, req CreateInvitationRequest) (*models.Invitation, error) {
+	if s.cfg.Registration.Mode == config.RegistrationModeClosed {
+		return nil, ErrRegistrationClosed
+	}
+	if exists, _ := s.userRepo.ExistsByEmail(req.Email); exists {
+		return nil, errors.New("email already taken")
+	}
+	if roles, _ := s.roleRepo.FindRolesByName([]string{req.Role}); len(roles) == 0 {
+		return nil, errors.New("unknown role " + req.Role)
+	}
+	if err := s.repo.DeletePendingByEmail(req.Email, nil); err != nil {
+		return nil, err
+	}
+
+	token := rand.Text() + rand.Text()
+	invitation := &models.Invitation{
+		Email:     req.Email,
+		Role:      req.Role,
+		Token:     utils.HashSecret(token),
+		InvitedBy: invitedBy.String(),
+		ExpiresAt: time.Now().Add(time.Duration(s.cfg.Registration.InvitationExpirationHours) * time.Hour),
+	}
+	if err := s.repo.Create(invitation); err != nil {
+		return nil, err
+	}
+	if err := s.emailService.SendInvitationEmail(invitation.Email, token); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Invitation sent", "invitationId", invitation.ID, "role", invitation.Role, "by", invitedBy)
+	return invitation, nil
+}
+
+func (s *invitationService) ListPending() ([]models.Invitation, error) {
+	return s.repo.FindPending(time.Now(), nil)
+}
+
+func (s *invitationService) RevokeInvitation(id uint) error {
+	invitation, err := s.repo.FindByID(id)
+	if err != nil || invitation.AcceptedAt != nil {
+		return errors.New("invitation not found")
+	}
+	return s.repo.Delete(invitation)
+}
+
+// AcceptInvitation creates the invited account. The link reached the inbox, so the email is verified.
+// An invitation into an organization also makes the new user a member.
+func (s *invitationService) AcceptInvitation(token string, req AcceptInvitationRequest) (*models.User, map[string]interface{}, error) {
+	if s.cfg.Registration.Mode == config.RegistrationModeClosed {
+		return nil, nil, ErrRegistrationClosed
+	}
+	now := time.Now()
+	invitation, err := s.repo.FindByTokenHash(utils.HashSecret(token))
+	if err != nil || !invitation.IsPending(now) {
+		return nil, nil, ErrInvalidInvitation
+	}
+	if err := s.passwords.Validate(req.Password, invitation.Email, req.Name); err != nil {
+		return nil, nil, err
+	}
+	if exists, _ := s.userRepo.ExistsByEmail(invitation.Email); exists {
+		return nil, nil, errors.New("email already taken")
+	}
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := &models.User{
+		Name:            req.Name,
+		Email:           invitation.Email,
+		Password:        hash,
+		Role:            invitation.Role,
+		IsEmailVerified: true,
+	}
+	var membership *models.Membership
+	if invitation.OrganizationID != nil {
+		membership = &models.Membership{OrganizationID: *invitation.OrganizationID, Role: invitation.OrganizationRole}
+	}
+	accepted, err := s.repo.Accept(invitation.ID, now, user, membership)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !accepted {
+		return nil, nil, ErrInvalidInvitation
+	}
+	logger.Log.Info("Invitation accepted", "invitationId", invitation.ID, "userId", user.ID, "role", user.Role)
+
+	tokens, err := s.tokenService.GenerateAuthTokens(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// Unknown kids trigger a reload at most this often, so a key created by
+// another instance is picked up without letting bogus tokens hammer the DB.
+const keyReloadCooldown = time.Minute
+
+// KeyService owns the JWT signing keys. With HS256 it wraps JWT_SECRET; with
+// RS256/EdDSA it keeps rotating key pairs in the database and publishes their
+// public halves as a JWKS.
+type KeyService struct {
+	repo repository.SigningKeyRepository
+	cfg  *config.Config
+
+	mu          sync.RWMutex
+	active      *utils.SigningKey
+	activeSince time.Time
+	keys        map[string]*utils.SigningKey
+	lastReload  time.Time
+}
+
+func NewKeyService(repo repository.SigningKeyRepository, cfg *config.Config) (*KeyService, error) {
+	s := &KeyService{repo: repo, cfg: cfg, keys: make(map[string]*utils.SigningKey)}
+
+	if s.isSymmetric() {
+		key := utils.NewHMACKey("", cfg.JWT.Secret)
+		s.active = key
+		s.keys[key.ID] = key
+		return s, nil
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if s.active == nil {
+		if err := s.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *KeyService) SigningKey() (*utils.SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.active == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return s.active, nil
+}
+
+func (s *KeyService) VerificationKey(kid string) (*utils.SigningKey, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+
+	if !s.isSymmetric() && s.reloadDue() {
+		if err := s.Reload(); err != nil {
+			return nil, err
+		}
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// Reload replaces the in-memory keys with the ones still usable in the database
+func (s *KeyService) Reload() error {
+	if s.isSymmetric() {
+		return nil
+	}
+
+	now := time.Now()
+	rows, err := s.repo.FindUsable(s.cfg.JWT.Algorithm, now.Add(-s.gracePeriod()))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*utils.SigningKey)
+	var active *utils.SigningKey
+	var activeSince time.Time
+	for _, row := range rows {
+		key, err := utils.ParseSigningKey(row.ID, row.Algorithm, row.PrivateKey)
+		if err != nil {
+			logger.Log.Error("Skipping unreadable signing key", "kid", row.ID, "error", err)
+			continue
+		}
+		keys[key.ID] = key
+		// Rows come newest first
+		if active == nil && row.RetiredAt == nil {
+			active = key
+			activeSince = row.CreatedAt
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.active = active
+	s.activeSince = activeSince
+	s.lastReload = now
+	s.mu.Unlock()
+	return nil
+}
+
+// Rotate creates a new signing key and retires the previous one into its grace window
+func (s *KeyService) Rotate() error {
+	if s.isSymmetric() {
+		return errors.New("key rotation requires an asymmetric JWT_ALGORITHM")
+	}
+
+	key, err := utils.GenerateSigningKey(uuid.NewString(), s.cfg.JWT.Algorithm)
+	if err != nil {
+		return err
+	}
+	privatePEM, err := key.EncodePrivateKey()
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(&models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: privatePEM,
+	}); err != nil {
+		return err
+	}
+	if err := s.repo.Retire(key.ID, time.Now()); err != nil {
+		return err
+	}
+
+	logger.Log.Info("Rotated JWT signing key", "kid", key.ID, "alg", key.Algorithm)
+	return s.Reload()
+}
+
+// RotateIfDue rotates the active key once it is older than the rotation period
+// and drops keys whose grace window has ended.
+func (s *KeyService) RotateIfDue() error {
+	if s.isSymmetric() {
+		return nil
+	}
+
+	if err := s.repo.DeleteRetiredBefore(time.Now().Add(-s.gracePeriod())); err != nil {
+		return err
+	}
+
+	if s.cfg.JWT.KeyRotationDays <= 0 {
+		return nil
+	}
+	s.mu.RLock()
+	due := s.active == nil || time.Since(s.activeSince) >= time.Duration(s.cfg.JWT.KeyRotationDays)*24*time.Hour
+	s.mu.RUnlock()
+
+	if due {
+		return s.Rotate()
+	}
+	return nil
+}
+
+// StartRotation periodically reloads keys (to see rotations done by other instances) and rotates when due
+func (s *KeyService) StartRotation(interval time.Duration) {
+	if s.isSymmetric() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Reload(); err != nil {
+				logger.Log.Error("Failed to reload signing keys", "error", err)
+				continue
+			}
+			if err := s.RotateIfDue(); err != nil {
+				logger.Log.Error("Failed to rotate signing key", "error", err)
+			}
+		}
+	}()
+}
+
+// JWKS returns the public keys that currently verify tokens. Shared secrets are never published.
+func (s *KeyService) JWKS() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]map[string]interface{}, 0, len(s.keys))
+	for _, key := range s.keys {
+		if jwk := key.JWK(); jwk != nil {
+			keys = append(keys, jwk)
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+func (s *KeyService) lookup(kid string) (*utils.SigningKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *KeyService) reloadDue() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.lastReload) >= keyReloadCooldown
+}
+
+func (s *KeyService) isSymmetric() bool {
+	return s.cfg.JWT.Algorithm == utils.AlgorithmHS256
+}
+
+func (s *KeyService) gracePeriod() time.Duration {
+	return time.Duration(s.cfg.JWT.KeyGraceDays) * 24 * time.Hour
+}
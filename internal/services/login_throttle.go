@@ -0,0 +1,124 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+)
+
+// LoginThrottledError is returned when a login attempt is refused before the password is checked
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // Lockout rather than the delay between attempts
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "too many failed login attempts, try again later"
+	}
+	return "too many failed login attempts, wait before retrying"
+}
+
+type ipFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle applies the lockout rules of config.LockoutConfig. Account state lives on the
+// user row; failures per source IP are only kept in memory, per instance.
+type loginThrottle struct {
+	cfg *config.LockoutConfig
+
+	mu  sync.Mutex
+	ips map[string]*ipFailures
+}
+
+func newLoginThrottle(cfg *config.LockoutConfig) *loginThrottle {
+	return &loginThrottle{cfg: cfg, ips: make(map[string]*ipFailures)}
+}
+
+func (t *loginThrottle) window() time.Duration {
+	return time.Duration(t.cfg.DurationMinutes) * time.Minute
+}
+
+// delay is the wait imposed after the given number of consecutive failures
+func (t *loginThrottle) delay(failures int) time.Duration {
+	if t.cfg.DelaySeconds <= 0 || failures <= 0 {
+		return 0
+	}
+	seconds := float64(t.cfg.DelaySeconds) * math.Pow(2, float64(failures-1))
+	if t.cfg.MaxDelaySeconds > 0 {
+		seconds = math.Min(seconds, float64(t.cfg.MaxDelaySeconds))
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (t *loginThrottle) checkAccount(user *models.User, now time.Time) *LoginThrottledError {
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		return &LoginThrottledError{RetryAfter: user.LockedUntil.Sub(now), Locked: true}
+	}
+	if user.LastFailedLoginAt != nil && user.LastFailedLoginAt.After(now.Add(-t.window())) {
+		next := user.LastFailedLoginAt.Add(t.delay(user.FailedLoginAttempts))
+		if next.After(now) {
+			return &LoginThrottledError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// accountLocked reports whether this many failures in a row lock the account
+func (t *loginThrottle) accountLocked(failures int) bool {
+	return t.cfg.MaxAttempts > 0 && failures >= t.cfg.MaxAttempts
+}
+
+func (t *loginThrottle) checkIP(ip string, now time.Time) *LoginThrottledError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.ips[ip]
+	if ok && entry.lockedUntil.After(now) {
+		return &LoginThrottledError{RetryAfter: entry.lockedUntil.Sub(now), Locked: true}
+	}
+	return nil
+}
+
+// failIP counts a failure from ip and reports whether it just got locked out
+func (t *loginThrottle) failIP(ip string, now time.Time) bool {
+	if t.cfg.IPMaxAttempts <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.ips[ip]
+	if !ok || entry.last.Before(now.Add(-t.window())) {
+		if len(t.ips) >= 10000 {
+			t.pruneLocked(now)
+		}
+		entry = &ipFailures{}
+		t.ips[ip] = entry
+	}
+	entry.count++
+	entry.last = now
+
+	if entry.count >= t.cfg.IPMaxAttempts {
+		entry.count = 0
+		entry.lockedUntil = now.Add(t.window())
+		return true
+	}
+	return false
+}
+
+// pruneLocked forgets addresses that are neither locked nor failed recently. t.mu must be held.
+func (t *loginThrottle) pruneLocked(now time.Time) {
+	for ip, entry := range t.ips {
+		if entry.lockedUntil.Before(now) && entry.last.Before(now.Add(-t.window())) {
+			delete(t.ips, ip)
+		}
+	}
+}
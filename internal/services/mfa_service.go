@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	recoveryCodeCount = 10
+	// A pending MFA token is burned after this many wrong codes
+	maxMFAAttempts = 5
+	// Accept codes from one step before/after to tolerate clock drift
+	totpSkew = 1
+)
+
+// MFARequiredError is returned by Login when the password is correct but a second factor is still needed
+type MFARequiredError struct {
+	Token   string
+	Expires time.Time
+}
+
+func (e *MFARequiredError) Error() string {
+	return "multi-factor authentication required"
+}
+
+// mfaAttempts counts the wrong codes sent for a pending MFA token until the token expires
+type mfaAttempts struct {
+	count   int
+	expires time.Time
+}
+
+func (s *authService) VerifyMFA(mfaToken, code string) (*models.User, map[string]interface{}, error) {
+	payload, err := s.tokenService.ParseToken(mfaToken)
+	if err != nil || payload.Type != models.TokenTypeMFAPending {
+		return nil, nil, errors.New("invalid or expired mfa token")
+	}
+	tokenDoc, err := s.tokenService.VerifyToken(mfaToken, models.TokenTypeMFAPending)
+	if err != nil {
+		return nil, nil, errors.New("invalid or expired mfa token")
+	}
+
+	userUUID, _ := uuid.Parse(tokenDoc.UserID)
+	user, err := s.userRepo.FindByID(userUUID)
+	if err != nil || !user.MFAEnabled {
+		return nil, nil, errors.New("invalid or expired mfa token")
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		if s.recordMFAFailure(tokenDoc) {
+			logger.Log.Warn("Too many invalid MFA codes, pending login revoked", "userId", user.ID)
+			s.tokenRepo.Delete(tokenDoc)
+		}
+		return nil, nil, errors.New("invalid mfa code")
+	}
+
+	s.clearMFAFailures(tokenDoc)
+	if err := s.tokenRepo.Delete(tokenDoc); err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.tokenService.GenerateAuthTokens(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+func (s *authService) EnrollMFA(userID uuid.UUID) (map[string]interface{}, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("mfa is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	// The secret stays inactive until ConfirmMFA proves the app was set up correctly
+	user.MFASecret = secret
+	user.MFALastUsedStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"secret":     secret,
+		"otpauthUrl": utils.TOTPURI(s.cfg.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *authService) ConfirmMFA(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("mfa is already enabled")
+	}
+	if user.MFASecret == "" {
+		return nil, errors.New("mfa enrollment not started")
+	}
+
+	ok, err := s.checkTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid mfa code")
+	}
+
+	user.MFAEnabled = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(user)
+}
+
+func (s *authService) DisableMFA(userID uuid.UUID, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !user.MFAEnabled {
+		return errors.New("mfa is not enabled")
+	}
+
+	ok, err := s.checkSecondFactor(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid mfa code")
+	}
+
+	return resetUserMFA(s.userRepo, s.mfaRepo, user)
+}
+
+func (s *authService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.MFAEnabled {
+		return nil, errors.New("mfa is not enabled")
+	}
+
+	ok, err := s.checkTOTP(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid mfa code")
+	}
+	return s.issueRecoveryCodes(user)
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code
+func (s *authService) checkSecondFactor(user *models.User, code string) (bool, error) {
+	if ok, err := s.checkTOTP(user, code); ok || err != nil {
+		return ok, err
+	}
+
+	used, err := s.mfaRepo.Consume(user.ID.String(), utils.HashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	if used {
+		logger.Log.Info("MFA recovery code used", "userId", user.ID)
+	}
+	return used, nil
+}
+
+// checkTOTP validates a code and remembers its step so it cannot be replayed
+func (s *authService) checkTOTP(user *models.User, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(user.MFASecret, code, time.Now(), totpSkew)
+	if !ok || step <= user.MFALastUsedStep {
+		return false, nil
+	}
+
+	user.MFALastUsedStep = step
+	if err := s.userRepo.Update(user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *authService) issueRecoveryCodes(user *models.User) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+
+	if err := s.mfaRepo.ReplaceForUser(user.ID.String(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// resetUserMFA turns MFA off and removes the secret and all recovery codes
+func resetUserMFA(userRepo repository.UserRepository, mfaRepo repository.MFARecoveryCodeRepository, user *models.User) error {
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	user.MFALastUsedStep = 0
+	if err := userRepo.Update(user); err != nil {
+		return err
+	}
+	return mfaRepo.DeleteByUserID(user.ID.String())
+}
+
+// recordMFAFailure counts a wrong code against the pending token and reports whether the limit is reached
+func (s *authService) recordMFAFailure(tokenDoc *models.Token) bool {
+	s.mfaMu.Lock()
+	defer s.mfaMu.Unlock()
+
+	// Tokens that expired without a successful login would otherwise stay here forever
+	now := time.Now()
+	for id, attempts := range s.mfaAttempts {
+		if now.After(attempts.expires) {
+			delete(s.mfaAttempts, id)
+		}
+	}
+
+	attempts := s.mfaAttempts[tokenDoc.ID]
+	attempts.count++
+	attempts.expires = tokenDoc.Expires
+	if attempts.count >= maxMFAAttempts {
+		delete(s.mfaAttempts, tokenDoc.ID)
+		return true
+	}
+	s.mfaAttempts[tokenDoc.ID] = attempts
+	return false
+}
+
+func (s *authService) clearMFAFailures(tokenDoc *models.Token) {
+	s.mfaMu.Lock()
+	defer s.mfaMu.Unlock()
+	delete(s.mfaAttempts, tokenDoc.ID)
+}
@@ -0,0 +1,573 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// OAuthError is an error response defined by RFC 6749, e.g. invalid_grant
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+type oauthService struct {
+	repo         repository.OAuthRepository
+	userRepo     repository.UserRepository
+	tokenRepo    repository.TokenRepository
+	tokenService *TokenService
+	denylist     TokenDenylist
+	cfg          *config.Config
+}
+
+func NewOAuthService(repo repository.OAuthRepository, userRepo repository.UserRepository, tokenRepo repository.TokenRepository, tokenService *TokenService, denylist TokenDenylist, cfg *config.Config) OAuthService {
+	return &oauthService{
+		repo:         repo,
+		userRepo:     userRepo,
+		tokenRepo:    tokenRepo,
+		tokenService: tokenService,
+		denylist:     denylist,
+		cfg:          cfg,
+	}
+}
+
+func (s *oauthService) CreateClient(req OAuthClientRequest) (*models.OAuthClient, string, error) {
+	client := &models.OAuthClient{
+		ID:           uuid.NewString(),
+		Name:         req.Name,
+		Public:       req.Public,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		Scopes:       strings.Join(req.Scopes, " "),
+		GrantTypes:   strings.Join(req.GrantTypes, " "),
+		Audiences:    strings.Join(req.Audiences, " "),
+	}
+	if err := validateClient(client); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if !client.Public {
+		secret = generateClientSecret()
+		client.SecretHash = utils.HashSecret(secret)
+	}
+	if err := s.repo.CreateClient(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *oauthService) GetClients() ([]models.OAuthClient, error) {
+	return s.repo.FindClients()
+}
+
+func (s *oauthService) GetClient(id string) (*models.OAuthClient, error) {
+	client, err := s.repo.FindClientByID(id)
+	if err != nil {
+		return nil, errors.New("client not found")
+	}
+	return client, nil
+}
+
+func (s *oauthService) UpdateClient(id string, req UpdateOAuthClientRequest) (*models.OAuthClient, error) {
+	client, err := s.GetClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		client.Name = req.Name
+	}
+	if req.RedirectURIs != nil {
+		client.RedirectURIs = strings.Join(req.RedirectURIs, " ")
+	}
+	if req.Scopes != nil {
+		client.Scopes = strings.Join(req.Scopes, " ")
+	}
+	if req.GrantTypes != nil {
+		client.GrantTypes = strings.Join(req.GrantTypes, " ")
+	}
+	if req.Audiences != nil {
+		client.Audiences = strings.Join(req.Audiences, " ")
+	}
+	if err := validateClient(client); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateClient(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *oauthService) DeleteClient(id string) error {
+	client, err := s.GetClient(id)
+	if err != nil {
+		return err
+	}
+	// Access tokens already issued stay valid until they expire
+	if err := s.tokenRepo.DeleteByClientID(client.ID); err != nil {
+		return err
+	}
+	return s.repo.DeleteClient(client)
+}
+
+func (s *oauthService) RotateClientSecret(id string) (*models.OAuthClient, string, error) {
+	client, err := s.GetClient(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if client.Public {
+		return nil, "", errors.New("public clients have no secret")
+	}
+
+	secret := generateClientSecret()
+	client.SecretHash = utils.HashSecret(secret)
+	if err := s.repo.UpdateClient(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *oauthService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	client, err := s.repo.FindClientByID(clientID)
+	if err != nil {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if client.Public {
+		if clientSecret != "" {
+			return nil, oauthError("invalid_client", "client authentication failed")
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashSecret(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	return client, nil
+}
+
+// ValidateAuthorization checks an authorization request before the user is asked for consent
+func (s *oauthService) ValidateAuthorization(req OAuthAuthorizeRequest) (*models.OAuthClient, string, error) {
+	client, err := s.findRedirectClient(req)
+	if err != nil {
+		return nil, "", err
+	}
+	scope, err := checkAuthorizationRequest(client, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, scope, nil
+}
+
+// Authorize records the user's consent decision and returns where to send the browser next
+func (s *oauthService) Authorize(userID uuid.UUID, req OAuthAuthorizeRequest, approved bool) (string, error) {
+	client, err := s.findRedirectClient(req)
+	if err != nil {
+		return "", err
+	}
+
+	// The redirect URI is trusted from here on, so errors are reported to the client through it
+	scope, err := checkAuthorizationRequest(client, req)
+	if err != nil {
+		return redirectWithParams(req.RedirectURI, errorParams(err, req.State)), nil
+	}
+	if !approved {
+		return redirectWithParams(req.RedirectURI, errorParams(oauthError("access_denied", "the user denied the request"), req.State)), nil
+	}
+
+	// Expired codes are cleaned up opportunistically
+	s.repo.DeleteExpiredAuthorizationCodes(time.Now())
+
+	code := rand.Text()
+	err = s.repo.CreateAuthorizationCode(&models.OAuthAuthorizationCode{
+		CodeHash:      utils.HashSecret(code),
+		ClientID:      client.ID,
+		UserID:        userID.String(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		Expires:       time.Now().Add(time.Duration(s.cfg.OAuth.CodeExpirationMinutes) * time.Minute),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return redirectWithParams(req.RedirectURI, params), nil
+}
+
+func (s *oauthService) Token(client *models.OAuthClient, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.GrantType != models.GrantTypeClientCredentials && req.GrantType != models.GrantTypeAuthorizationCode && req.GrantType != models.GrantTypeRefreshToken {
+		return nil, oauthError("unsupported_grant_type", "unsupported grant_type")
+	}
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauthError("unauthorized_client", "client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeClientCredentials:
+		return s.clientCredentialsGrant(client, req)
+	case models.GrantTypeAuthorizationCode:
+		return s.authorizationCodeGrant(client, req)
+	default:
+		return s.refreshTokenGrant(client, req)
+	}
+}
+
+// Introspect reports whether a token is currently usable (RFC 7662). Any token type hint is
+// ignored because the type is read from the token itself.
+func (s *oauthService) Introspect(client *models.OAuthClient, token string) (*OAuthIntrospection, error) {
+	if client.Public {
+		return nil, oauthError("invalid_client", "public clients cannot introspect tokens")
+	}
+
+	payload := s.activeToken(token)
+	if payload == nil {
+		return &OAuthIntrospection{Active: false}, nil
+	}
+
+	res := &OAuthIntrospection{
+		Active:   true,
+		Sub:      payload.Sub,
+		Type:     payload.Type,
+		Scope:    payload.Scope,
+		ClientID: payload.ClientID,
+		Aud:      payload.Audience,
+		Jti:      payload.ID,
+	}
+	if payload.ExpiresAt != nil {
+		res.Exp = payload.ExpiresAt.Unix()
+	}
+	if payload.IssuedAt != nil {
+		res.Iat = payload.IssuedAt.Unix()
+	}
+	return res, nil
+}
+
+// Revoke invalidates an access or refresh token (RFC 7009). Unknown or already invalid
+// tokens are not an error, so the response does not reveal anything about them.
+func (s *oauthService) Revoke(client *models.OAuthClient, token string) error {
+	payload := s.activeToken(token)
+	if payload == nil {
+		return nil
+	}
+
+	// Clients revoke their own tokens; confidential clients (gateways) may also revoke first-party ones
+	if payload.ClientID != client.ID && (payload.ClientID != "" || client.Public) {
+		return nil
+	}
+
+	if payload.Type == models.TokenTypeAccess {
+		return s.denylist.RevokeAccessToken(payload)
+	}
+
+	// Revoking a refresh token ends the whole grant, including rotated tokens
+	tokenDoc, err := s.tokenService.VerifyToken(token, payload.Type)
+	if err != nil {
+		return nil
+	}
+	if tokenDoc.Family != "" {
+		return s.tokenRepo.DeleteByFamily(tokenDoc.Family)
+	}
+	return s.tokenRepo.Delete(tokenDoc)
+}
+
+// activeToken returns the claims of a valid access or refresh token of an active user or a client,
+// or nil if it is not usable
+func (s *oauthService) activeToken(token string) *utils.TokenPayload {
+	payload, err := s.tokenService.ParseToken(token)
+	if err != nil {
+		return nil
+	}
+
+	switch payload.Type {
+	case models.TokenTypeAccess:
+		if s.denylist.IsRevoked(payload) {
+			return nil
+		}
+	case models.TokenTypeRefresh, models.TokenTypeOAuthRefresh:
+		// Refresh tokens are only valid while stored and not yet rotated
+		if _, err := s.tokenService.VerifyToken(token, payload.Type); err != nil {
+			return nil
+		}
+	default:
+		// Reset, verification and MFA tokens are not credentials for other services
+		return nil
+	}
+
+	// Tokens of deleted, suspended or banned users are not usable either. client_credentials
+	// tokens have the client as subject and no user behind them.
+	if payload.ClientID == "" || payload.Sub != payload.ClientID {
+		userID, err := uuid.Parse(payload.Sub)
+		if err != nil {
+			return nil
+		}
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil || checkAccountStatus(user) != nil {
+			return nil
+		}
+		// Like middleware.Auth, first-party access tokens are stale once the user's permissions changed
+		if payload.Type == models.TokenTypeAccess && payload.ClientID == "" && payload.PermissionsVersion != user.PermissionsVersion {
+			return nil
+		}
+	}
+	return payload
+}
+
+func (s *oauthService) clientCredentialsGrant(client *models.OAuthClient, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if client.Public {
+		return nil, oauthError("unauthorized_client", "public clients cannot use client_credentials")
+	}
+	scope, err := resolveScope(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client acts on its own behalf, so it is also the subject. No refresh token (RFC 6749 section 4.4.3).
+	expires := time.Duration(s.cfg.OAuth.AccessExpirationMinutes) * time.Minute
+	accessToken, _, err := s.tokenService.GenerateClientToken(client.ID, client.ID, scope, s.audiences(client), expires, models.TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(expires.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (s *oauthService) authorizationCodeGrant(client *models.OAuthClient, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	code, err := s.repo.ConsumeAuthorizationCode(utils.HashSecret(req.Code))
+	if err != nil {
+		return nil, oauthError("invalid_grant", "invalid or expired authorization code")
+	}
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client or redirect_uri")
+	}
+	challenge := sha256.Sum256([]byte(req.CodeVerifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(challenge[:])), []byte(code.CodeChallenge)) != 1 {
+		return nil, oauthError("invalid_grant", "code_verifier does not match the code challenge")
+	}
+
+	return s.issueUserTokens(client, code.UserID, intersectScopes(code.Scope, client.Scopes), uuid.NewString())
+}
+
+func (s *oauthService) refreshTokenGrant(client *models.OAuthClient, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	payload, err := s.tokenService.ParseToken(req.RefreshToken)
+	if err != nil || payload.Type != models.TokenTypeOAuthRefresh || payload.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "invalid refresh token")
+	}
+
+	tokenDoc, err := s.tokenService.VerifyToken(req.RefreshToken, models.TokenTypeOAuthRefresh)
+	if err != nil {
+		// A token that was already exchanged is being replayed: assume it leaked
+		if usedDoc, findErr := s.tokenRepo.FindRotatedRefreshToken(req.RefreshToken, models.TokenTypeOAuthRefresh); findErr == nil {
+			s.revokeTokenFamily(usedDoc)
+		}
+		return nil, oauthError("invalid_grant", "invalid refresh token")
+	}
+
+	// The client may narrow the scope but never widen it (RFC 6749 section 6). Scopes taken
+	// away from the client since the grant are dropped as well.
+	granted := intersectScopes(payload.Scope, client.Scopes)
+	if granted == "" && payload.Scope != "" {
+		return nil, oauthError("invalid_grant", "the granted scopes are no longer allowed for this client")
+	}
+	scope, err := resolveScope(req.Scope, granted)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.tokenRepo.MarkRotated(tokenDoc)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		s.revokeTokenFamily(tokenDoc)
+		return nil, oauthError("invalid_grant", "invalid refresh token")
+	}
+	return s.issueUserTokens(client, tokenDoc.UserID, scope, tokenDoc.Family)
+}
+
+func (s *oauthService) issueUserTokens(client *models.OAuthClient, userID, scope, family string) (*OAuthTokenResponse, error) {
+	userUUID, _ := uuid.Parse(userID)
+	user, err := s.userRepo.FindByID(userUUID)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "user not found")
+	}
+	// Clients cannot keep acting for a user who was suspended or banned since approving them
+	if err := checkAccountStatus(user); err != nil {
+		return nil, oauthError("invalid_grant", err.Error())
+	}
+
+	expires := time.Duration(s.cfg.OAuth.AccessExpirationMinutes) * time.Minute
+	accessToken, _, err := s.tokenService.GenerateClientToken(userID, client.ID, scope, s.audiences(client), expires, models.TokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	res := &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(expires.Seconds()),
+		Scope:       scope,
+	}
+
+	if client.AllowsGrant(models.GrantTypeRefreshToken) {
+		refreshExpires := time.Duration(s.cfg.OAuth.RefreshExpirationDays) * 24 * time.Hour
+		refreshToken, refreshExp, err := s.tokenService.GenerateClientToken(userID, client.ID, scope, nil, refreshExpires, models.TokenTypeOAuthRefresh)
+		if err != nil {
+			return nil, err
+		}
+		err = s.tokenRepo.Create(&models.Token{
+			Token:    refreshToken,
+			UserID:   userID,
+			Type:     models.TokenTypeOAuthRefresh,
+			Family:   family,
+			ClientID: client.ID,
+			Expires:  refreshExp,
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.RefreshToken = refreshToken
+	}
+	return res, nil
+}
+
+func (s *oauthService) revokeTokenFamily(token *models.Token) {
+	logger.Log.Warn("OAuth refresh token reuse detected, revoking token family",
+		"userId", token.UserID,
+		"clientId", token.ClientID,
+		"family", token.Family,
+		"tokenId", token.ID,
+	)
+	if err := s.tokenRepo.DeleteByFamily(token.Family); err != nil {
+		logger.Log.Error("Failed to revoke token family", "family", token.Family, "error", err)
+	}
+}
+
+func (s *oauthService) audiences(client *models.OAuthClient) []string {
+	if audiences := strings.Fields(client.Audiences); len(audiences) > 0 {
+		return audiences
+	}
+	return []string{s.cfg.OAuth.DefaultAudience}
+}
+
+// findRedirectClient checks the client and redirect URI. These errors must be shown to the
+// user instead of redirecting, or the endpoint would be an open redirector.
+func (s *oauthService) findRedirectClient(req OAuthAuthorizeRequest) (*models.OAuthClient, error) {
+	client, err := s.repo.FindClientByID(req.ClientID)
+	if err != nil {
+		return nil, oauthError("invalid_request", "unknown client")
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	return client, nil
+}
+
+func checkAuthorizationRequest(client *models.OAuthClient, req OAuthAuthorizeRequest) (string, error) {
+	if req.ResponseType != "code" {
+		return "", oauthError("unsupported_response_type", "only response_type=code is supported")
+	}
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return "", oauthError("unauthorized_client", "client may not use the authorization code grant")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return "", oauthError("invalid_request", "PKCE with code_challenge_method=S256 is required")
+	}
+	return resolveScope(req.Scope, client.Scopes)
+}
+
+func validateClient(client *models.OAuthClient) error {
+	if client.Public && client.AllowsGrant(models.GrantTypeClientCredentials) {
+		return errors.New("public clients cannot use client_credentials")
+	}
+	if client.AllowsGrant(models.GrantTypeAuthorizationCode) && client.RedirectURIs == "" {
+		return errors.New("authorization_code clients need at least one redirect URI")
+	}
+	return nil
+}
+
+func generateClientSecret() string {
+	return rand.Text() + rand.Text()
+}
+
+// resolveScope returns the requested scope, or all allowed scopes when none was requested
+func resolveScope(requested, allowed string) (string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, nil
+	}
+	for _, scope := range strings.Fields(requested) {
+		if !containsScope(allowed, scope) {
+			return "", oauthError("invalid_scope", "scope \""+scope+"\" is not allowed")
+		}
+	}
+	return strings.Join(strings.Fields(requested), " "), nil
+}
+
+// intersectScopes keeps the scopes that are also in allowed, in their original order
+func intersectScopes(scopes, allowed string) string {
+	kept := make([]string, 0)
+	for _, scope := range strings.Fields(scopes) {
+		if containsScope(allowed, scope) {
+			kept = append(kept, scope)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func containsScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func errorParams(err error, state string) url.Values {
+	params := url.Values{"error": {"server_error"}}
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		params.Set("error", oauthErr.Code)
+		params.Set("error_description", oauthErr.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return params
+}
+
+func redirectWithParams(redirectURI string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	return redirectURI + separator + params.Encode()
+}
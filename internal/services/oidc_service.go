@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// How long the user has to complete the sign-in at the provider
+	oidcAuthRequestTimeout = 10 * time.Minute
+	oidcHTTPTimeout        = 10 * time.Second
+)
+
+var (
+	ErrUnknownOIDCProvider     = errors.New("unknown identity provider")
+	ErrOIDCProviderUnavailable = errors.New("identity provider is unavailable")
+)
+
+// OIDCCallbackResult is either a completed sign-in or a newly linked identity
+type OIDCCallbackResult struct {
+	User     *models.User
+	Tokens   map[string]interface{} // Nil when the callback finished linking
+	Identity *models.Identity
+	Linked   bool
+}
+
+// oidcProvider discovers the provider metadata lazily so the API can start while a provider is unreachable
+type oidcProvider struct {
+	cfg config.OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type oidcClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Some providers send "true" as a string
+	Name          string      `json:"name"`
+}
+
+type oidcService struct {
+	providers    map[string]*oidcProvider
+	repo         repository.IdentityRepository
+	userRepo     repository.UserRepository
+	tokenService *TokenService
+	httpClient   *http.Client
+	registration string // Registration mode, new accounts are only provisioned when open
+}
+
+func NewOIDCService(repo repository.IdentityRepository, userRepo repository.UserRepository, tokenService *TokenService, cfg *config.Config) OIDCService {
+	providers := make(map[string]*oidcProvider)
+	for name, providerCfg := range cfg.OIDC {
+		providers[name] = &oidcProvider{cfg: providerCfg}
+	}
+
+	return &oidcService{
+		providers:    providers,
+		repo:         repo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+		httpClient:   &http.Client{Timeout: oidcHTTPTimeout},
+		registration: cfg.Registration.Mode,
+	}
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *oidcService) AuthorizationURL(providerName string, linkUserID *uuid.UUID) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownOIDCProvider
+	}
+	oauthCfg, _, err := s.discover(provider)
+	if err != nil {
+		return "", err
+	}
+
+	// Abandoned sign-ins are cleaned up opportunistically
+	s.repo.DeleteExpiredAuthRequests(time.Now())
+
+	request := &models.OIDCAuthRequest{
+		State:        rand.Text(),
+		Provider:     providerName,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        rand.Text(),
+		Expires:      time.Now().Add(oidcAuthRequestTimeout),
+	}
+	if linkUserID != nil {
+		request.UserID = linkUserID.String()
+	}
+	if err := s.repo.CreateAuthRequest(request); err != nil {
+		return "", err
+	}
+
+	return oauthCfg.AuthCodeURL(request.State, oauth2.S256ChallengeOption(request.CodeVerifier), oidc.Nonce(request.Nonce)), nil
+}
+
+func (s *oidcService) Callback(providerName, state, code string) (*OIDCCallbackResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	request, err := s.repo.ConsumeAuthRequest(state, providerName)
+	if err != nil {
+		return nil, errors.New("sign-in request not found or expired")
+	}
+	oauthCfg, verifier, err := s.discover(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(context.Background(), s.httpClient), oidcHTTPTimeout)
+	defer cancel()
+
+	token, err := oauthCfg.Exchange(ctx, code, oauth2.VerifierOption(request.CodeVerifier))
+	if err != nil {
+		logger.Log.Debug("OIDC code exchange failed", "provider", providerName, "error", err)
+		return nil, errors.New("could not complete sign-in with the identity provider")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("identity provider did not return an id token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != request.Nonce {
+		logger.Log.Debug("OIDC id token rejected", "provider", providerName, "error", err)
+		return nil, errors.New("invalid id token")
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, errors.New("invalid id token")
+	}
+
+	if request.UserID != "" {
+		return s.link(request.UserID, providerName, idToken.Subject, claims)
+	}
+	return s.login(providerName, idToken.Subject, claims)
+}
+
+func (s *oidcService) ListIdentities(userID uuid.UUID) ([]models.Identity, error) {
+	return s.repo.FindByUserID(userID.String())
+}
+
+func (s *oidcService) Unlink(userID uuid.UUID, id uint) error {
+	identity, err := s.repo.FindByID(userID.String(), id)
+	if err != nil {
+		return errors.New("identity not found")
+	}
+	return s.repo.Delete(identity)
+}
+
+func (s *oidcService) login(providerName, subject string, claims oidcClaims) (*OIDCCallbackResult, error) {
+	now := time.Now()
+	identity, err := s.repo.FindByProviderSubject(providerName, subject)
+	var user *models.User
+	if err == nil {
+		userUUID, _ := uuid.Parse(identity.UserID)
+		if user, err = s.userRepo.FindByID(userUUID); err != nil {
+			return nil, errors.New("user not found")
+		}
+	} else {
+		if user, err = s.findOrProvisionUser(claims); err != nil {
+			return nil, err
+		}
+		identity = &models.Identity{UserID: user.ID.String(), Provider: providerName, Subject: subject}
+	}
+
+	if claims.Email != "" {
+		identity.Email = claims.Email
+	}
+	identity.LastLoginAt = &now
+	if identity.ID == 0 {
+		err = s.repo.Create(identity)
+	} else {
+		err = s.repo.Update(identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider only replaces the password step, a second factor still applies
+	if user.MFAEnabled {
+		mfaToken, expires, err := s.tokenService.GenerateMFAToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &MFARequiredError{Token: mfaToken, Expires: expires}
+	}
+
+	tokens, err := s.tokenService.GenerateAuthTokens(user)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCCallbackResult{User: user, Tokens: tokens, Identity: identity}, nil
+}
+
+// findOrProvisionUser links to an existing account only when the provider vouches for the email
+func (s *oidcService) findOrProvisionUser(claims oidcClaims) (*models.User, error) {
+	if claims.Email == "" {
+		return nil, errors.New("identity provider did not return an email address")
+	}
+	verified := isTrueClaim(claims.EmailVerified)
+
+	if user, err := s.userRepo.FindByEmail(claims.Email); err == nil {
+		if !verified {
+			return nil, errors.New("an account with this email already exists, sign in and link the provider instead")
+		}
+		return user, nil
+	}
+	if err := selfRegistrationError(s.registration); err != nil {
+		return nil, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+	// Unknown to anyone, the password can be set through forgot-password
+	hash, err := utils.HashPassword(rand.Text())
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Name:            name,
+		Email:           claims.Email,
+		Password:        hash,
+		Role:            "user",
+		IsEmailVerified: verified,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *oidcService) link(userID, providerName, subject string, claims oidcClaims) (*OIDCCallbackResult, error) {
+	userUUID, _ := uuid.Parse(userID)
+	user, err := s.userRepo.FindByID(userUUID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if identity, err := s.repo.FindByProviderSubject(providerName, subject); err == nil {
+		if identity.UserID != userID {
+			return nil, errors.New("this account is already linked to another user")
+		}
+		return &OIDCCallbackResult{User: user, Identity: identity, Linked: true}, nil
+	}
+
+	identity := &models.Identity{UserID: userID, Provider: providerName, Subject: subject, Email: claims.Email}
+	if err := s.repo.Create(identity); err != nil {
+		return nil, err
+	}
+	return &OIDCCallbackResult{User: user, Identity: identity, Linked: true}, nil
+}
+
+func (s *oidcService) discover(provider *oidcProvider) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.oauth != nil {
+		return provider.oauth, provider.verifier, nil
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(context.Background(), s.httpClient), oidcHTTPTimeout)
+	defer cancel()
+	discovered, err := oidc.NewProvider(ctx, provider.cfg.Issuer)
+	if err != nil {
+		logger.Log.Error("OIDC discovery failed", "provider", provider.cfg.Name, "error", err)
+		return nil, nil, ErrOIDCProviderUnavailable
+	}
+
+	provider.oauth = &oauth2.Config{
+		ClientID:     provider.cfg.ClientID,
+		ClientSecret: provider.cfg.ClientSecret,
+		RedirectURL:  provider.cfg.RedirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       provider.cfg.Scopes,
+	}
+	provider.verifier = discovered.VerifierContext(oidc.ClientContext(context.Background(), s.httpClient), &oidc.Config{ClientID: provider.cfg.ClientID})
+	return provider.oauth, provider.verifier, nil
+}
+
+func isTrueClaim(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrOrganizationForbidden = errors.New("your role in the organization does not allow this")
+	ErrNotOrganizationMember = errors.New("not a member of the organization")
+	ErrLastOwner             = errors.New("an organization needs at least one owner")
+)
+
+type organizationService struct {
+	repo           repository.OrganizationRepository
+	invitationRepo repository.InvitationRepository
+	userRepo       repository.UserRepository
+	emailService   EmailService
+	rbac           RBACService
+	cfg            *config.Config
+}
+
+func NewOrganizationService(repo repository.OrganizationRepository, invitationRepo repository.InvitationRepository, userRepo repository.UserRepository, emailService EmailService, rbac RBACService, cfg *config.Config) OrganizationService {
+	return &organizationService{
+		repo:           repo,
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		emailService:   emailService,
+		rbac:           rbac,
+		cfg:            cfg,
+	}
+}
+
+// CreateOrganization creates the organization with its creator as owner
+func (s *organizationService) CreateOrganization(ownerID uuid.UUID, req CreateOrganizationRequest) (*models.Organization, error) {
+	organization := &models.Organization{Name: req.Name}
+	owner := &models.Membership{UserID: ownerID.String(), Role: models.OrgRoleOwner}
+	if err := s.repo.CreateWithOwner(organization, owner); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Organization created", "organizationId", organization.ID, "owner", ownerID)
+	return organization, nil
+}
+
+func (s *organizationService) ListAll() ([]models.Organization, error) {
+	return s.repo.FindAll()
+}
+
+func (s *organizationService) ListMemberships(userID uuid.UUID) ([]models.Membership, error) {
+	return s.repo.FindMembershipsByUser(userID.String())
+}
+
+func (s *organizationService) GetOrganization(actor OrganizationActor, id string) (*models.Organization, error) {
+	organization, _, err := s.authorize(actor, id)
+	return organization, err
+}
+
+func (s *organizationService) UpdateOrganization(actor OrganizationActor, id string, req UpdateOrganizationRequest) (*models.Organization, error) {
+	organization, _, err := s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	organization.Name = req.Name
+	if err := s.repo.Update(organization); err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (s *organizationService) DeleteOrganization(actor OrganizationActor, id string) error {
+	organization, _, err := s.authorize(actor, id, models.OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(organization); err != nil {
+		return err
+	}
+	s.rbac.ForgetPermissionsVersions()
+	logger.Log.Info("Organization deleted", "organizationId", organization.ID, "by", actor.UserID)
+	return nil
+}
+
+func (s *organizationService) ListMembers(actor OrganizationActor, id string) ([]models.Membership, error) {
+	if _, _, err := s.authorize(actor, id); err != nil {
+		return nil, err
+	}
+	return s.repo.FindMembers(id)
+}
+
+// UpdateMemberRole changes the role of a member. Only owners hand out or take away the owner role,
+// and the last owner keeps it.
+func (s *organizationService) UpdateMemberRole(actor OrganizationActor, id string, userID uuid.UUID, req UpdateMemberRequest) (*models.Membership, error) {
+	_, own, err := s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	membership, err := s.repo.FindMembership(id, userID.String())
+	if err != nil {
+		return nil, ErrNotOrganizationMember
+	}
+	if membership.Role == req.Role {
+		return membership, nil
+	}
+	if (membership.Role == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) && !isOwner(actor, own) {
+		return nil, ErrOrganizationForbidden
+	}
+	if membership.Role == models.OrgRoleOwner {
+		if err := s.keepAnOwner(id); err != nil {
+			return nil, err
+		}
+	}
+
+	membership.Role = req.Role
+	if err := s.repo.UpdateMemberRole(membership); err != nil {
+		return nil, err
+	}
+	s.rbac.ForgetPermissionsVersions(userID)
+	logger.Log.Info("Organization role changed", "organizationId", id, "userId", userID, "role", req.Role, "by", actor.UserID)
+	return membership, nil
+}
+
+// RemoveMember takes a user out of the organization. Members may leave on their own, and only
+// owners remove owners.
+func (s *organizationService) RemoveMember(actor OrganizationActor, id string, userID uuid.UUID) error {
+	var own *models.Membership
+	var err error
+	if userID == actor.UserID {
+		_, own, err = s.authorize(actor, id)
+	} else {
+		_, own, err = s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	}
+	if err != nil {
+		return err
+	}
+	membership, err := s.repo.FindMembership(id, userID.String())
+	if err != nil {
+		return ErrNotOrganizationMember
+	}
+	if membership.Role == models.OrgRoleOwner {
+		if userID != actor.UserID && !isOwner(actor, own) {
+			return ErrOrganizationForbidden
+		}
+		if err := s.keepAnOwner(id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RemoveMember(membership); err != nil {
+		return err
+	}
+	s.rbac.ForgetPermissionsVersions(userID)
+	logger.Log.Info("Organization member removed", "organizationId", id, "userId", userID, "by", actor.UserID)
+	return nil
+}
+
+// CreateInvitation mails an invitation into the organization. Whoever does not have an account yet
+// registers through it, which the registration mode must allow.
+func (s *organizationService) CreateInvitation(actor OrganizationActor, id string, req CreateOrganizationInvitationRequest) (*models.Invitation, error) {
+	organization, own, err := s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if req.Role == models.OrgRoleOwner && !isOwner(actor, own) {
+		return nil, ErrOrganizationForbidden
+	}
+	user, err := s.userRepo.FindByEmail(req.Email)
+	switch {
+	case err == nil:
+		if _, err := s.repo.FindMembership(id, user.ID.String()); err == nil {
+			return nil, errors.New("already a member of the organization")
+		}
+	case !repository.IsNotFound(err):
+		return nil, err
+	default:
+		// The invitation lets them register: in invite-only mode only holders of invitations:manage
+		// may send that, so organizations do not become a way around it, or a way to mail anyone
+		if err := selfRegistrationError(s.cfg.Registration.Mode); err != nil &&
+			(!errors.Is(err, ErrRegistrationInviteOnly) || !actor.ManagesInvitations) {
+			return nil, err
+		}
+	}
+	if err := s.invitationRepo.DeletePendingByEmail(req.Email, &id); err != nil {
+		return nil, err
+	}
+
+	token := rand.Text() + rand.Text()
+	invitation := &models.Invitation{
+		Email:            req.Email,
+		Role:             models.RoleUser,
+		Token:            utils.HashSecret(token),
+		InvitedBy:        actor.UserID.String(),
+		ExpiresAt:        time.Now().Add(time.Duration(s.cfg.Registration.InvitationExpirationHours) * time.Hour),
+		OrganizationID:   &id,
+		OrganizationRole: req.Role,
+	}
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return nil, err
+	}
+	if err := s.emailService.SendOrganizationInvitationEmail(invitation.Email, organization.Name, token); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Organization invitation sent", "invitationId", invitation.ID, "organizationId", id, "role", req.Role, "by", actor.UserID)
+	return invitation, nil
+}
+
+func (s *organizationService) ListInvitations(actor OrganizationActor, id string) ([]models.Invitation, error) {
+	if _, _, err := s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.invitationRepo.FindPending(time.Now(), &id)
+}
+
+func (s *organizationService) RevokeInvitation(actor OrganizationActor, id string, invitationID uint) error {
+	if _, _, err := s.authorize(actor, id, models.OrgRoleOwner, models.OrgRoleAdmin); err != nil {
+		return err
+	}
+	invitation, err := s.invitationRepo.FindByID(invitationID)
+	if err != nil || invitation.AcceptedAt != nil || invitation.OrganizationID == nil || *invitation.OrganizationID != id {
+		return errors.New("invitation not found")
+	}
+	return s.invitationRepo.Delete(invitation)
+}
+
+// AcceptInvitation lets a signed-in user join the organization it was invited to. The invitation
+// must have been sent to the user's email.
+func (s *organizationService) AcceptInvitation(userID uuid.UUID, token string) (*models.Membership, error) {
+	now := time.Now()
+	invitation, err := s.invitationRepo.FindByTokenHash(utils.HashSecret(token))
+	if err != nil || !invitation.IsPending(now) || invitation.OrganizationID == nil {
+		return nil, ErrInvalidInvitation
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, errors.New("the invitation was sent to another email")
+	}
+
+	// Members already in the organization use up the invitation and keep their membership as it is
+	var joined *models.Membership
+	membership, err := s.repo.FindMembership(*invitation.OrganizationID, user.ID.String())
+	if err != nil {
+		joined = &models.Membership{OrganizationID: *invitation.OrganizationID, UserID: user.ID.String(), Role: invitation.OrganizationRole}
+		membership = joined
+	}
+	accepted, err := s.invitationRepo.Accept(invitation.ID, now, nil, joined)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidInvitation
+	}
+	logger.Log.Info("Organization invitation accepted", "invitationId", invitation.ID, "organizationId", membership.OrganizationID, "userId", user.ID)
+	return membership, nil
+}
+
+// authorize loads the organization and the actor's membership, which must have one of roles
+// (any role when none is given). Super-admins pass without being members. Non-members do not
+// learn that the organization exists.
+func (s *organizationService) authorize(actor OrganizationActor, id string, roles ...string) (*models.Organization, *models.Membership, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, ErrOrganizationNotFound
+	}
+	organization, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, nil, ErrOrganizationNotFound
+	}
+	membership, err := s.repo.FindMembership(id, actor.UserID.String())
+	if actor.SuperAdmin {
+		return organization, membership, nil
+	}
+	if err != nil {
+		return nil, nil, ErrOrganizationNotFound
+	}
+	if len(roles) > 0 && !slices.Contains(roles, membership.Role) {
+		return nil, nil, ErrOrganizationForbidden
+	}
+	return organization, membership, nil
+}
+
+// keepAnOwner fails when the organization is down to a single owner
+func (s *organizationService) keepAnOwner(id string) error {
+	owners, err := s.repo.CountOwners(id)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func isOwner(actor OrganizationActor, membership *models.Membership) bool {
+	return actor.SuperAdmin || (membership != nil && membership.Role == models.OrgRoleOwner)
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// How long the client has between requesting options and sending the authenticator response
+const passkeyCeremonyTimeout = 5 * time.Minute
+
+type passkeyService struct {
+	webauthn     *webauthn.WebAuthn
+	repo         repository.WebAuthnRepository
+	userRepo     repository.UserRepository
+	tokenService *TokenService
+}
+
+func NewPasskeyService(repo repository.WebAuthnRepository, userRepo repository.UserRepository, tokenService *TokenService, cfg *config.Config) (PasskeyService, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTimeout, TimeoutUVD: passkeyCeremonyTimeout}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkeyService{
+		webauthn:     wa,
+		repo:         repo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+	}, nil
+}
+
+func (s *passkeyService) BeginRegistration(userID uuid.UUID) (*protocol.CredentialCreation, string, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Stop the authenticator from registering a second passkey for the same account
+	exclusions := webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()
+	creation, session, err := s.webauthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(userID.String(), models.WebAuthnSessionRegistration, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, sessionID, nil
+}
+
+func (s *passkeyService) FinishRegistration(userID uuid.UUID, sessionID, name string, credentialJSON []byte) (*models.WebAuthnCredential, error) {
+	session, err := s.consumeSession(sessionID, models.WebAuthnSessionRegistration)
+	if err != nil || !bytes.Equal(session.UserID, userID[:]) {
+		return nil, errors.New("passkey registration session not found or expired")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credentialJSON)
+	if err != nil {
+		return nil, errors.New("invalid passkey registration response")
+	}
+
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		logger.Log.Debug("Passkey registration failed", "userId", userID, "error", err)
+		return nil, errors.New("passkey registration failed")
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	record := &models.WebAuthnCredential{
+		UserID:          userID.String(),
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		SignCount:       credential.Authenticator.SignCount,
+	}
+	if err := s.repo.CreateCredential(record); err != nil {
+		return nil, errors.New("passkey already registered")
+	}
+	return record, nil
+}
+
+func (s *passkeyService) BeginLogin(email string) (*protocol.CredentialAssertion, string, error) {
+	var user *passkeyUser
+	if email != "" {
+		if found, err := s.userRepo.FindByEmail(email); err == nil {
+			user, _ = s.loadUser(found.ID)
+		}
+	}
+
+	// Unknown emails and accounts without passkeys fall back to a discoverable login. Accounts
+	// with passkeys get them listed in allowCredentials, which does tell that the email has
+	// passkeys; clients that must not reveal this should leave the email out.
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var err error
+	sessionUserID := ""
+	if user != nil && len(user.credentials) > 0 {
+		assertion, session, err = s.webauthn.BeginLogin(user)
+		sessionUserID = user.user.ID.String()
+	} else {
+		assertion, session, err = s.webauthn.BeginDiscoverableLogin()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(sessionUserID, models.WebAuthnSessionLogin, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, sessionID, nil
+}
+
+func (s *passkeyService) FinishLogin(sessionID string, credentialJSON []byte) (*models.User, map[string]interface{}, error) {
+	session, err := s.consumeSession(sessionID, models.WebAuthnSessionLogin)
+	if err != nil {
+		return nil, nil, errors.New("passkey login session not found or expired")
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credentialJSON)
+	if err != nil {
+		return nil, nil, errors.New("invalid passkey login response")
+	}
+
+	var user *passkeyUser
+	var credential *webauthn.Credential
+	if len(session.UserID) > 0 {
+		userID, _ := uuid.FromBytes(session.UserID)
+		if user, err = s.loadUser(userID); err == nil {
+			credential, err = s.webauthn.ValidateLogin(user, *session, parsed)
+		}
+	} else {
+		var found webauthn.User
+		found, credential, err = s.webauthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			userID, err := uuid.FromBytes(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			return s.loadUser(userID)
+		}, *session, parsed)
+		if err == nil {
+			user = found.(*passkeyUser)
+		}
+	}
+	if err != nil {
+		logger.Log.Debug("Passkey login failed", "error", err)
+		return nil, nil, errors.New("passkey login failed")
+	}
+
+	record, err := s.repo.FindCredentialByCredentialID(base64.RawURLEncoding.EncodeToString(credential.ID))
+	if err != nil || record.UserID != user.user.ID.String() {
+		return nil, nil, errors.New("passkey login failed")
+	}
+
+	now := time.Now()
+	record.LastUsedAt = &now
+	record.BackupState = credential.Flags.BackupState
+	if credential.Authenticator.CloneWarning {
+		// The signature counter went backwards: two copies of this key may exist
+		record.CloneWarning = true
+		s.repo.UpdateCredential(record)
+		logger.Log.Warn("Passkey sign count regressed, possible cloned authenticator",
+			"userId", record.UserID,
+			"credentialId", record.ID,
+			"storedCount", record.SignCount,
+			"receivedCount", credential.Authenticator.SignCount,
+		)
+		return nil, nil, errors.New("passkey login failed")
+	}
+	record.SignCount = credential.Authenticator.SignCount
+	if err := s.repo.UpdateCredential(record); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.tokenService.GenerateAuthTokens(user.user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user.user, tokens, nil
+}
+
+func (s *passkeyService) ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.repo.FindCredentialsByUserID(userID.String())
+}
+
+func (s *passkeyService) RenameCredential(userID uuid.UUID, id uint, name string) (*models.WebAuthnCredential, error) {
+	credential, err := s.repo.FindCredential(userID.String(), id)
+	if err != nil {
+		return nil, errors.New("passkey not found")
+	}
+	credential.Name = name
+	if err := s.repo.UpdateCredential(credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (s *passkeyService) DeleteCredential(userID uuid.UUID, id uint) error {
+	credential, err := s.repo.FindCredential(userID.String(), id)
+	if err != nil {
+		return errors.New("passkey not found")
+	}
+	return s.repo.DeleteCredential(credential)
+}
+
+func (s *passkeyService) saveSession(userID, sessionType string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	// Abandoned ceremonies are cleaned up opportunistically
+	s.repo.DeleteExpiredSessions(time.Now())
+
+	record := &models.WebAuthnSession{
+		ID:      uuid.NewString(),
+		UserID:  userID,
+		Type:    sessionType,
+		Data:    string(data),
+		Expires: time.Now().Add(passkeyCeremonyTimeout),
+	}
+	if err := s.repo.CreateSession(record); err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+func (s *passkeyService) consumeSession(id, sessionType string) (*webauthn.SessionData, error) {
+	record, err := s.repo.ConsumeSession(id, sessionType)
+	if err != nil {
+		return nil, err
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(record.Data), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *passkeyService) loadUser(id uuid.UUID) (*passkeyUser, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	credentials, err := s.repo.FindCredentialsByUserID(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// passkeyUser adapts a user and its stored passkeys to webauthn.User
+type passkeyUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+// WebAuthnID is the user handle; the raw UUID bytes keep it opaque and stable
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, record := range u.credentials {
+		id, err := base64.RawURLEncoding.DecodeString(record.CredentialID)
+		if err != nil {
+			continue
+		}
+		var transports []protocol.AuthenticatorTransport
+		if record.Transports != "" {
+			for _, transport := range strings.Split(record.Transports, ",") {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       record.PublicKey,
+			AttestationType: record.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: record.BackupEligible,
+				BackupState:    record.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       record.AAGUID,
+				SignCount:    record.SignCount,
+				CloneWarning: record.CloneWarning,
+			},
+		})
+	}
+	return credentials
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/glebarez/sqlite"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softwareAuthenticator is a minimal FIDO2 authenticator with a single P-256 passkey,
+// producing the same JSON a browser hands to the API.
+type softwareAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softwareAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+// create answers navigator.credentials.create with a "none" attestation
+func (a *softwareAuthenticator) create(options *protocol.CredentialCreation) []byte {
+	a.userHandle = options.Response.User.ID.(protocol.URLEncodedBase64)
+	point, _ := a.key.PublicKey.ECDH()
+	raw := point.Bytes() // 0x04 || X || Y
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: raw[1:33],
+		YCoord: raw[33:],
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	authData := a.authenticatorData(protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]interface{}{
+		"clientDataJSON":    encode(a.clientData("webauthn.create", options.Response.Challenge)),
+		"attestationObject": encode(attestation),
+	})
+}
+
+// get answers navigator.credentials.get, signing with the next counter value
+func (a *softwareAuthenticator) get(options *protocol.CredentialAssertion) []byte {
+	a.signCount++
+	clientData := a.clientData("webauthn.get", options.Response.Challenge)
+	authData := a.authenticatorData(protocol.FlagUserPresent | protocol.FlagUserVerified)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credential(map[string]interface{}{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softwareAuthenticator) authenticatorData(flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], byte(flags))
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softwareAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": encode(challenge),
+		"origin":    testOrigin,
+	})
+	return data
+}
+
+func (a *softwareAuthenticator) credential(response map[string]interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+type passkeyFixture struct {
+	service PasskeyService
+	repo    repository.WebAuthnRepository
+	user    *models.User
+}
+
+func newPasskeyFixture(t *testing.T) *passkeyFixture {
+	logger.InitLogger("test")
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Token{}, &models.SigningKey{}, &models.WebAuthnCredential{}, &models.WebAuthnSession{}, &models.Permission{}, &models.Role{}, &models.Organization{}, &models.Membership{}, &models.Group{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		JWT:      config.JWTConfig{Secret: "test-secret", Algorithm: "HS256", AccessExpirationMinutes: 30, RefreshExpirationDays: 30},
+		WebAuthn: config.WebAuthnConfig{RPID: testRPID, RPDisplayName: "Test", RPOrigins: []string{testOrigin}},
+	}
+	userRepo := repository.NewUserRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	if err := NewRBACService(roleRepo, userRepo).EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeyService(repository.NewSigningKeyRepository(db), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokenService(repository.NewTokenRepository(db), roleRepo, repository.NewGroupRepository(db), repository.NewOrganizationRepository(db), keys, cfg)
+
+	repo := repository.NewWebAuthnRepository(db)
+	service, err := NewPasskeyService(repo, userRepo, tokens, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := utils.HashPassword("Correct-horse-battery-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Name: "Passkey User", Email: "passkey@example.com", Password: hash}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return &passkeyFixture{service: service, repo: repo, user: user}
+}
+
+// register runs the registration ceremony for a fresh authenticator
+func (f *passkeyFixture) register(t *testing.T) (*softwareAuthenticator, *models.WebAuthnCredential) {
+	authenticator := newSoftwareAuthenticator(t)
+	options, sessionID, err := f.service.BeginRegistration(f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := f.service.FinishRegistration(f.user.ID, sessionID, "Laptop", authenticator.create(options))
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return authenticator, credential
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator, credential := f.register(t)
+	if credential.Name != "Laptop" || credential.CredentialID != encode(authenticator.credentialID) {
+		t.Fatalf("unexpected credential: %+v", credential)
+	}
+
+	options, sessionID, err := f.service.BeginLogin(f.user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Response.AllowedCredentials) != 1 {
+		t.Fatalf("expected the registered passkey to be allowed, got %d", len(options.Response.AllowedCredentials))
+	}
+	user, tokens, err := f.service.FinishLogin(sessionID, authenticator.get(options))
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if user.ID != f.user.ID || tokens["access"] == nil || tokens["refresh"] == nil {
+		t.Fatalf("expected a token pair for %s, got %v", f.user.ID, tokens)
+	}
+
+	stored, err := f.repo.FindCredential(f.user.ID.String(), credential.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SignCount != authenticator.signCount || stored.LastUsedAt == nil {
+		t.Fatalf("sign count %d (want %d), last used %v", stored.SignCount, authenticator.signCount, stored.LastUsedAt)
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator, _ := f.register(t)
+
+	// Without an email, and for unknown emails, the options name no credential
+	for _, email := range []string{"", "nobody@example.com"} {
+		options, _, err := f.service.BeginLogin(email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(options.Response.AllowedCredentials) != 0 {
+			t.Fatalf("email %q: expected discoverable options, got %d credentials", email, len(options.Response.AllowedCredentials))
+		}
+	}
+
+	options, sessionID, err := f.service.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := f.service.FinishLogin(sessionID, authenticator.get(options))
+	if err != nil {
+		t.Fatalf("discoverable login failed: %v", err)
+	}
+	if user.ID != f.user.ID {
+		t.Fatalf("logged in as %s, want %s", user.ID, f.user.ID)
+	}
+}
+
+func TestPasskeyLoginSessionIsSingleUse(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator, _ := f.register(t)
+
+	options, sessionID, err := f.service.BeginLogin(f.user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := authenticator.get(options)
+	if _, _, err := f.service.FinishLogin(sessionID, assertion); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if _, _, err := f.service.FinishLogin(sessionID, assertion); err == nil {
+		t.Fatal("replayed assertion was accepted")
+	}
+}
+
+func TestPasskeyLoginRejectsForeignKey(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator, _ := f.register(t)
+
+	options, sessionID, err := f.service.BeginLogin(f.user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same credential ID, different private key
+	authenticator.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, _, err := f.service.FinishLogin(sessionID, authenticator.get(options)); err == nil {
+		t.Fatal("assertion signed by another key was accepted")
+	}
+}
+
+func TestPasskeyLoginFlagsClonedAuthenticator(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator, credential := f.register(t)
+
+	for i := 0; i < 2; i++ {
+		options, sessionID, err := f.service.BeginLogin(f.user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			// A copy of the key that has signed fewer times than the original
+			authenticator.signCount = 0
+		}
+		_, _, err = f.service.FinishLogin(sessionID, authenticator.get(options))
+		if i == 0 && err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if i == 1 && err == nil {
+			t.Fatal("login with a regressed sign count was accepted")
+		}
+	}
+
+	stored, err := f.repo.FindCredential(f.user.ID.String(), credential.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.CloneWarning {
+		t.Fatal("credential was not flagged as cloned")
+	}
+}
+
+func TestPasskeyRegistrationRejectsWrongOrigin(t *testing.T) {
+	f := newPasskeyFixture(t)
+	authenticator := newSoftwareAuthenticator(t)
+
+	options, sessionID, err := f.service.BeginRegistration(f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := authenticator.create(options)
+	var credential map[string]interface{}
+	json.Unmarshal(response, &credential)
+	clientData, _ := json.Marshal(map[string]string{"type": "webauthn.create", "challenge": encode(options.Response.Challenge), "origin": "https://evil.example"})
+	credential["response"].(map[string]interface{})["clientDataJSON"] = encode(clientData)
+	response, _ = json.Marshal(credential)
+
+	if _, err := f.service.FinishRegistration(f.user.ID, sessionID, "", response); err == nil {
+		t.Fatal("registration from a foreign origin was accepted")
+	}
+
+	// The session belongs to this user only
+	_, sessionID, _ = f.service.BeginRegistration(f.user.ID)
+	if _, err := f.service.FinishRegistration(uuid.New(), sessionID, "", authenticator.create(options)); err == nil {
+		t.Fatal("registration session of another user was accepted")
+	}
+}
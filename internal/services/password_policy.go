@@ -0,0 +1,157 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+// Codes of the password rules, reported in PasswordPolicyError
+const (
+	PasswordTooShort     = "min_length"
+	PasswordTooLong      = "max_length"
+	PasswordNoUpper      = "uppercase"
+	PasswordNoLower      = "lowercase"
+	PasswordNoDigit      = "digit"
+	PasswordNoSymbol     = "symbol"
+	PasswordPersonalInfo = "personal_info"
+	PasswordBreached     = "breached"
+	PasswordReused       = "reused"
+)
+
+// PasswordPolicyError lists every rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, ", ")
+}
+
+// PasswordPolicy enforces config.PasswordPolicy and the password history
+type PasswordPolicy struct {
+	cfg      *config.PasswordPolicy
+	history  repository.PasswordHistoryRepository
+	breached *breachedRanges // nil when the breached password check is off
+}
+
+func NewPasswordPolicy(history repository.PasswordHistoryRepository, cfg *config.Config) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{cfg: &cfg.Password, history: history}
+	if cfg.Password.BreachedRangesDir != "" {
+		breached, err := newBreachedRanges(cfg.Password.BreachedRangesDir)
+		if err != nil {
+			return nil, err
+		}
+		p.breached = breached
+		logger.Log.Info("Checking passwords against breached password ranges", "dir", cfg.Password.BreachedRangesDir)
+	}
+	return p, nil
+}
+
+// Validate checks a new password against the rules. email and name are those of the account.
+func (p *PasswordPolicy) Validate(password, email, name string) error {
+	var violations []string
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		violations = append(violations, PasswordTooShort)
+	}
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		violations = append(violations, PasswordTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, PasswordNoUpper)
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, PasswordNoLower)
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, PasswordNoDigit)
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, PasswordNoSymbol)
+	}
+
+	if p.cfg.DisallowPersonalInfo && containsPersonalInfo(password, email, name) {
+		violations = append(violations, PasswordPersonalInfo)
+	}
+	if p.isBreached(password) {
+		violations = append(violations, PasswordBreached)
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the password contains the email's local part or a word
+// of the name. Parts shorter than three characters are ignored.
+func containsPersonalInfo(password, email, name string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, part := range append(strings.Fields(name), local) {
+		if part = strings.ToLower(part); utf8.RuneCountInString(part) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PasswordPolicy) isBreached(password string) bool {
+	return p.breached != nil && p.breached.contains(password)
+}
+
+// CheckReuse rejects the user's current password and the replaced ones still in the history
+func (p *PasswordPolicy) CheckReuse(user *models.User, password string) error {
+	if p.cfg.HistorySize <= 0 {
+		return nil
+	}
+	if utils.CheckPassword(password, user.Password) {
+		return &PasswordPolicyError{Violations: []string{PasswordReused}}
+	}
+
+	entries, err := p.history.FindRecent(user.ID.String(), p.cfg.HistorySize-1)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if utils.CheckPassword(password, entry.Hash) {
+			return &PasswordPolicyError{Violations: []string{PasswordReused}}
+		}
+	}
+	return nil
+}
+
+// Remember records the hash of a password that was just replaced and forgets older ones
+func (p *PasswordPolicy) Remember(userID, previousHash string) {
+	if p.cfg.HistorySize <= 1 || previousHash == "" {
+		return
+	}
+
+	err := p.history.Create(&models.PasswordHistory{UserID: userID, Hash: previousHash})
+	if err == nil {
+		err = p.history.Prune(userID, p.cfg.HistorySize-1)
+	}
+	if err != nil {
+		logger.Log.Error("Failed to update password history", "userId", userID, "error", err)
+	}
+}
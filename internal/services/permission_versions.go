@@ -0,0 +1,64 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/repository"
+)
+
+// permissionVersions caches the permissions version of each user, so the Auth middleware can tell
+// stale access tokens apart without hitting the database on every request.
+type permissionVersions struct {
+	repo repository.RoleRepository
+
+	mu       sync.RWMutex
+	versions map[string]int // userID -> permissions version
+}
+
+func newPermissionVersions(repo repository.RoleRepository) *permissionVersions {
+	return &permissionVersions{repo: repo, versions: make(map[string]int)}
+}
+
+// Get returns the cached version of the user, loading it on first use
+func (v *permissionVersions) Get(userID string) (int, error) {
+	v.mu.RLock()
+	version, ok := v.versions[userID]
+	v.mu.RUnlock()
+	if ok {
+		return version, nil
+	}
+
+	version, err := v.repo.FindPermissionsVersion(userID)
+	if err != nil {
+		return 0, err
+	}
+	v.mu.Lock()
+	v.versions[userID] = version
+	v.mu.Unlock()
+	return version, nil
+}
+
+// Forget drops the cached versions of the users, or all of them when none is given, after they were bumped
+func (v *permissionVersions) Forget(userIDs ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(userIDs) == 0 {
+		v.versions = make(map[string]int)
+		return
+	}
+	for _, userID := range userIDs {
+		delete(v.versions, userID)
+	}
+}
+
+// StartSync periodically empties the cache so versions bumped by other instances are picked up.
+func (v *permissionVersions) StartSync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			v.Forget()
+		}
+	}()
+}
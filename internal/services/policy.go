@@ -0,0 +1,285 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// defaultPolicy applies when no policy file is configured: permissions from roles allow their action,
+// users reach their own account, profile edits limited to the name, and owners and admins of the
+// active organization list and read its members. Policy files include it with includeDefault.
+//
+//go:embed policy_default.json
+var defaultPolicy []byte
+
+// PolicyDocument is the declarative policy format. A request is allowed when a rule allows it and
+// no rule denies it.
+type PolicyDocument struct {
+	IncludeDefault bool         `json:"includeDefault,omitempty"` // The built-in rules come before Rules
+	Rules          []PolicyRule `json:"rules"`
+}
+
+type PolicyRule struct {
+	Name       string            `json:"name"`
+	Effect     string            `json:"effect"`     // allow or deny
+	Actions    []string          `json:"actions"`    // Patterns such as users:read, users:* or *
+	Conditions []PolicyCondition `json:"conditions"` // All must hold
+}
+
+// PolicyCondition compares the attribute at a dotted path (subject.roles, resource.id, request.fields,
+// action) with a literal value, or with another attribute named by valueFrom.
+type PolicyCondition struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"` // eq, ne, in, notIn, contains, notContains, subsetOf or exists
+	Value     interface{} `json:"value,omitempty"`
+	ValueFrom string      `json:"valueFrom,omitempty"`
+}
+
+// ParsePolicyDocument reads and checks a policy document
+func ParsePolicyDocument(data []byte) (*PolicyDocument, error) {
+	var document PolicyDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	for i, rule := range document.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i)
+		}
+		if rule.Effect != PolicyEffectAllow && rule.Effect != PolicyEffectDeny {
+			return nil, fmt.Errorf("rule %q: unsupported effect %q", rule.Name, rule.Effect)
+		}
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %q has no actions", rule.Name)
+		}
+		for _, condition := range rule.Conditions {
+			if condition.Attribute == "" {
+				return nil, fmt.Errorf("rule %q: condition without attribute", rule.Name)
+			}
+			if _, ok := policyOperators[condition.Operator]; !ok {
+				return nil, fmt.Errorf("rule %q: unsupported operator %q", rule.Name, condition.Operator)
+			}
+		}
+	}
+	if document.IncludeDefault {
+		builtIn, err := ParsePolicyDocument(defaultPolicy)
+		if err != nil {
+			return nil, err
+		}
+		document.Rules = append(builtIn.Rules, document.Rules...)
+	}
+	return &document, nil
+}
+
+// Evaluate applies the rules in order. Deny rules win over allow rules, and nothing is allowed by default.
+func (d *PolicyDocument) Evaluate(req AuthzRequest) AuthzDecision {
+	attributes := req.attributes()
+	decision := AuthzDecision{Reason: "no rule allows " + req.Action}
+	for _, rule := range d.Rules {
+		if !rule.matches(req.Action, attributes) {
+			continue
+		}
+		if rule.Effect == PolicyEffectDeny {
+			return AuthzDecision{Allowed: false, Rule: rule.Name, Reason: "denied by rule " + rule.Name}
+		}
+		if !decision.Allowed {
+			decision = AuthzDecision{Allowed: true, Rule: rule.Name, Reason: "allowed by rule " + rule.Name}
+		}
+	}
+	return decision
+}
+
+func (r PolicyRule) matches(action string, attributes map[string]interface{}) bool {
+	if !slices.ContainsFunc(r.Actions, func(pattern string) bool {
+		matched, _ := path.Match(pattern, action)
+		return matched
+	}) {
+		return false
+	}
+	for _, condition := range r.Conditions {
+		if !condition.holds(attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c PolicyCondition) holds(attributes map[string]interface{}) bool {
+	actual, found := lookupAttribute(attributes, c.Attribute)
+	expected := c.Value
+	if c.ValueFrom != "" {
+		var ok bool
+		if expected, ok = lookupAttribute(attributes, c.ValueFrom); !ok {
+			return false
+		}
+	}
+	if c.Operator == "exists" {
+		want, _ := expected.(bool)
+		return found == (want || expected == nil)
+	}
+	if !found {
+		// A missing attribute satisfies only the negative operators
+		return c.Operator == "ne" || c.Operator == "notIn" || c.Operator == "notContains"
+	}
+	return policyOperators[c.Operator](actual, expected)
+}
+
+var policyOperators = map[string]func(actual, expected interface{}) bool{
+	"eq":          reflect.DeepEqual,
+	"ne":          func(actual, expected interface{}) bool { return !reflect.DeepEqual(actual, expected) },
+	"in":          func(actual, expected interface{}) bool { return containsValue(expected, actual) },
+	"notIn":       func(actual, expected interface{}) bool { return !containsValue(expected, actual) },
+	"contains":    containsValue,
+	"notContains": func(actual, expected interface{}) bool { return !containsValue(actual, expected) },
+	"subsetOf": func(actual, expected interface{}) bool {
+		values, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if !containsValue(expected, value) {
+				return false
+			}
+		}
+		return true
+	},
+	"exists": nil, // handled in holds, it looks at the presence of the attribute
+}
+
+// containsValue reports whether list is a list holding value
+func containsValue(list, value interface{}) bool {
+	values, _ := list.([]interface{})
+	return slices.ContainsFunc(values, func(item interface{}) bool { return reflect.DeepEqual(item, value) })
+}
+
+// lookupAttribute follows a dotted path through the attributes
+func lookupAttribute(attributes map[string]interface{}, name string) (interface{}, bool) {
+	var current interface{} = attributes
+	for _, key := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok || current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// attributes turns the request into plain JSON values, the way the rules were decoded
+func (req AuthzRequest) attributes() map[string]interface{} {
+	attributes := map[string]interface{}{}
+	data, err := json.Marshal(req)
+	if err == nil {
+		_ = json.Unmarshal(data, &attributes)
+	}
+	return attributes
+}
+
+// policyService evaluates the policy document of the configured file, or the built-in one,
+// and picks up changes to the file.
+type policyService struct {
+	cfg *config.Config
+
+	mu       sync.RWMutex
+	policy   Policy
+	modified time.Time
+}
+
+func NewPolicyService(cfg *config.Config) (PolicyService, error) {
+	s := &policyService{cfg: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *policyService) Evaluate(req AuthzRequest) AuthzDecision {
+	s.mu.RLock()
+	policy := s.policy
+	s.mu.RUnlock()
+	return policy.Evaluate(req)
+}
+
+// Reload reads the policy file again. An invalid file is refused and the current policy stays.
+func (s *policyService) Reload() error {
+	if s.cfg.Policy.File == "" {
+		document, err := ParsePolicyDocument(defaultPolicy)
+		if err != nil {
+			return err
+		}
+		s.setPolicy(document, time.Time{})
+		return nil
+	}
+
+	info, err := os.Stat(s.cfg.Policy.File)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.cfg.Policy.File)
+	if err != nil {
+		return err
+	}
+	document, err := ParsePolicyDocument(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.cfg.Policy.File, err)
+	}
+	s.setPolicy(document, info.ModTime())
+	logger.Log.Info("Access policy loaded", "file", s.cfg.Policy.File, "rules", len(document.Rules))
+	return nil
+}
+
+// StartWatch reloads the policy file whenever its modification time changes
+func (s *policyService) StartWatch(interval time.Duration) {
+	if s.cfg.Policy.File == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			info, err := os.Stat(s.cfg.Policy.File)
+			if err != nil {
+				logger.Log.Error("Failed to check the access policy file", "error", err)
+				continue
+			}
+			s.mu.RLock()
+			changed := !info.ModTime().Equal(s.modified)
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				logger.Log.Error("Failed to reload the access policy, keeping the current one", "error", err)
+				// Do not retry until the file changes again
+				s.mu.Lock()
+				s.modified = info.ModTime()
+				s.mu.Unlock()
+			}
+		}
+	}()
+}
+
+func (s *policyService) setPolicy(policy Policy, modified time.Time) {
+	s.mu.Lock()
+	s.policy = policy
+	s.modified = modified
+	s.mu.Unlock()
+}
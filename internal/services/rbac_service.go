@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	permissionNamePattern = regexp.MustCompile(`^[a-z0-9-]+:[a-z0-9-]+$`)
+	roleNamePattern       = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
+)
+
+type rbacService struct {
+	repo     repository.RoleRepository
+	userRepo repository.UserRepository
+	versions *permissionVersions
+}
+
+func NewRBACService(repo repository.RoleRepository, userRepo repository.UserRepository) RBACService {
+	return &rbacService{repo: repo, userRepo: userRepo, versions: newPermissionVersions(repo)}
+}
+
+// EnsureDefaults creates the built-in permissions and roles, grants every built-in permission
+// to the admin role, and gives users without roles the one in their role column
+func (s *rbacService) EnsureDefaults() error {
+	permissions := make([]models.Permission, 0, len(models.DefaultPermissions))
+	for _, permission := range models.DefaultPermissions {
+		permission.BuiltIn = true
+		if err := s.repo.EnsurePermission(&permission); err != nil {
+			return err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	admin := &models.Role{Name: models.RoleAdmin, Description: "Full access", BuiltIn: true}
+	if err := s.repo.EnsureRole(admin); err != nil {
+		return err
+	}
+	// Only grant what is missing, adding permissions makes the tokens of every admin stale
+	current, err := s.repo.FindRoleByID(admin.ID)
+	if err != nil {
+		return err
+	}
+	missing := slices.DeleteFunc(permissions, func(permission models.Permission) bool {
+		return slices.ContainsFunc(current.Permissions, func(granted models.Permission) bool { return granted.ID == permission.ID })
+	})
+	if err := s.repo.AddRolePermissions(admin, missing); err != nil {
+		return err
+	}
+	if err := s.repo.EnsureRole(&models.Role{Name: models.RoleUser, Description: "Default role of new users", BuiltIn: true}); err != nil {
+		return err
+	}
+
+	assigned, err := s.repo.AssignMissingUserRoles()
+	if err != nil {
+		return err
+	}
+	if assigned > 0 {
+		logger.Log.Info("Assigned roles to existing users", "count", assigned)
+	}
+	return nil
+}
+
+func (s *rbacService) GetPermissions() ([]models.Permission, error) {
+	return s.repo.FindPermissions()
+}
+
+func (s *rbacService) CreatePermission(req CreatePermissionRequest) (*models.Permission, error) {
+	if !permissionNamePattern.MatchString(req.Name) {
+		return nil, errors.New("permission name must look like resource:action")
+	}
+	if existing, _ := s.repo.FindPermissionsByName([]string{req.Name}); len(existing) > 0 {
+		return nil, errors.New("permission already exists")
+	}
+
+	permission := &models.Permission{Name: req.Name, Description: req.Description}
+	if err := s.repo.CreatePermission(permission); err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+func (s *rbacService) UpdatePermission(id uint, req UpdatePermissionRequest) (*models.Permission, error) {
+	permission, err := s.repo.FindPermissionByID(id)
+	if err != nil {
+		return nil, ErrPermissionNotFound
+	}
+	permission.Description = req.Description
+	if err := s.repo.UpdatePermission(permission); err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+// DeletePermission removes a permission and takes it from every role
+func (s *rbacService) DeletePermission(id uint) error {
+	permission, err := s.repo.FindPermissionByID(id)
+	if err != nil {
+		return ErrPermissionNotFound
+	}
+	if permission.BuiltIn {
+		return errors.New("built-in permissions cannot be deleted")
+	}
+	if err := s.repo.DeletePermission(permission); err != nil {
+		return err
+	}
+	s.versions.Forget()
+	return nil
+}
+
+func (s *rbacService) GetRoles() ([]models.Role, error) {
+	return s.repo.FindRoles()
+}
+
+func (s *rbacService) GetRole(id uint) (*models.Role, error) {
+	role, err := s.repo.FindRoleByID(id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (s *rbacService) CreateRole(req CreateRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, errors.New("role name may only contain lowercase letters, digits and dashes")
+	}
+	if existing, _ := s.repo.FindRolesByName([]string{req.Name}); len(existing) > 0 {
+		return nil, errors.New("role already exists")
+	}
+	permissions, err := findPermissions(s.repo, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: req.Name, Description: req.Description}
+	if err := s.repo.CreateRole(role); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRolePermissions(role, permissions); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Role created", "role", role.Name, "permissions", req.Permissions)
+	return s.repo.FindRoleByID(role.ID)
+}
+
+// UpdateRole changes the description and, when given, replaces the permissions. Names are fixed
+// because users keep their main role by name.
+func (s *rbacService) UpdateRole(id uint, req UpdateRoleRequest) (*models.Role, error) {
+	role, err := s.repo.FindRoleByID(id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	if req.Description != nil {
+		role.Description = *req.Description
+		if err := s.repo.UpdateRole(role); err != nil {
+			return nil, err
+		}
+	}
+	if req.Permissions != nil {
+		permissions, err := findPermissions(s.repo, req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.ReplaceRolePermissions(role, permissions); err != nil {
+			return nil, err
+		}
+		s.versions.Forget()
+		logger.Log.Info("Role permissions changed", "role", role.Name, "permissions", req.Permissions)
+	}
+	return s.repo.FindRoleByID(role.ID)
+}
+
+// DeleteRole removes a role that no user has anymore
+func (s *rbacService) DeleteRole(id uint) error {
+	role, err := s.repo.FindRoleByID(id)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+	if role.BuiltIn {
+		return errors.New("built-in roles cannot be deleted")
+	}
+	users, err := s.repo.CountUsersWithRole(role.ID)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return errors.New("role is still assigned to users")
+	}
+	return s.repo.DeleteRole(role)
+}
+
+func (s *rbacService) GetUserRoles(userID uuid.UUID) ([]models.Role, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+	return s.repo.FindUserRoles(userID.String())
+}
+
+// SetUserRoles replaces the roles of a user. The main role stays unless it was taken away,
+// then the first given role becomes the main one.
+func (s *rbacService) SetUserRoles(userID uuid.UUID, req SetUserRolesRequest) ([]models.Role, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	roles, err := s.repo.FindRolesByName(req.Roles)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range req.Roles {
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.Name == name }) {
+			return nil, errors.New("unknown role " + name)
+		}
+	}
+
+	if !slices.Contains(req.Roles, user.Role) {
+		user.Role = req.Roles[0]
+	}
+	if err := s.repo.ReplaceUserRoles(user, roles); err != nil {
+		return nil, err
+	}
+	s.versions.Forget(user.ID.String())
+	logger.Log.Info("User roles changed", "userId", user.ID, "roles", req.Roles)
+	return s.repo.FindUserRoles(user.ID.String())
+}
+
+// PermissionsVersion returns the cached version of the user, or ErrUserNotFound once the user is deleted
+func (s *rbacService) PermissionsVersion(userID uuid.UUID) (int, error) {
+	version, err := s.versions.Get(userID.String())
+	if repository.IsNotFound(err) {
+		return 0, ErrUserNotFound
+	}
+	return version, err
+}
+
+// ForgetPermissionsVersions drops the cached versions of the users, of everybody when none is given,
+// after another service bumped them.
+func (s *rbacService) ForgetPermissionsVersions(userIDs ...uuid.UUID) {
+	if len(userIDs) == 0 {
+		s.versions.Forget()
+		return
+	}
+	ids := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ids = append(ids, userID.String())
+	}
+	s.versions.Forget(ids...)
+}
+
+// StartSync periodically drops the cached permissions versions so changes made by other instances are picked up.
+func (s *rbacService) StartSync(interval time.Duration) {
+	s.versions.StartSync(interval)
+}
+
+// findPermissions loads the named permissions, failing on the first unknown one
+func findPermissions(repo repository.RoleRepository, names []string) ([]models.Permission, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	permissions, err := repo.FindPermissionsByName(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(permissions, func(permission models.Permission) bool { return permission.Name == name }) {
+			return nil, errors.New("unknown permission " + name)
+		}
+	}
+	return permissions, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitedError is returned when a request is refused because it was made too often
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return "too many requests, try again later"
+}
+
+// requestLimiter allows max requests per key within a sliding window. It is kept in memory, per instance.
+type requestLimiter struct {
+	max    int
+	window time.Duration
+
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+func newRequestLimiter(max int, window time.Duration) *requestLimiter {
+	return &requestLimiter{max: max, window: window, hits: make(map[string][]time.Time)}
+}
+
+// allow records a request for key, or refuses it with the time until the oldest one leaves the window
+func (l *requestLimiter) allow(key string, now time.Time) *RateLimitedError {
+	if l.max <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.recent(key, now)
+	if len(recent) >= l.max {
+		l.hits[key] = recent
+		return &RateLimitedError{RetryAfter: recent[0].Add(l.window).Sub(now)}
+	}
+	if len(l.hits) >= 10000 {
+		l.pruneLocked(now)
+	}
+	l.hits[key] = append(recent, now)
+	return nil
+}
+
+// recent returns the requests of key still within the window. l.mu must be held.
+func (l *requestLimiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	start := now.Add(-l.window)
+	for len(hits) > 0 && !hits[0].After(start) {
+		hits = hits[1:]
+	}
+	return hits
+}
+
+// pruneLocked forgets keys without requests in the window. l.mu must be held.
+func (l *requestLimiter) pruneLocked(now time.Time) {
+	for key := range l.hits {
+		if len(l.recent(key, now)) == 0 {
+			delete(l.hits, key)
+		}
+	}
+}
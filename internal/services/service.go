@@ -1,49 +1,429 @@
 package services
 
 import (
+	"time"
+
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/pkg/utils"
 
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/google/uuid"
 )
 
 // AuthService defines the interface for authentication logic
 type AuthService interface {
-	Login(email, password string) (*models.User, map[string]interface{}, error)
+	Login(email, password, ipAddress string) (*models.User, map[string]interface{}, error)
 	Register(req RegisterRequest) (*models.User, map[string]interface{}, error)
 	RefreshAuth(refreshToken string) (map[string]interface{}, error)
-	Logout(refreshToken string) error
+	SwitchOrganization(refreshToken, organizationID string) (map[string]interface{}, error)
+	Logout(refreshToken, accessToken string) error
+
+	// Sessions (one per login, followed across refresh token rotation)
+	TrackSession(refreshToken, userAgent, ipAddress string) error
+	ListSessions(userID uuid.UUID) ([]Session, error)
+	RevokeSession(userID uuid.UUID, sessionID string) error
+	LogoutAll(userID uuid.UUID) error
 	
 	// Password Reset & Verification
 	ForgotPassword(email string) error
 	ResetPassword(token, newPassword string) error
-	SendVerificationEmail(user *models.User) error
+	SendVerificationEmail(userID uuid.UUID) error
+	ResendVerificationEmail(email string) error
 	VerifyEmail(token string) error
+	UnlockAccount(token string) error
+
+	// Passwordless login by email link
+	RequestMagicLink(email string) error
+	MagicLinkLogin(token string) (*models.User, map[string]interface{}, error)
+
+	// Email change (confirmed from the new address, cancellable from the old one)
+	RequestEmailChange(userID uuid.UUID, newEmail, password string) error
+	ConfirmEmailChange(token string) error
+	CancelEmailChange(token string) error
+
+	// Multi-factor Authentication (TOTP)
+	VerifyMFA(mfaToken, code string) (*models.User, map[string]interface{}, error)
+	EnrollMFA(userID uuid.UUID) (map[string]interface{}, error)
+	ConfirmMFA(userID uuid.UUID, code string) ([]string, error)
+	DisableMFA(userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error)
 }
 
 // UserService defines the interface for user management logic
 type UserService interface {
 	CreateUser(req CreateUserRequest) (*models.User, error)
 	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserAttributes(id uuid.UUID) (*UserAttributes, error)
 	GetUsers(filters map[string]interface{}, page, limit int, sort string) (*utils.PaginationResult, error)
 	UpdateUser(id uuid.UUID, req UpdateUserRequest) (*models.User, error)
 	DeleteUser(id uuid.UUID) error
+	RestoreUser(id uuid.UUID) (*models.User, error)
+	PurgeDeletedUsers() error
+	StartPurge(interval time.Duration)
+	StartSync(interval time.Duration)
+	ResetMFA(id uuid.UUID) error
+	UnlockUser(id uuid.UUID) error
+
+	// Account status (see models.UserStatusActive)
+	SuspendUser(id uuid.UUID, req SuspendUserRequest) (*models.User, error)
+	BanUser(id uuid.UUID, reason string) (*models.User, error)
+	ReactivateUser(id uuid.UUID) (*models.User, error)
+
+	// Self-service for the signed-in user
+	UpdateProfile(id uuid.UUID, req UpdateProfileRequest) (*models.User, error)
+	ChangePassword(id uuid.UUID, sessionID string, req ChangePasswordRequest) error
+	DeleteAccount(id uuid.UUID, password string) error
+}
+
+// PasskeyService defines the interface for WebAuthn (passkey) registration, login and management
+type PasskeyService interface {
+	BeginRegistration(userID uuid.UUID) (*protocol.CredentialCreation, string, error)
+	FinishRegistration(userID uuid.UUID, sessionID, name string, credentialJSON []byte) (*models.WebAuthnCredential, error)
+	BeginLogin(email string) (*protocol.CredentialAssertion, string, error)
+	FinishLogin(sessionID string, credentialJSON []byte) (*models.User, map[string]interface{}, error)
+	ListCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	RenameCredential(userID uuid.UUID, id uint, name string) (*models.WebAuthnCredential, error)
+	DeleteCredential(userID uuid.UUID, id uint) error
+}
+
+// OIDCService defines the interface for sign-in with external OpenID Connect providers
+type OIDCService interface {
+	Providers() []string
+	AuthorizationURL(provider string, linkUserID *uuid.UUID) (string, error)
+	Callback(provider, state, code string) (*OIDCCallbackResult, error)
+	ListIdentities(userID uuid.UUID) ([]models.Identity, error)
+	Unlink(userID uuid.UUID, id uint) error
+}
+
+// OAuthService defines the interface for the OAuth2 authorization server and its client registry
+type OAuthService interface {
+	CreateClient(req OAuthClientRequest) (*models.OAuthClient, string, error)
+	GetClients() ([]models.OAuthClient, error)
+	GetClient(id string) (*models.OAuthClient, error)
+	UpdateClient(id string, req UpdateOAuthClientRequest) (*models.OAuthClient, error)
+	DeleteClient(id string) error
+	RotateClientSecret(id string) (*models.OAuthClient, string, error)
+	AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error)
+	ValidateAuthorization(req OAuthAuthorizeRequest) (*models.OAuthClient, string, error)
+	Authorize(userID uuid.UUID, req OAuthAuthorizeRequest, approved bool) (string, error)
+	Token(client *models.OAuthClient, req OAuthTokenRequest) (*OAuthTokenResponse, error)
+	Introspect(client *models.OAuthClient, token string) (*OAuthIntrospection, error)
+	Revoke(client *models.OAuthClient, token string) error
+}
+
+// APIKeyService defines the interface for personal API keys
+type APIKeyService interface {
+	CreateKey(userID uuid.UUID, req CreateAPIKeyRequest) (*models.APIKey, string, error)
+	ListKeys(userID uuid.UUID) ([]models.APIKey, error)
+	RevokeKey(userID uuid.UUID, id uint) error
+	Authenticate(secret string) (*models.APIKey, error)
+}
+
+// ServiceAccountService defines the interface for service accounts, users meant for scripts and
+// other services that authenticate only with API keys
+type ServiceAccountService interface {
+	CreateServiceAccount(req CreateServiceAccountRequest) (*models.User, error)
+	GetServiceAccounts(page, limit int) (*utils.PaginationResult, error)
+	GetServiceAccount(id uuid.UUID) (*models.User, error)
+	DeleteServiceAccount(id uuid.UUID) error
+	CreateKey(id uuid.UUID, req CreateAPIKeyRequest) (*models.APIKey, string, error)
+	ListKeys(id uuid.UUID) ([]models.APIKey, error)
+	RevokeKey(id uuid.UUID, keyID uint) error
+}
+
+// InvitationService defines the interface for admin-issued invitations to register
+type InvitationService interface {
+	CreateInvitation(invitedBy uuid.UUID, req CreateInvitationRequest) (*models.Invitation, error)
+	ListPending() ([]models.Invitation, error)
+	RevokeInvitation(id uint) error
+	AcceptInvitation(token string, req AcceptInvitationRequest) (*models.User, map[string]interface{}, error)
+}
+
+// RBACService defines the interface for roles, their permissions and the checks made with them
+type RBACService interface {
+	EnsureDefaults() error
+	GetPermissions() ([]models.Permission, error)
+	CreatePermission(req CreatePermissionRequest) (*models.Permission, error)
+	UpdatePermission(id uint, req UpdatePermissionRequest) (*models.Permission, error)
+	DeletePermission(id uint) error
+	GetRoles() ([]models.Role, error)
+	GetRole(id uint) (*models.Role, error)
+	CreateRole(req CreateRoleRequest) (*models.Role, error)
+	UpdateRole(id uint, req UpdateRoleRequest) (*models.Role, error)
+	DeleteRole(id uint) error
+	GetUserRoles(userID uuid.UUID) ([]models.Role, error)
+	SetUserRoles(userID uuid.UUID, req SetUserRolesRequest) ([]models.Role, error)
+	// Access tokens issued at another version than the current one are stale
+	PermissionsVersion(userID uuid.UUID) (int, error)
+	ForgetPermissionsVersions(userIDs ...uuid.UUID)
+	StartSync(interval time.Duration)
+}
+
+// GroupService defines the interface for groups of users, whose permissions are granted to their members
+type GroupService interface {
+	GetGroups() ([]models.Group, error)
+	GetGroup(id uint) (*models.Group, error)
+	CreateGroup(req CreateGroupRequest) (*models.Group, error)
+	UpdateGroup(id uint, req UpdateGroupRequest) (*models.Group, error)
+	DeleteGroup(id uint) error
+	GetMembers(id uint) ([]models.User, error)
+	AddMembers(id uint, req AddGroupMembersRequest) ([]models.User, error)
+	RemoveMember(id uint, userID uuid.UUID) error
+	GetUserGroups(userID uuid.UUID) ([]models.Group, error)
+}
+
+// OrganizationService defines the interface for organizations (tenants), their members and invitations.
+// The actor's role in the organization decides what it may do, super-admins may do everything.
+type OrganizationService interface {
+	CreateOrganization(ownerID uuid.UUID, req CreateOrganizationRequest) (*models.Organization, error)
+	ListAll() ([]models.Organization, error)
+	ListMemberships(userID uuid.UUID) ([]models.Membership, error)
+	GetOrganization(actor OrganizationActor, id string) (*models.Organization, error)
+	UpdateOrganization(actor OrganizationActor, id string, req UpdateOrganizationRequest) (*models.Organization, error)
+	DeleteOrganization(actor OrganizationActor, id string) error
+
+	// Members
+	ListMembers(actor OrganizationActor, id string) ([]models.Membership, error)
+	UpdateMemberRole(actor OrganizationActor, id string, userID uuid.UUID, req UpdateMemberRequest) (*models.Membership, error)
+	RemoveMember(actor OrganizationActor, id string, userID uuid.UUID) error
+
+	// Invitations (new users accept them through InvitationService.AcceptInvitation)
+	CreateInvitation(actor OrganizationActor, id string, req CreateOrganizationInvitationRequest) (*models.Invitation, error)
+	ListInvitations(actor OrganizationActor, id string) ([]models.Invitation, error)
+	RevokeInvitation(actor OrganizationActor, id string, invitationID uint) error
+	AcceptInvitation(userID uuid.UUID, token string) (*models.Membership, error)
+}
+
+// Policy decides attribute-based access. PolicyDocument is the declarative implementation.
+type Policy interface {
+	Evaluate(req AuthzRequest) AuthzDecision
+}
+
+// PolicyService evaluates the configured policy and reloads it when its file changes
+type PolicyService interface {
+	Policy
+	Reload() error
+	StartWatch(interval time.Duration)
+}
+
+// TokenDenylist defines the interface for access token revocation
+type TokenDenylist interface {
+	RevokeAccessToken(payload *utils.TokenPayload) error
+	RevokeAllAccessTokens(userID string) error
+	RevokeSessionAccessTokens(userID string, sessionIDs []string) error
+	IsRevoked(payload *utils.TokenPayload) bool
+	Reload() error
+	StartSync(interval time.Duration)
 }
 
 // DTOs (Data Transfer Objects) for Requests
 type RegisterRequest struct {
 	Name     string `validate:"required"`
 	Email    string `validate:"required,email"`
-	Password string `validate:"required,min=8"`
+	Password string `validate:"required"` // Rules are in config.PasswordPolicy
 }
 
 type CreateUserRequest struct {
 	RegisterRequest
-	Role string `validate:"required,oneof=user admin"`
+	Role string `validate:"required"` // Name of an existing role
+}
+
+type CreateInvitationRequest struct {
+	Email string `validate:"required,email"`
+	Role  string `validate:"required"` // Name of an existing role
+}
+
+// AcceptInvitationRequest completes an invitation; the email is the invited one
+type AcceptInvitationRequest struct {
+	Name     string `validate:"required"`
+	Password string `validate:"required"` // Rules are in config.PasswordPolicy
+}
+
+// OrganizationActor is who acts on an organization. SuperAdmin holds the organizations:manage permission,
+// ManagesInvitations the invitations:manage one.
+type OrganizationActor struct {
+	UserID             uuid.UUID
+	SuperAdmin         bool
+	ManagesInvitations bool
+}
+
+type CreateOrganizationRequest struct {
+	Name string `validate:"required,max=100,nocontrol"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `validate:"required,max=100,nocontrol"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `validate:"required,oneof=owner admin member"`
+}
+
+type CreateOrganizationInvitationRequest struct {
+	Email string `validate:"required,email"`
+	Role  string `validate:"required,oneof=owner admin member"`
+}
+
+// SwitchOrganizationRequest moves the session of the refresh token into an organization, out of any when empty
+type SwitchOrganizationRequest struct {
+	RefreshToken   string `validate:"required"`
+	OrganizationID string `validate:"omitempty,uuid"`
 }
 
 type UpdateUserRequest struct {
 	Name     string `validate:"omitempty"`
 	Email    string `validate:"omitempty,email"`
-	Password string `validate:"omitempty,min=8"`
-}
\ No newline at end of file
+	Password string `validate:"omitempty"`
+}
+
+type SuspendUserRequest struct {
+	Reason string     `validate:"required"`
+	Until  *time.Time // Suspended until reactivated when omitted
+}
+
+// UpdateProfileRequest holds what users may change about themselves directly. The email
+// has its own confirmed flow and the password needs the current one.
+type UpdateProfileRequest struct {
+	Name string `validate:"omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `validate:"required"`
+	NewPassword     string `validate:"required"` // Rules are in config.PasswordPolicy
+}
+
+type CreatePermissionRequest struct {
+	Name        string `validate:"required"` // resource:action
+	Description string
+}
+
+type UpdatePermissionRequest struct {
+	Description string
+}
+
+type CreateRoleRequest struct {
+	Name        string `validate:"required"`
+	Description string
+	Permissions []string `validate:"dive,required"`
+}
+
+// UpdateRoleRequest changes the given fields; Permissions replaces the role's permissions
+type UpdateRoleRequest struct {
+	Description *string
+	Permissions []string `validate:"omitempty,dive,required"`
+}
+
+type SetUserRolesRequest struct {
+	Roles []string `validate:"required,min=1,dive,required"`
+}
+
+type CreateGroupRequest struct {
+	Name        string `validate:"required"`
+	Description string
+	Permissions []string `validate:"dive,required"`
+}
+
+// UpdateGroupRequest changes the given fields; Permissions replaces the group's permissions
+type UpdateGroupRequest struct {
+	Description *string
+	Permissions []string `validate:"omitempty,dive,required"`
+}
+
+type AddGroupMembersRequest struct {
+	UserIDs []string `validate:"required,min=1,dive,uuid"`
+}
+
+// AuthzRequest holds the attributes an access decision is made on
+type AuthzRequest struct {
+	Subject  map[string]interface{} `json:"subject"`  // The authenticated user: id, roles, groups, permissions, organization
+	Action   string                 `json:"action" validate:"required"`
+	Resource map[string]interface{} `json:"resource"` // What is acted on, e.g. type, id and role of a user
+	Request  map[string]interface{} `json:"request"`  // method, path, ip and fields of the body
+}
+
+type AuthzDecision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule,omitempty"` // Rule that decided, empty when none matched
+	Reason  string `json:"reason"`
+}
+
+// Session describes where a user is signed in. ID is the refresh token family.
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"userAgent"`
+	IPAddress  string     `json:"ipAddress"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	Expires    time.Time  `json:"expires"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `validate:"required"`
+	Scopes    []string   `validate:"dive,oneof=read write"` // Empty for full access
+	ExpiresAt *time.Time // Never expires when omitted
+}
+
+// CreateServiceAccountRequest names the account; its roles are set like any user's
+type CreateServiceAccountRequest struct {
+	Name string `validate:"required"`
+}
+
+type OAuthClientRequest struct {
+	Name         string   `validate:"required"`
+	Public       bool     // No secret; only for the authorization code grant
+	RedirectURIs []string `validate:"dive,url"`
+	Scopes       []string `validate:"dive,required"`
+	GrantTypes   []string `validate:"required,min=1,dive,oneof=client_credentials authorization_code refresh_token"`
+	Audiences    []string `validate:"dive,required"`
+}
+
+// UpdateOAuthClientRequest replaces the given lists, omitted (null) ones are kept
+type UpdateOAuthClientRequest struct {
+	Name         string   `validate:"omitempty"`
+	RedirectURIs []string `validate:"omitempty,dive,url"`
+	Scopes       []string `validate:"omitempty,dive,required"`
+	GrantTypes   []string `validate:"omitempty,min=1,dive,oneof=client_credentials authorization_code refresh_token"`
+	Audiences    []string `validate:"omitempty,dive,required"`
+}
+
+// OAuthAuthorizeRequest holds the parameters of an authorization request (RFC 6749 section 4.1.1, RFC 7636)
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+type OAuthTokenRequest struct {
+	GrantType    string
+	Scope        string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+}
+
+// OAuthTokenResponse is the RFC 6749 section 5.1 token response
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthIntrospection is the RFC 7662 introspection response. Only Active is set for unusable tokens.
+type OAuthIntrospection struct {
+	Active   bool     `json:"active"`
+	Sub      string   `json:"sub,omitempty"`
+	Type     string   `json:"type,omitempty"` // access, refresh or oauthRefresh
+	Scope    string   `json:"scope,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Aud      []string `json:"aud,omitempty"`
+	Exp      int64    `json:"exp,omitempty"`
+	Iat      int64    `json:"iat,omitempty"`
+	Jti      string   `json:"jti,omitempty"`
+}
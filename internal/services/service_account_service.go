@@ -0,0 +1,105 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// Service accounts get an address on a reserved domain (RFC 2606), so no mail ever reaches anyone
+const serviceAccountEmailDomain = "service-accounts.invalid"
+
+var (
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrServiceAccountSignIn   = errors.New("service accounts cannot sign in, use an API key")
+)
+
+type serviceAccountService struct {
+	userRepo repository.UserRepository
+	apiKeys  APIKeyService
+}
+
+func NewServiceAccountService(userRepo repository.UserRepository, apiKeys APIKeyService) ServiceAccountService {
+	return &serviceAccountService{userRepo: userRepo, apiKeys: apiKeys}
+}
+
+// CreateServiceAccount creates the account with the default role and a password nobody knows
+func (s *serviceAccountService) CreateServiceAccount(req CreateServiceAccountRequest) (*models.User, error) {
+	hash, err := utils.HashPassword(rand.Text() + rand.Text())
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.New()
+	account := &models.User{
+		ID:             id,
+		Name:           req.Name,
+		Email:          id.String() + "@" + serviceAccountEmailDomain,
+		Password:       hash,
+		ServiceAccount: true,
+	}
+	if err := s.userRepo.Create(account); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Service account created", "userId", account.ID, "name", account.Name)
+	return account, nil
+}
+
+func (s *serviceAccountService) GetServiceAccounts(page, limit int) (*utils.PaginationResult, error) {
+	filters := map[string]interface{}{"serviceAccount": true}
+	accounts, totalRows, err := s.userRepo.FindAll(filters, &utils.PaginationScope{Page: page, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	result := utils.GetPaginationResult(totalRows, page, limit, accounts)
+	return &result, nil
+}
+
+func (s *serviceAccountService) GetServiceAccount(id uuid.UUID) (*models.User, error) {
+	account, err := s.userRepo.FindByID(id)
+	if repository.IsNotFound(err) || (err == nil && !account.ServiceAccount) {
+		return nil, ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// DeleteServiceAccount soft deletes the account like any user, which stops its API keys working
+func (s *serviceAccountService) DeleteServiceAccount(id uuid.UUID) error {
+	if _, err := s.GetServiceAccount(id); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(id); err != nil {
+		return err
+	}
+	logger.Log.Info("Service account deleted", "userId", id)
+	return nil
+}
+
+func (s *serviceAccountService) CreateKey(id uuid.UUID, req CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if _, err := s.GetServiceAccount(id); err != nil {
+		return nil, "", err
+	}
+	return s.apiKeys.CreateKey(id, req)
+}
+
+func (s *serviceAccountService) ListKeys(id uuid.UUID) ([]models.APIKey, error) {
+	if _, err := s.GetServiceAccount(id); err != nil {
+		return nil, err
+	}
+	return s.apiKeys.ListKeys(id)
+}
+
+func (s *serviceAccountService) RevokeKey(id uuid.UUID, keyID uint) error {
+	if _, err := s.GetServiceAccount(id); err != nil {
+		return err
+	}
+	return s.apiKeys.RevokeKey(id, keyID)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/config"
+	"starter-kit-restapi-gonethttp/internal/models"
+	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
+	"starter-kit-restapi-gonethttp/pkg/utils"
+)
+
+// tokenDenylist persists revoked access tokens in the tokens table and keeps
+// an in-memory copy so the Auth middleware never hits the database.
+type tokenDenylist struct {
+	repo repository.TokenRepository
+	cfg  *config.Config
+
+	mu       sync.RWMutex
+	jtis     map[string]time.Time // jti -> token expiry
+	users    map[string]time.Time // userID -> tokens issued before this time are revoked
+	sessions map[string]time.Time // session (refresh token family) -> entry expiry
+}
+
+func NewTokenDenylist(repo repository.TokenRepository, cfg *config.Config) TokenDenylist {
+	d := &tokenDenylist{
+		repo:     repo,
+		cfg:      cfg,
+		jtis:     make(map[string]time.Time),
+		users:    make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+	}
+	if err := d.Reload(); err != nil {
+		logger.Log.Error("Failed to load token denylist", "error", err)
+	}
+	return d
+}
+
+func (d *tokenDenylist) RevokeAccessToken(payload *utils.TokenPayload) error {
+	if payload.ID == "" || payload.ExpiresAt == nil {
+		return nil
+	}
+
+	entry := &models.Token{
+		Token:       payload.ID,
+		UserID:      payload.Sub,
+		ClientID:    payload.ClientID,
+		Type:        models.TokenTypeAccess,
+		Expires:     payload.ExpiresAt.Time,
+		Blacklisted: true,
+	}
+	if payload.ClientID != "" && payload.Sub == payload.ClientID {
+		// client_credentials token, there is no user behind it
+		entry.UserID = ""
+	}
+	if err := d.repo.Create(entry); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.jtis[payload.ID] = payload.ExpiresAt.Time
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *tokenDenylist) RevokeAllAccessTokens(userID string) error {
+	// JWT timestamps are truncated to the second, so the cutoff is rounded up: every token
+	// issued up to now has an iat before it
+	cutoff := time.Now().Add(time.Second).Truncate(time.Second)
+	entry := &models.Token{
+		Token:       userID,
+		UserID:      userID,
+		Type:        models.TokenTypeRevokeAll,
+		Expires:     cutoff.Add(d.accessTTL()),
+		Blacklisted: true,
+		CreatedAt:   cutoff,
+	}
+	if err := d.repo.Create(entry); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.users[userID] = cutoff
+	d.mu.Unlock()
+	return nil
+}
+
+// RevokeSessionAccessTokens revokes the access tokens issued in the sessions, whose refresh tokens
+// are deleted separately. The entries last as long as an access token.
+func (d *tokenDenylist) RevokeSessionAccessTokens(userID string, sessionIDs []string) error {
+	expires := time.Now().Add(d.accessTTL())
+	for _, sessionID := range sessionIDs {
+		entry := &models.Token{
+			Token:       sessionID,
+			UserID:      userID,
+			Type:        models.TokenTypeRevokeSession,
+			Family:      sessionID,
+			Expires:     expires,
+			Blacklisted: true,
+		}
+		if err := d.repo.Create(entry); err != nil {
+			return err
+		}
+
+		d.mu.Lock()
+		d.sessions[sessionID] = expires
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+func (d *tokenDenylist) IsRevoked(payload *utils.TokenPayload) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, ok := d.jtis[payload.ID]; ok && payload.ID != "" {
+		return true
+	}
+	if _, ok := d.sessions[payload.SessionID]; ok && payload.SessionID != "" {
+		return true
+	}
+	if cutoff, ok := d.users[payload.Sub]; ok {
+		if payload.IssuedAt == nil || payload.IssuedAt.Time.Before(cutoff) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload replaces the in-memory cache with the database contents and prunes expired entries.
+func (d *tokenDenylist) Reload() error {
+	now := time.Now()
+	if err := d.repo.DeleteExpiredBlacklisted(now); err != nil {
+		return err
+	}
+	entries, err := d.repo.FindBlacklisted(now)
+	if err != nil {
+		return err
+	}
+
+	jtis := make(map[string]time.Time)
+	users := make(map[string]time.Time)
+	sessions := make(map[string]time.Time)
+	for _, entry := range entries {
+		switch entry.Type {
+		case models.TokenTypeAccess:
+			jtis[entry.Token] = entry.Expires
+		case models.TokenTypeRevokeAll:
+			if entry.CreatedAt.After(users[entry.UserID]) {
+				users[entry.UserID] = entry.CreatedAt
+			}
+		case models.TokenTypeRevokeSession:
+			sessions[entry.Family] = entry.Expires
+		}
+	}
+
+	d.mu.Lock()
+	d.jtis = jtis
+	d.users = users
+	d.sessions = sessions
+	d.mu.Unlock()
+	return nil
+}
+
+// StartSync periodically reloads the cache so revocations made by other instances are picked up.
+func (d *tokenDenylist) StartSync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := d.Reload(); err != nil {
+				logger.Log.Error("Failed to reload token denylist", "error", err)
+			}
+		}
+	}()
+}
+
+func (d *tokenDenylist) accessTTL() time.Duration {
+	return time.Duration(d.cfg.JWT.AccessExpirationMinutes) * time.Minute
+}
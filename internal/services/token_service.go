@@ -7,26 +7,88 @@ import (
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/repository"
 	"starter-kit-restapi-gonethttp/pkg/utils"
+
+	"github.com/google/uuid"
 )
 
 type TokenService struct {
-	repo repository.TokenRepository
-	cfg  *config.Config
+	repo   repository.TokenRepository
+	roles  repository.RoleRepository
+	groups repository.GroupRepository
+	orgs   repository.OrganizationRepository
+	keys   utils.KeyProvider
+	cfg    *config.Config
+}
+
+func NewTokenService(repo repository.TokenRepository, roles repository.RoleRepository, groups repository.GroupRepository, orgs repository.OrganizationRepository, keys utils.KeyProvider, cfg *config.Config) *TokenService {
+	return &TokenService{repo: repo, roles: roles, groups: groups, orgs: orgs, keys: keys, cfg: cfg}
 }
 
-func NewTokenService(repo repository.TokenRepository, cfg *config.Config) *TokenService {
-	return &TokenService{repo: repo, cfg: cfg}
+// GenerateToken signs a single JWT with the current signing key
+func (s *TokenService) GenerateToken(userID uuid.UUID, expires time.Duration, tokenType string) (string, time.Time, error) {
+	return utils.GenerateToken(userID, expires, tokenType, s.keys)
 }
 
+// GenerateClientToken signs a JWT issued to an OAuth2 client
+func (s *TokenService) GenerateClientToken(subject, clientID, scope string, audience []string, expires time.Duration, tokenType string) (string, time.Time, error) {
+	return utils.GenerateClientToken(subject, clientID, scope, audience, expires, tokenType, s.keys)
+}
+
+// ParseToken verifies a JWT's signature and expiry and returns its claims
+func (s *TokenService) ParseToken(token string) (*utils.TokenPayload, error) {
+	return utils.ValidateToken(token, s.keys)
+}
+
+// GenerateAuthTokens issues a new token pair that starts a fresh refresh token family (session).
 func (s *TokenService) GenerateAuthTokens(user *models.User) (map[string]interface{}, error) {
-	accessToken, refreshToken, accessExp, refreshExp, err := utils.GenerateAuthTokens(user.ID, s.cfg)
+	return s.issueAuthTokens(user, &models.Token{Family: uuid.NewString()})
+}
+
+// RotateAuthTokens issues a new token pair whose refresh token continues the session of previous,
+// in the same organization.
+func (s *TokenService) RotateAuthTokens(user *models.User, previous *models.Token) (map[string]interface{}, error) {
+	session := &models.Token{
+		Family:         previous.Family,
+		UserAgent:      previous.UserAgent,
+		IPAddress:      previous.IPAddress,
+		CreatedAt:      previous.CreatedAt,
+		OrganizationID: previous.OrganizationID,
+	}
+	if session.Family == "" {
+		// Token issued before families existed, start one now
+		session.Family = uuid.NewString()
+	}
+	return s.issueAuthTokens(user, session)
+}
+
+func (s *TokenService) issueAuthTokens(user *models.User, session *models.Token) (map[string]interface{}, error) {
+	// Every sign-in method ends here, so none of them lets a disabled account or a service account in.
+	// Tokens for OAuth clients are checked the same way in oauthService.issueUserTokens.
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
+	}
+	if user.ServiceAccount {
+		return nil, ErrServiceAccountSignIn
+	}
+	if s.cfg.EmailVerification.Required && !user.IsEmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	claims, err := s.accessClaims(user, session)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, refreshToken, accessExp, refreshExp, err := utils.GenerateAuthTokens(claims, s.cfg, s.keys)
 	if err != nil {
 		return nil, err
 	}
 
 	// Save refresh token to database
-	err = s.SaveToken(refreshToken, user.ID.String(), refreshExp, models.TokenTypeRefresh)
-	if err != nil {
+	session.Token = refreshToken
+	session.UserID = user.ID.String()
+	session.Expires = refreshExp
+	session.Type = models.TokenTypeRefresh
+	if err := s.repo.Create(session); err != nil {
 		return nil, err
 	}
 
@@ -42,6 +104,63 @@ func (s *TokenService) GenerateAuthTokens(user *models.User) (map[string]interfa
 	}, nil
 }
 
+// accessClaims puts the user's roles, groups and their permissions in the access token, so requests
+// are authorized without a database lookup, and the organization of the session with the user's role
+// in it. The version is read first: if the roles or groups change meanwhile, the token is stale right
+// away instead of carrying outdated grants unnoticed.
+func (s *TokenService) accessClaims(user *models.User, session *models.Token) (*utils.TokenPayload, error) {
+	version, err := s.roles.FindPermissionsVersion(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roles.FindUserRoles(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groups.FindUserGroups(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &utils.TokenPayload{
+		Sub:                user.ID.String(),
+		SessionID:          session.Family,
+		Permissions:        models.PermissionNames(roles, groups),
+		PermissionsVersion: version,
+	}
+	for _, role := range roles {
+		claims.Roles = append(claims.Roles, role.Name)
+	}
+	for _, group := range groups {
+		claims.Groups = append(claims.Groups, group.Name)
+	}
+
+	if session.OrganizationID != "" {
+		membership, err := s.orgs.FindMembership(session.OrganizationID, user.ID.String())
+		if err != nil {
+			// No longer a member, the session leaves the organization
+			session.OrganizationID = ""
+			return claims, nil
+		}
+		claims.OrganizationID = membership.OrganizationID
+		claims.OrganizationRole = membership.Role
+	}
+	return claims, nil
+}
+
+// GenerateMFAToken issues the short-lived token exchanged for a full pair once the second factor is verified
+func (s *TokenService) GenerateMFAToken(user *models.User) (string, time.Time, error) {
+	expires := time.Duration(s.cfg.JWT.MFAExpirationMinutes) * time.Minute
+	mfaToken, expiresAt, err := s.GenerateToken(user.ID, expires, models.TokenTypeMFAPending)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.SaveToken(mfaToken, user.ID.String(), expiresAt, models.TokenTypeMFAPending); err != nil {
+		return "", time.Time{}, err
+	}
+	return mfaToken, expiresAt, nil
+}
+
 func (s *TokenService) SaveToken(token, userID string, expires time.Time, tokenType string) error {
 	tokenModel := &models.Token{
 		Token:   token,
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"starter-kit-restapi-gonethttp/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// UserAttributes is what access policies know about the user a request acts on
+type UserAttributes struct {
+	Role            string   // Main role
+	Roles           []string // Names of all the user's roles
+	Status          string
+	IsEmailVerified bool
+	OrganizationIDs []string
+}
+
+// userAttributeCache keeps the attributes of the users that requests act on, so policy checks do not
+// look them up every time. Changing the role, status or memberships of a user bumps their permissions
+// version, and an entry is only used while the version it was loaded at is current.
+type userAttributeCache struct {
+	users repository.UserRepository
+	orgs  repository.OrganizationRepository
+	rbac  RBACService
+
+	mu      sync.RWMutex
+	entries map[string]cachedUserAttributes // userID -> attributes
+}
+
+type cachedUserAttributes struct {
+	attributes *UserAttributes
+	version    int
+}
+
+func newUserAttributeCache(users repository.UserRepository, orgs repository.OrganizationRepository, rbac RBACService) *userAttributeCache {
+	return &userAttributeCache{users: users, orgs: orgs, rbac: rbac, entries: make(map[string]cachedUserAttributes)}
+}
+
+// Get returns the attributes of the user, or ErrUserNotFound if there is no such user
+func (c *userAttributeCache) Get(id uuid.UUID) (*UserAttributes, error) {
+	version, err := c.rbac.PermissionsVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	entry, ok := c.entries[id.String()]
+	c.mu.RUnlock()
+	if ok && entry.version == version {
+		return entry.attributes, nil
+	}
+
+	user, err := c.users.FindByID(id)
+	if repository.IsNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	roles, err := c.rbac.GetUserRoles(id)
+	if err != nil {
+		return nil, err
+	}
+	organizationIDs, err := c.orgs.FindOrganizationIDsByUser(id.String())
+	if err != nil {
+		return nil, err
+	}
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+	attributes := &UserAttributes{
+		Role:            user.Role,
+		Roles:           roleNames,
+		Status:          user.Status,
+		IsEmailVerified: user.IsEmailVerified,
+		OrganizationIDs: organizationIDs,
+	}
+	c.mu.Lock()
+	c.entries[id.String()] = cachedUserAttributes{attributes: attributes, version: version}
+	c.mu.Unlock()
+	return attributes, nil
+}
+
+// Forget empties the cache
+func (c *userAttributeCache) Forget() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedUserAttributes)
+}
+
+// StartSync periodically empties the cache, so attributes that do not bump the permissions version,
+// such as isEmailVerified, are picked up within the interval.
+func (c *userAttributeCache) StartSync(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.Forget()
+		}
+	}()
+}
@@ -2,31 +2,59 @@ package services
 
 import (
 	"errors"
+	"time"
 
+	"starter-kit-restapi-gonethttp/config"
 	"starter-kit-restapi-gonethttp/internal/models"
 	"starter-kit-restapi-gonethttp/internal/repository"
+	"starter-kit-restapi-gonethttp/pkg/logger"
 	"starter-kit-restapi-gonethttp/pkg/utils"
 
 	"github.com/google/uuid"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type userService struct {
-	repo repository.UserRepository
+	repo       repository.UserRepository
+	tokenRepo  repository.TokenRepository
+	mfaRepo    repository.MFARecoveryCodeRepository
+	denylist   TokenDenylist
+	rbac       RBACService
+	attributes *userAttributeCache
+	passwords  *PasswordPolicy
+	cfg        *config.Config
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(repo repository.UserRepository, tokenRepo repository.TokenRepository, mfaRepo repository.MFARecoveryCodeRepository, orgRepo repository.OrganizationRepository, denylist TokenDenylist, rbac RBACService, passwords *PasswordPolicy, cfg *config.Config) UserService {
+	return &userService{
+		repo:       repo,
+		tokenRepo:  tokenRepo,
+		mfaRepo:    mfaRepo,
+		denylist:   denylist,
+		rbac:       rbac,
+		attributes: newUserAttributeCache(repo, orgRepo, rbac),
+		passwords:  passwords,
+		cfg:        cfg,
+	}
 }
 
 func (s *userService) CreateUser(req CreateUserRequest) (*models.User, error) {
+	if err := s.passwords.Validate(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
 	if exists, _ := s.repo.ExistsByEmail(req.Email); exists {
 		return nil, errors.New("email already taken")
 	}
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
 
 	user := &models.User{
 		Name:     req.Name,
 		Email:    req.Email,
-		Password: req.Password,
+		Password: hash,
 		Role:     req.Role,
 	}
 
@@ -40,6 +68,16 @@ func (s *userService) GetUserByID(id uuid.UUID) (*models.User, error) {
 	return s.repo.FindByID(id)
 }
 
+// GetUserAttributes returns what access policies know about the user, cached between changes
+func (s *userService) GetUserAttributes(id uuid.UUID) (*UserAttributes, error) {
+	return s.attributes.Get(id)
+}
+
+// StartSync periodically drops the cached user attributes, see GetUserAttributes
+func (s *userService) StartSync(interval time.Duration) {
+	s.attributes.StartSync(interval)
+}
+
 func (s *userService) GetUsers(filters map[string]interface{}, page, limit int, sort string) (*utils.PaginationResult, error) {
 	paginationScope := &utils.PaginationScope{
 		Page:  page,
@@ -66,24 +104,225 @@ func (s *userService) UpdateUser(id uuid.UUID, req UpdateUserRequest) (*models.U
 		if exists, _ := s.repo.ExistsByEmail(req.Email); exists {
 			return nil, errors.New("email already taken")
 		}
+		// Set by an admin, so nobody has proven the new address yet
 		user.Email = req.Email
+		user.PendingEmail = ""
+		user.IsEmailVerified = false
 	}
 	if req.Name != "" {
 		user.Name = req.Name
 	}
-	if req.Password != "" {
-		user.Password = req.Password
+	passwordChanged := req.Password != ""
+	previousHash := user.Password
+	if passwordChanged {
+		if err := s.passwords.Validate(req.Password, user.Email, user.Name); err != nil {
+			return nil, err
+		}
+		if err := s.passwords.CheckReuse(user, req.Password); err != nil {
+			return nil, err
+		}
+		hash, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		user.Password = hash
 	}
 
 	if err := s.repo.Update(user); err != nil {
 		return nil, err
 	}
+
+	if passwordChanged {
+		s.passwords.Remember(user.ID.String(), previousHash)
+		if err := revokeSessions(s.denylist, s.tokenRepo, user.ID); err != nil {
+			return nil, err
+		}
+	}
 	return user, nil
 }
 
+// DeleteUser soft deletes the user and ends their sessions. It can be undone with RestoreUser
+// until the retention period is over.
 func (s *userService) DeleteUser(id uuid.UUID) error {
 	if _, err := s.repo.FindByID(id); err != nil {
 		return errors.New("user not found")
 	}
-	return s.repo.Delete(id)
-}
\ No newline at end of file
+	if err := revokeSessions(s.denylist, s.tokenRepo, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.rbac.ForgetPermissionsVersions(id)
+	logger.Log.Info("User deleted", "userId", id, "by", "admin")
+	return nil
+}
+
+func (s *userService) RestoreUser(id uuid.UUID) (*models.User, error) {
+	restored, err := s.repo.Restore(id)
+	if err != nil {
+		return nil, err
+	}
+	if !restored {
+		return nil, errors.New("deleted user not found")
+	}
+	logger.Log.Info("User restored", "userId", id)
+	return s.repo.FindByID(id)
+}
+
+// PurgeDeletedUsers permanently removes users deleted longer ago than the retention period
+func (s *userService) PurgeDeletedUsers() error {
+	if s.cfg.Users.DeletedRetentionDays <= 0 {
+		return nil
+	}
+	before := time.Now().AddDate(0, 0, -s.cfg.Users.DeletedRetentionDays)
+	purged, err := s.repo.PurgeDeleted(before)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		logger.Log.Info("Purged deleted users", "count", purged, "deletedBefore", before)
+	}
+	return nil
+}
+
+// StartPurge runs PurgeDeletedUsers now and then at every interval
+func (s *userService) StartPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.PurgeDeletedUsers(); err != nil {
+				logger.Log.Error("Failed to purge deleted users", "error", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ResetMFA lets an admin turn off MFA for a user who lost their authenticator and recovery codes
+func (s *userService) ResetMFA(id uuid.UUID) error {
+	user, err := s.repo.FindByID(id)
+	if repository.IsNotFound(err) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return resetUserMFA(s.repo, s.mfaRepo, user)
+}
+
+// revokeSessions invalidates every access and refresh token of the user, including those
+// issued to OAuth clients acting for them
+func revokeSessions(denylist TokenDenylist, tokenRepo repository.TokenRepository, id uuid.UUID) error {
+	if err := denylist.RevokeAllAccessTokens(id.String()); err != nil {
+		return err
+	}
+	if err := tokenRepo.DeleteByUserIDAndType(id.String(), models.TokenTypeOAuthRefresh); err != nil {
+		return err
+	}
+	return tokenRepo.DeleteByUserIDAndType(id.String(), models.TokenTypeRefresh)
+}
+
+// UnlockUser lifts a login lockout, e.g. once support has verified the account owner
+func (s *userService) UnlockUser(id uuid.UUID) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return errors.New("user not found")
+	}
+	if err := s.repo.ResetLoginFailures(id); err != nil {
+		return err
+	}
+	logger.Log.Info("Account unlocked", "userId", id, "by", "admin")
+
+	return s.tokenRepo.DeleteByUserIDAndType(id.String(), models.TokenTypeUnlockAccount)
+}
+
+func (s *userService) UpdateProfile(id uuid.UUID, req UpdateProfileRequest) (*models.User, error) {
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password once the current one is confirmed. The session making
+// the change stays signed in, the others are ended and their access tokens revoked.
+func (s *userService) ChangePassword(id uuid.UUID, sessionID string, req ChangePasswordRequest) error {
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if !utils.CheckPassword(req.CurrentPassword, user.Password) {
+		return ErrIncorrectPassword
+	}
+	if err := s.passwords.Validate(req.NewPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	if err := s.passwords.CheckReuse(user, req.NewPassword); err != nil {
+		return err
+	}
+
+	hash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	previousHash := user.Password
+	user.Password = hash
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	s.passwords.Remember(user.ID.String(), previousHash)
+	logger.Log.Info("Password changed", "userId", user.ID)
+
+	return s.endOtherSessions(user.ID, sessionID)
+}
+
+// endOtherSessions signs the user out everywhere but in the given session, revoking the access
+// tokens of the other sessions along with their refresh tokens
+func (s *userService) endOtherSessions(id uuid.UUID, sessionID string) error {
+	sessions, err := s.tokenRepo.FindSessions(id.String(), time.Now())
+	if err != nil {
+		return err
+	}
+	others := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Family != "" && session.Family != sessionID {
+			others = append(others, session.Family)
+		}
+	}
+	if err := s.denylist.RevokeSessionAccessTokens(id.String(), others); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteSessionsExcept(id.String(), sessionID)
+}
+
+// DeleteAccount lets users delete themselves after confirming their password
+func (s *userService) DeleteAccount(id uuid.UUID, password string) error {
+	user, err := s.repo.FindByID(id)
+	if repository.IsNotFound(err) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPassword(password, user.Password) {
+		return ErrIncorrectPassword
+	}
+
+	if err := revokeSessions(s.denylist, s.tokenRepo, user.ID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(user.ID); err != nil {
+		return err
+	}
+	s.rbac.ForgetPermissionsVersions(user.ID)
+	logger.Log.Info("Account deleted", "userId", user.ID, "by", "self")
+	return nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is a single JWT key identified by its kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   interface{} // []byte for HS256, crypto.Signer otherwise
+	Public    interface{} // []byte for HS256, crypto.PublicKey otherwise
+}
+
+// KeyProvider supplies the key used to sign new tokens and the keys accepted when verifying them
+type KeyProvider interface {
+	SigningKey() (*SigningKey, error)
+	VerificationKey(kid string) (*SigningKey, error)
+}
+
+// NewHMACKey wraps a shared secret as an HS256 signing key
+func NewHMACKey(id, secret string) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Algorithm: AlgorithmHS256,
+		Private:   []byte(secret),
+		Public:    []byte(secret),
+	}
+}
+
+// GenerateSigningKey creates a fresh asymmetric key pair for the given algorithm
+func GenerateSigningKey(id, algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{ID: id, Algorithm: algorithm, Private: private, Public: private.Public()}, nil
+}
+
+// ParseSigningKey restores an asymmetric key from its PKCS#8 PEM encoding
+func ParseSigningKey(id, algorithm, privatePEM string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	key := &SigningKey{ID: id, Algorithm: algorithm, Private: private, Public: private.Public()}
+	if _, err := key.Method(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodePrivateKey returns the PKCS#8 PEM encoding of an asymmetric key
+func (k *SigningKey) EncodePrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// Method returns the jwt signing method matching the key, checking the key type agrees with the algorithm
+func (k *SigningKey) Method() (jwt.SigningMethod, error) {
+	switch k.Algorithm {
+	case AlgorithmHS256:
+		if _, ok := k.Private.([]byte); ok {
+			return jwt.SigningMethodHS256, nil
+		}
+	case AlgorithmRS256:
+		if _, ok := k.Public.(*rsa.PublicKey); ok {
+			return jwt.SigningMethodRS256, nil
+		}
+	case AlgorithmEdDSA:
+		if _, ok := k.Public.(ed25519.PublicKey); ok {
+			return jwt.SigningMethodEdDSA, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q does not match algorithm %s", k.ID, k.Algorithm)
+}
+
+// JWK returns the public part of the key as a JSON Web Key, or nil for shared secrets
+func (k *SigningKey) JWK() map[string]interface{} {
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return map[string]interface{}{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": k.Algorithm,
+			"kid": k.ID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"use": "sig",
+			"alg": k.Algorithm,
+			"kid": k.ID,
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	}
+	return nil
+}
@@ -1,17 +1,184 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"starter-kit-restapi-gonethttp/config"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// PasswordHasher hashes passwords with argon2id or bcrypt. Hashes are self-describing
+// encoded strings (PHC format "$argon2id$v=19$m=...,t=...,p=...$salt$hash" or bcrypt's
+// "$2a$cost$..."), so any of them can be verified whatever the current settings are.
+type PasswordHasher struct {
+	cfg config.PasswordHashConfig
+}
+
+func NewPasswordHasher(cfg config.PasswordHashConfig) (*PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		if cfg.Argon2Memory < 1 || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+	case PasswordAlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm %q", cfg.Algorithm)
+	}
+	return &PasswordHasher{cfg: cfg}, nil
+}
+
+// Hash encodes a plain text password with the configured algorithm and parameters
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == PasswordAlgorithmBcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(bytes), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	params := argon2Params{
+		memory:      uint32(h.cfg.Argon2Memory),
+		iterations:  uint32(h.cfg.Argon2Iterations),
+		parallelism: uint8(h.cfg.Argon2Parallelism),
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify compares a plain text password with a hash made by any supported algorithm
+func (h *PasswordHasher) Verify(password, encoded string) bool {
+	if isBcryptHash(encoded) {
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+	}
+
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or other parameters than
+// the configured ones. It should be replaced the next time the plain text password is known.
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	if h.cfg.Algorithm == PasswordAlgorithmBcrypt {
+		if !isBcryptHash(encoded) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+
+	params, _, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != uint32(h.cfg.Argon2Memory) ||
+		params.iterations != uint32(h.cfg.Argon2Iterations) ||
+		params.parallelism != uint8(h.cfg.Argon2Parallelism) ||
+		len(key) != argon2KeyLength
+}
+
+type argon2Params struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+}
+
+func decodeArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, errors.New("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, errors.New("invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+	return params, salt, key, nil
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// passwordHasher is replaced at startup by SetPasswordHasher with the configured one
+var passwordHasher = &PasswordHasher{cfg: config.PasswordHashConfig{
+	Algorithm:         PasswordAlgorithmArgon2id,
+	Argon2Memory:      19456,
+	Argon2Iterations:  2,
+	Argon2Parallelism: 1,
+	BcryptCost:        bcrypt.DefaultCost,
+}}
+
+// SetPasswordHasher sets the hasher used by HashPassword, CheckPassword and PasswordNeedsRehash
+func SetPasswordHasher(h *PasswordHasher) {
+	passwordHasher = h
+}
+
 // HashPassword hashes a plain text password
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return passwordHasher.Hash(password)
 }
 
 // CheckPassword compares a hashed password with a plain text password
 func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return passwordHasher.Verify(password, hash)
+}
+
+// PasswordNeedsRehash reports whether a password hash is not in the configured algorithm and parameters
+func PasswordNeedsRehash(hash string) bool {
+	return passwordHasher.NeedsRehash(hash)
+}
+
+// IsPasswordHash reports whether the value is an encoded hash that CheckPassword understands
+func IsPasswordHash(value string) bool {
+	if isBcryptHash(value) {
+		return true
+	}
+	_, _, _, err := decodeArgon2id(value)
 	return err == nil
-}
\ No newline at end of file
+}
+
+// HashSecret hashes a random, high-entropy secret (client secret, authorization code) for storage.
+// A fast hash is enough because such secrets cannot be brute-forced like passwords.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -11,26 +11,60 @@ import (
 )
 
 type TokenPayload struct {
-	Sub  string `json:"sub"` // User ID
-	Type string `json:"type"`
+	Sub       string `json:"sub"` // User ID, or the client ID for client_credentials tokens
+	Type      string `json:"type"`
+	Scope     string `json:"scope,omitempty"`     // Space separated OAuth2 scopes
+	ClientID  string `json:"client_id,omitempty"` // Set on tokens issued to OAuth2 clients
+	SessionID string `json:"sid,omitempty"`       // Refresh token family an access token was issued with
+
+	// What a user's access token allows, as of the user's permissions version when it was issued
+	Roles              []string `json:"roles,omitempty"`
+	Groups             []string `json:"groups,omitempty"`
+	Permissions        []string `json:"permissions,omitempty"`
+	PermissionsVersion int      `json:"pv,omitempty"`
+	// Organization the session acts in, with the user's role in it
+	OrganizationID   string `json:"org,omitempty"`
+	OrganizationRole string `json:"orgRole,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a signed JWT token
-func GenerateToken(userID uuid.UUID, expires time.Duration, tokenType string, secret string) (string, time.Time, error) {
-	expirationTime := time.Now().Add(expires)
+// GenerateToken creates a JWT token signed with the provider's current key
+func GenerateToken(userID uuid.UUID, expires time.Duration, tokenType string, keys KeyProvider) (string, time.Time, error) {
+	return signToken(&TokenPayload{Sub: userID.String(), Type: tokenType}, expires, keys)
+}
 
+// GenerateClientToken creates a JWT for an OAuth2 client, carrying its scope and audience
+func GenerateClientToken(subject, clientID, scope string, audience []string, expires time.Duration, tokenType string, keys KeyProvider) (string, time.Time, error) {
 	claims := &TokenPayload{
-		Sub:  userID.String(),
-		Type: tokenType,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		Sub:              subject,
+		Type:             tokenType,
+		Scope:            scope,
+		ClientID:         clientID,
+		RegisteredClaims: jwt.RegisteredClaims{Audience: audience},
 	}
+	return signToken(claims, expires, keys)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secret))
+func signToken(claims *TokenPayload, expires time.Duration, keys KeyProvider) (string, time.Time, error) {
+	expirationTime := time.Now().Add(expires)
+	claims.ID = uuid.NewString() // jti, lets individual tokens be revoked
+	claims.ExpiresAt = jwt.NewNumericDate(expirationTime)
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+
+	key, err := keys.SigningKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	method, err := key.Method()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signedToken, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -38,13 +72,23 @@ func GenerateToken(userID uuid.UUID, expires time.Duration, tokenType string, se
 	return signedToken, expirationTime, nil
 }
 
-// ValidateToken parses and verifies a JWT token
-func ValidateToken(tokenString string, secret string) (*TokenPayload, error) {
+// ValidateToken parses and verifies a JWT token against the key named by its kid header
+func ValidateToken(tokenString string, keys KeyProvider) (*TokenPayload, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &TokenPayload{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// The key decides the algorithm, never the token header
+		method, err := key.Method()
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secret), nil
+		return key.Public, nil
 	})
 
 	if err != nil {
@@ -58,19 +102,21 @@ func ValidateToken(tokenString string, secret string) (*TokenPayload, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
-// GenerateAuthTokens generates both Access and Refresh tokens
-func GenerateAuthTokens(userID uuid.UUID, cfg *config.Config) (string, string, time.Time, time.Time, error) {
+// GenerateAuthTokens generates both Access and Refresh tokens. The access token carries the given claims:
+// the user, the session it belongs to and what it allows.
+func GenerateAuthTokens(access *TokenPayload, cfg *config.Config, keys KeyProvider) (string, string, time.Time, time.Time, error) {
+	access.Type = "access"
 	accessTokenExpires := time.Duration(cfg.JWT.AccessExpirationMinutes) * time.Minute
-	accessToken, accessExp, err := GenerateToken(userID, accessTokenExpires, "access", cfg.JWT.Secret)
+	accessToken, accessExp, err := signToken(access, accessTokenExpires, keys)
 	if err != nil {
 		return "", "", time.Time{}, time.Time{}, err
 	}
 
 	refreshTokenExpires := time.Duration(cfg.JWT.RefreshExpirationDays) * 24 * time.Hour
-	refreshToken, refreshExp, err := GenerateToken(userID, refreshTokenExpires, "refresh", cfg.JWT.Secret)
+	refreshToken, refreshExp, err := signToken(&TokenPayload{Sub: access.Sub, Type: "refresh"}, refreshTokenExpires, keys)
 	if err != nil {
 		return "", "", time.Time{}, time.Time{}, err
 	}
 
 	return accessToken, refreshToken, accessExp, refreshExp, nil
-}
\ No newline at end of file
+}
@@ -1,10 +1,22 @@
 package utils
 
 import (
+	"strings"
+	"unicode"
+
 	"github.com/go-playground/validator/v10"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// nocontrol refuses control characters, such as line breaks in names that end up in email headers
+	v.RegisterValidation("nocontrol", func(fl validator.FieldLevel) bool {
+		return strings.IndexFunc(fl.Field().String(), unicode.IsControl) < 0
+	})
+	return v
+}
 
 type ErrorResponse struct {
 	FailedField string